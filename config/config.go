@@ -1,9 +1,13 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -16,12 +20,66 @@ type Config struct {
 	BaseURL     string
 
 	// Database
-	DatabaseDSN string
+	DatabaseDriver      string
+	DatabaseDSN         string
+	DatabaseAutoMigrate bool
 
 	// JWT settings
 	JWTSecret     string
 	JWTAccessTTL  time.Duration
 	JWTRefreshTTL time.Duration
+	// Access tokens are signed with the RSA or Ed25519 key in
+	// JWTSigningKeyFile when set, and with JWTSecret (HS256) otherwise
+	JWTSigningKeyFile       string
+	JWTVerificationKeyFiles []string
+
+	// Email
+	MailDriver           string
+	MailFrom             string
+	MailLogPath          string
+	SMTPHost             string
+	SMTPPort             int
+	SMTPUsername         string
+	SMTPPassword         string
+	EmailVerifyURL       string
+	PasswordResetURL     string
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	RequireVerifiedEmail bool
+
+	// Workspace invitations
+	WorkspaceInviteURL string
+	WorkspaceInviteTTL time.Duration
+
+	// OpenID Connect login, enabled when OIDCIssuerURL is set
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+
+	// Login throttling
+	LoginMaxFailures     int
+	LoginFailureWindow   time.Duration
+	LoginLockoutDuration time.Duration
+
+	// Password-protected links
+	LinkUnlockTTL time.Duration
+
+	// Bulk link creation
+	BulkLinksMax int
+
+	// Click tracking
+	IPHashSalt         string
+	GeoCountryHeader   string
+	ClickQueueSize     int
+	ClickBatchSize     int
+	ClickFlushInterval time.Duration
+
+	// Redirect cache
+	RedirectCacheSize        int
+	RedirectCacheTTL         time.Duration
+	RedirectCacheNegativeTTL time.Duration
 
 	// Rate limiting
 	RateLimitAuth     int
@@ -37,25 +95,96 @@ func Load() *Config {
 	}
 
 	cfg := &Config{
-		Environment:       getEnv("APP_ENV", "development"),
-		Port:              getEnv("APP_PORT", "8080"),
-		BaseURL:           getEnv("APP_BASE_URL", "http://localhost:8080"),
-		DatabaseDSN:       getEnv("DB_DSN", "postgres://postgres:@localhost:5432/shortener?sslmode=disable"),
-		JWTSecret:         getEnv("JWT_SECRET", "super-secret-change-in-production"),
-		JWTAccessTTL:      parseDuration(getEnv("JWT_ACCESS_TTL", "15m")),
-		JWTRefreshTTL:     parseDuration(getEnv("JWT_REFRESH_TTL", "168h")),
-		RateLimitAuth:     parseInt(getEnv("RATE_LIMIT_AUTH", "5")),
-		RateLimitRedirect: parseInt(getEnv("RATE_LIMIT_REDIRECT", "200")),
-		LogLevel:          getEnv("LOG_LEVEL", "info"),
+		Environment:              getEnv("APP_ENV", "development"),
+		Port:                     getEnv("APP_PORT", "8080"),
+		BaseURL:                  getEnv("APP_BASE_URL", "http://localhost:8080"),
+		DatabaseDriver:           getEnv("DB_DRIVER", "postgres"),
+		DatabaseDSN:              getEnv("DB_DSN", ""),
+		DatabaseAutoMigrate:      parseBool(getEnv("DB_AUTO_MIGRATE", "true")),
+		JWTSecret:                getEnv("JWT_SECRET", "super-secret-change-in-production"),
+		JWTAccessTTL:             parseDuration(getEnv("JWT_ACCESS_TTL", "15m")),
+		JWTRefreshTTL:            parseDuration(getEnv("JWT_REFRESH_TTL", "168h")),
+		JWTSigningKeyFile:        getEnv("JWT_SIGNING_KEY_FILE", ""),
+		JWTVerificationKeyFiles:  parseList(getEnv("JWT_VERIFICATION_KEY_FILES", "")),
+		MailDriver:               getEnv("MAIL_DRIVER", "log"),
+		MailFrom:                 getEnv("MAIL_FROM", "CleanShort <no-reply@localhost>"),
+		MailLogPath:              getEnv("MAIL_LOG_PATH", ""),
+		SMTPHost:                 getEnv("SMTP_HOST", ""),
+		SMTPPort:                 parseInt(getEnv("SMTP_PORT", "587")),
+		SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+		EmailVerifyURL:           getEnv("EMAIL_VERIFY_URL", ""),
+		PasswordResetURL:         getEnv("PASSWORD_RESET_URL", ""),
+		EmailVerificationTTL:     parseDuration(getEnv("EMAIL_VERIFICATION_TTL", "24h")),
+		PasswordResetTTL:         parseDuration(getEnv("PASSWORD_RESET_TTL", "1h")),
+		RequireVerifiedEmail:     parseBool(getEnv("REQUIRE_VERIFIED_EMAIL", "false")),
+		WorkspaceInviteURL:       getEnv("WORKSPACE_INVITE_URL", ""),
+		WorkspaceInviteTTL:       parseDuration(getEnv("WORKSPACE_INVITE_TTL", "168h")),
+		OIDCIssuerURL:            getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:             getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:         getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:          getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:               strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		LoginMaxFailures:         parseInt(getEnv("LOGIN_MAX_FAILURES", "10")),
+		LoginFailureWindow:       parseDuration(getEnv("LOGIN_FAILURE_WINDOW", "15m")),
+		LoginLockoutDuration:     parseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "15m")),
+		LinkUnlockTTL:            parseDuration(getEnv("LINK_UNLOCK_TTL", "1h")),
+		BulkLinksMax:             parseInt(getEnv("BULK_LINKS_MAX", "500")),
+		IPHashSalt:               getEnv("IP_HASH_SALT", ""),
+		GeoCountryHeader:         getEnv("GEO_COUNTRY_HEADER", "CF-IPCountry"),
+		ClickQueueSize:           parseInt(getEnv("CLICK_QUEUE_SIZE", "10000")),
+		ClickBatchSize:           parseInt(getEnv("CLICK_BATCH_SIZE", "500")),
+		ClickFlushInterval:       parseDuration(getEnv("CLICK_FLUSH_INTERVAL", "2s")),
+		RedirectCacheSize:        parseInt(getEnv("REDIRECT_CACHE_SIZE", "10000")),
+		RedirectCacheTTL:         parseDuration(getEnv("REDIRECT_CACHE_TTL", "30s")),
+		RedirectCacheNegativeTTL: parseDuration(getEnv("REDIRECT_CACHE_NEGATIVE_TTL", "5s")),
+		RateLimitAuth:            parseInt(getEnv("RATE_LIMIT_AUTH", "5")),
+		RateLimitRedirect:        parseInt(getEnv("RATE_LIMIT_REDIRECT", "200")),
+		LogLevel:                 getEnv("LOG_LEVEL", "info"),
 	}
 
 	if cfg.JWTSecret == "super-secret-change-in-production" && cfg.Environment == "production" {
 		log.Fatal("JWT_SECRET must be set in production")
 	}
 
+	if cfg.OIDCIssuerURL != "" && cfg.OIDCClientID == "" {
+		log.Fatal("OIDC_CLIENT_ID must be set when OIDC_ISSUER_URL is set")
+	}
+
+	// A zero interval would make the click recorder's ticker panic
+	if cfg.ClickFlushInterval <= 0 {
+		log.Fatal("CLICK_FLUSH_INTERVAL must be positive")
+	}
+
+	if cfg.OIDCRedirectURL == "" {
+		cfg.OIDCRedirectURL = strings.TrimSuffix(cfg.BaseURL, "/") + "/api/v1/auth/oidc/callback"
+	}
+
+	if cfg.DatabaseDSN == "" {
+		switch cfg.DatabaseDriver {
+		case "sqlite":
+			cfg.DatabaseDSN = "cleanshort.db"
+		case "postgres":
+			cfg.DatabaseDSN = "postgres://postgres:@localhost:5432/shortener?sslmode=disable"
+		}
+	}
+
+	// Without IP_HASH_SALT the key is derived from JWT_SECRET, so the hashes
+	// are never keyed with the token signing secret itself
+	if cfg.IPHashSalt == "" {
+		cfg.IPHashSalt = deriveKey(cfg.JWTSecret, "ip-hash")
+	}
+
 	return cfg
 }
 
+// deriveKey returns a key for one purpose derived from secret
+func deriveKey(secret, purpose string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -77,4 +206,23 @@ func parseDuration(s string) time.Duration {
 		log.Fatalf("Invalid duration value: %s", s)
 	}
 	return d
-}
\ No newline at end of file
+}
+
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		log.Fatalf("Invalid boolean value: %s", s)
+	}
+	return b
+}
+
+// parseList splits a comma-separated value, dropping empty entries
+func parseList(s string) []string {
+	var values []string
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
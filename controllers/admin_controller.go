@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/services"
+)
+
+type AdminController struct {
+	adminService *services.AdminService
+}
+
+func NewAdminController(adminService *services.AdminService) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+	}
+}
+
+func (ac *AdminController) ListUsers(c *fiber.Ctx) error {
+	limit, offset := pageParams(c)
+
+	suspended, ok := boolParam(c.Query("suspended"))
+	if !ok {
+		return adminValidationError(c, "Invalid suspended value. Allowed values: true, false")
+	}
+
+	role := c.Query("role")
+	if role != "" && role != models.RoleUser && role != models.RoleAdmin {
+		return adminValidationError(c, "Invalid role. Allowed values: user, admin")
+	}
+
+	users, err := ac.adminService.ListUsers(repositories.UserFilter{
+		Query:     c.Query("query"),
+		Role:      role,
+		Suspended: suspended,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return adminError(c, err, "Failed to retrieve users")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(users)
+}
+
+func (ac *AdminController) GetUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return adminValidationError(c, "Invalid user ID")
+	}
+
+	user, err := ac.adminService.GetUser(userID)
+	if err != nil {
+		return adminError(c, err, "Failed to retrieve user")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+func (ac *AdminController) SuspendUser(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(uuid.UUID)
+
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return adminValidationError(c, "Invalid user ID")
+	}
+
+	user, err := ac.adminService.SuspendUser(adminID, userID)
+	if err != nil {
+		return adminError(c, err, "Failed to suspend user")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+func (ac *AdminController) UnsuspendUser(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(uuid.UUID)
+
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return adminValidationError(c, "Invalid user ID")
+	}
+
+	user, err := ac.adminService.UnsuspendUser(adminID, userID)
+	if err != nil {
+		return adminError(c, err, "Failed to unsuspend user")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+func (ac *AdminController) ListLinks(c *fiber.Ctx) error {
+	limit, offset := pageParams(c)
+
+	active, ok := boolParam(c.Query("active"))
+	if !ok {
+		return adminValidationError(c, "Invalid active value. Allowed values: true, false")
+	}
+
+	var userID uuid.UUID
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		var err error
+		if userID, err = uuid.Parse(userIDStr); err != nil {
+			return adminValidationError(c, "Invalid user ID")
+		}
+	}
+
+	sortBy := c.Query("sort_by", "created_at")
+	switch sortBy {
+	case "created_at", "updated_at", "title", "short_code", "click_count", "last_clicked_at":
+	default:
+		return adminValidationError(c, "Invalid sort_by field. Allowed values: created_at, updated_at, title, short_code, click_count, last_clicked_at")
+	}
+
+	orderBy := c.Query("order_by", "desc")
+	if orderBy != "asc" && orderBy != "desc" {
+		return adminValidationError(c, "Invalid order_by value. Allowed values: asc, desc")
+	}
+
+	links, err := ac.adminService.ListLinks(repositories.LinkFilter{
+		UserID:  userID,
+		Query:   c.Query("query"),
+		Active:  active,
+		SortBy:  sortBy,
+		OrderBy: orderBy,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		return adminError(c, err, "Failed to retrieve links")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(links)
+}
+
+func (ac *AdminController) DisableLink(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(uuid.UUID)
+
+	linkID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return adminValidationError(c, "Invalid link ID")
+	}
+
+	link, err := ac.adminService.DisableLink(adminID, linkID)
+	if err != nil {
+		return adminError(c, err, "Failed to disable link")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(link)
+}
+
+func (ac *AdminController) EnableLink(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(uuid.UUID)
+
+	linkID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return adminValidationError(c, "Invalid link ID")
+	}
+
+	link, err := ac.adminService.EnableLink(adminID, linkID)
+	if err != nil {
+		return adminError(c, err, "Failed to enable link")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(link)
+}
+
+func (ac *AdminController) GetStats(c *fiber.Ctx) error {
+	stats, err := ac.adminService.GetStats()
+	if err != nil {
+		return adminError(c, err, "Failed to retrieve stats")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(stats)
+}
+
+// pageParams reads limit (1-100, default 20) and offset
+func pageParams(c *fiber.Ctx) (limit, offset int) {
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err = strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+// boolParam parses an optional true/false query value
+func boolParam(value string) (*bool, bool) {
+	switch value {
+	case "":
+		return nil, true
+	case "true", "false":
+		b := value == "true"
+		return &b, true
+	default:
+		return nil, false
+	}
+}
+
+func adminValidationError(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "VALIDATION_ERROR",
+			Message:   message,
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+func adminError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case strings.Contains(err.Error(), "user not found"):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "NOT_FOUND",
+				Message:   "User not found",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "link not found"):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "LINK_NOT_FOUND",
+				Message:   "Short link not found",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "cannot suspend an admin"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Admins cannot be suspended",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   fallback,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+)
+
+type AnalyticsController struct {
+	analyticsService *services.AnalyticsService
+}
+
+func NewAnalyticsController(analyticsService *services.AnalyticsService) *AnalyticsController {
+	return &AnalyticsController{
+		analyticsService: analyticsService,
+	}
+}
+
+func (ac *AnalyticsController) GetLinkStats(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	linkIDStr := c.Params("id")
+	linkID, err := uuid.Parse(linkIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid link ID",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	interval := c.Query("interval", "day")
+	if interval != "hour" && interval != "day" && interval != "week" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid interval value. Allowed values: hour, day, week",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid to value. Expected RFC 3339 timestamp",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+	if to == nil {
+		now := time.Now()
+		to = &now
+	}
+
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid from value. Expected RFC 3339 timestamp",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+	if from == nil {
+		defaultFrom := to.AddDate(0, 0, -30)
+		from = &defaultFrom
+	}
+
+	stats, err := ac.analyticsService.GetLinkStats(userID, linkID, *from, *to, interval)
+	if err != nil {
+		if strings.Contains(err.Error(), "insufficient workspace role") {
+			return insufficientWorkspaceRole(c)
+		}
+
+		if strings.Contains(err.Error(), "not found") {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "LINK_NOT_FOUND",
+					Message:   "Short link not found",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "invalid range") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Invalid time range: from must be before to and cover at most 1000 intervals",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to retrieve link stats",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(stats)
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+type APIKeyController struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (kc *APIKeyController) CreateAPIKey(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.APIKeyCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	scopes, _ := c.Locals("scopes").([]string)
+
+	key, err := kc.apiKeyService.CreateAPIKey(userID, scopes, &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "insufficient scope") {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "INSUFFICIENT_SCOPE",
+					Message:   "API keys cannot have scopes the current credential lacks",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to create API key",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+func (kc *APIKeyController) ListAPIKeys(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	keys, err := kc.apiKeyService.ListAPIKeys(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to retrieve API keys",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keys)
+}
+
+func (kc *APIKeyController) RevokeAPIKey(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	keyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid API key ID",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := kc.apiKeyService.RevokeAPIKey(userID, keyID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "API_KEY_NOT_FOUND",
+					Message:   "API key not found",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to revoke API key",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
@@ -1,9 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/zhakazx/cleanshort/models"
 	"github.com/zhakazx/cleanshort/services"
 	"github.com/zhakazx/cleanshort/utils"
@@ -75,8 +81,17 @@ func (ac *AuthController) Login(c *fiber.Ctx) error {
 		return utils.HandleValidationError(c, err)
 	}
 
-	authResponse, err := ac.authService.Login(&req)
+	authResponse, challenge, err := ac.authService.Login(&req, clientInfo(c))
 	if err != nil {
+		var locked *services.AccountLockedError
+		if errors.As(err, &locked) {
+			return accountLocked(c, locked)
+		}
+
+		if strings.Contains(err.Error(), "account suspended") {
+			return accountSuspended(c)
+		}
+
 		if strings.Contains(err.Error(), "invalid credentials") {
 			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
 				Error: models.ErrorDetail{
@@ -96,6 +111,70 @@ func (ac *AuthController) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	if challenge != nil {
+		return c.Status(fiber.StatusOK).JSON(challenge)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(authResponse)
+}
+
+// LoginTwoFactor completes a login that returned a two-factor challenge
+func (ac *AuthController) LoginTwoFactor(c *fiber.Ctx) error {
+	var req models.TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	authResponse, err := ac.authService.CompleteTwoFactorLogin(&req, clientInfo(c))
+	if err != nil {
+		var locked *services.AccountLockedError
+		if errors.As(err, &locked) {
+			return accountLocked(c, locked)
+		}
+
+		if strings.Contains(err.Error(), "account suspended") {
+			return accountSuspended(c)
+		}
+
+		if strings.Contains(err.Error(), "invalid challenge token") {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "UNAUTHORIZED",
+					Message:   "Challenge token is invalid or expired, please log in again",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "invalid two-factor code") {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "INVALID_2FA_CODE",
+					Message:   "Two-factor code is invalid or was already used",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to authenticate user",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
 	return c.Status(fiber.StatusOK).JSON(authResponse)
 }
 
@@ -115,8 +194,22 @@ func (ac *AuthController) RefreshToken(c *fiber.Ctx) error {
 		return utils.HandleValidationError(c, err)
 	}
 
-	tokenResponse, err := ac.authService.RefreshToken(&req)
+	tokenResponse, err := ac.authService.RefreshToken(&req, clientInfo(c))
 	if err != nil {
+		if strings.Contains(err.Error(), "account suspended") {
+			return accountSuspended(c)
+		}
+
+		if strings.Contains(err.Error(), "reuse detected") {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "UNAUTHORIZED",
+					Message:   "Refresh token was already used, please log in again",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
 		if strings.Contains(err.Error(), "invalid") || strings.Contains(err.Error(), "expired") || strings.Contains(err.Error(), "revoked") {
 			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
 				Error: models.ErrorDetail{
@@ -176,4 +269,223 @@ func (ac *AuthController) Logout(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
-}
\ No newline at end of file
+}
+
+func (ac *AuthController) ListSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var currentSessionID *uuid.UUID
+	if sessionID, ok := c.Locals("sessionID").(uuid.UUID); ok {
+		currentSessionID = &sessionID
+	}
+
+	sessions, err := ac.authService.ListSessions(userID, currentSessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to retrieve sessions",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(sessions)
+}
+
+func (ac *AuthController) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid session ID",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := ac.authService.RevokeSession(userID, sessionID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "NOT_FOUND",
+					Message:   "Session not found",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to revoke session",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (ac *AuthController) LogoutAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	if err := ac.authService.RevokeAllUserTokens(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to logout user",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (ac *AuthController) VerifyEmail(c *fiber.Ctx) error {
+	var req models.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	user, err := ac.authService.VerifyEmail(&req)
+	if err != nil {
+		return emailTokenError(c, err, "Failed to verify email")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+// ForgotPassword always answers 202 so that it does not reveal which emails
+// have an account
+func (ac *AuthController) ForgotPassword(c *fiber.Ctx) error {
+	var req models.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	if err := ac.authService.ForgotPassword(&req); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to request password reset",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).Send(nil)
+}
+
+func (ac *AuthController) ResetPassword(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	if err := ac.authService.ResetPassword(&req); err != nil {
+		return emailTokenError(c, err, "Failed to reset password")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// accountLocked answers a login attempt for an email that is locked out or
+// has to wait after failed attempts
+func accountLocked(c *fiber.Ctx, locked *services.AccountLockedError) error {
+	retryAfter := int64(math.Ceil(locked.RetryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+
+	return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:       "ACCOUNT_LOCKED",
+			Message:    fmt.Sprintf("Too many failed logins. Try again in %v", time.Duration(retryAfter)*time.Second),
+			RequestID:  c.Locals("requestid").(string),
+			RetryAfter: retryAfter,
+		},
+	})
+}
+
+// accountSuspended rejects logins and refreshes of suspended users
+func accountSuspended(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "ACCOUNT_SUSPENDED",
+			Message:   "This account has been suspended",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// emailTokenError maps errors of the email token flows to responses
+func emailTokenError(c *fiber.Ctx, err error, fallback string) error {
+	if strings.Contains(err.Error(), "invalid or expired token") {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INVALID_TOKEN",
+				Message:   "Token is invalid, expired or already used",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if strings.Contains(err.Error(), "already in use") {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Email already in use",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "INTERNAL_ERROR",
+			Message:   fallback,
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// clientInfo describes the client of a login or refresh request
+func clientInfo(c *fiber.Ctx) models.ClientInfo {
+	return models.ClientInfo{
+		UserAgent: strings.Clone(c.Get(fiber.HeaderUserAgent)),
+		IPAddress: strings.Clone(c.IP()),
+	}
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+type FolderController struct {
+	folderService *services.FolderService
+}
+
+func NewFolderController(folderService *services.FolderService) *FolderController {
+	return &FolderController{
+		folderService: folderService,
+	}
+}
+
+// ListFolders lists the folders of the workspace_id query parameter, or of
+// the personal workspace
+func (fc *FolderController) ListFolders(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, message := parseWorkspaceQuery(c)
+	if message != "" {
+		return workspaceValidationError(c, message)
+	}
+
+	folders, err := fc.folderService.ListFolders(userID, workspaceID)
+	if err != nil {
+		return folderError(c, err, "Failed to retrieve folders")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(folders)
+}
+
+func (fc *FolderController) CreateFolder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.FolderCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	folder, err := fc.folderService.CreateFolder(userID, &req)
+	if err != nil {
+		return folderError(c, err, "Failed to create folder")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(folder)
+}
+
+// UpdateFolder renames a folder or moves it to another parent
+func (fc *FolderController) UpdateFolder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	folderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid folder ID")
+	}
+
+	var req models.FolderUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	folder, err := fc.folderService.UpdateFolder(userID, folderID, &req)
+	if err != nil {
+		return folderError(c, err, "Failed to update folder")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(folder)
+}
+
+func (fc *FolderController) DeleteFolder(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	folderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid folder ID")
+	}
+
+	if err := fc.folderService.DeleteFolder(userID, folderID); err != nil {
+		return folderError(c, err, "Failed to delete folder")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// folderNotFound is the response for folders outside of the workspaces of
+// the user, or outside of the workspace of the link
+func folderNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "FOLDER_NOT_FOUND",
+			Message:   "Folder not found",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// folderError maps FolderService errors to responses
+func folderError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case strings.Contains(err.Error(), "workspace not found"):
+		return workspaceNotFound(c)
+	case strings.Contains(err.Error(), "insufficient workspace role"):
+		return insufficientWorkspaceRole(c)
+	case strings.Contains(err.Error(), "folder not found"):
+		return folderNotFound(c)
+	case strings.Contains(err.Error(), "invalid folder ID"):
+		return workspaceValidationError(c, "Invalid parent folder ID")
+	case strings.Contains(err.Error(), "invalid folder name"):
+		return workspaceValidationError(c, "Folder names must not be blank")
+	case strings.Contains(err.Error(), "into itself"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "A folder cannot be moved into itself or one of its subfolders",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "folder already exists"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "A folder with this name already exists in the parent folder",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   fallback,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/zhakazx/cleanshort/jwtkeys"
+)
+
+type JWKSController struct {
+	keys *jwtkeys.KeySet
+}
+
+func NewJWKSController(keys *jwtkeys.KeySet) *JWKSController {
+	return &JWKSController{
+		keys: keys,
+	}
+}
+
+// GetJWKS publishes the public keys that verify access tokens, so other
+// services can check tokens without calling the API
+func (kc *JWKSController) GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(kc.keys.JWKS())
+}
@@ -1,23 +1,38 @@
 package controllers
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/middleware"
 	"github.com/zhakazx/cleanshort/models"
 	"github.com/zhakazx/cleanshort/services"
 	"github.com/zhakazx/cleanshort/utils"
 )
 
 type LinkController struct {
-	linkService *services.LinkService
+	linkService   *services.LinkService
+	clickRecorder *services.ClickRecorder
+	cfg           *config.Config
+	unlockLimiter *middleware.RateLimiter
 }
 
-func NewLinkController(linkService *services.LinkService) *LinkController {
+func NewLinkController(linkService *services.LinkService, clickRecorder *services.ClickRecorder, cfg *config.Config, unlockLimiter *middleware.RateLimiter) *LinkController {
 	return &LinkController{
-		linkService: linkService,
+		linkService:   linkService,
+		clickRecorder: clickRecorder,
+		cfg:           cfg,
+		unlockLimiter: unlockLimiter,
 	}
 }
 
@@ -41,6 +56,36 @@ func (lc *LinkController) CreateLink(c *fiber.Ctx) error {
 
 	link, err := lc.linkService.CreateLink(userID, &req)
 	if err != nil {
+		if strings.Contains(err.Error(), "workspace not found") {
+			return workspaceNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "insufficient workspace role") {
+			return insufficientWorkspaceRole(c)
+		}
+
+		if strings.Contains(err.Error(), "email not verified") {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "EMAIL_NOT_VERIFIED",
+					Message:   "Verify your email address before creating links",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "folder not found") {
+			return folderNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "invalid tag name") {
+			return invalidTagName(c)
+		}
+
+		if strings.Contains(err.Error(), "tag already exists") {
+			return tagConflict(c)
+		}
+
 		if strings.Contains(err.Error(), "invalid short code") {
 			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 				Error: models.ErrorDetail{
@@ -61,6 +106,16 @@ func (lc *LinkController) CreateLink(c *fiber.Ctx) error {
 			})
 		}
 
+		if strings.Contains(err.Error(), "invalid expiration") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "expires_at must be in the future",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
 		if strings.Contains(err.Error(), "already exists") {
 			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
 				Error: models.ErrorDetail{
@@ -83,6 +138,200 @@ func (lc *LinkController) CreateLink(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(link)
 }
 
+// BulkCreateLinks creates links from a JSON array of create requests, a CSV
+// body or a CSV file uploaded as "file". With mode=atomic (the default)
+// nothing is created unless every row is valid; with mode=best_effort the
+// valid rows are created and the others reported.
+func (lc *LinkController) BulkCreateLinks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	mode := c.Query("mode", "atomic")
+	if mode != "atomic" && mode != "best_effort" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid mode. Allowed values: atomic, best_effort",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	// workspace_id applies to every row that does not name a workspace
+	var workspaceID *uuid.UUID
+	if workspaceIDStr := c.Query("workspace_id"); workspaceIDStr != "" {
+		id, err := uuid.Parse(workspaceIDStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Invalid workspace ID",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+		workspaceID = &id
+	}
+
+	var rows []services.BulkLinkRow
+	var err error
+
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEMultipartForm):
+		file, fileErr := c.FormFile("file")
+		if fileErr != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Upload the CSV file in the file field",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+		f, openErr := file.Open()
+		if openErr != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Invalid file upload",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+		defer f.Close()
+		rows, err = services.ParseLinkCSV(f)
+	case strings.HasPrefix(contentType, "text/csv"):
+		rows, err = services.ParseLinkCSV(bytes.NewReader(c.Body()))
+	default:
+		var reqs []models.LinkCreateRequest
+		if err := json.Unmarshal(c.Body(), &reqs); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Invalid request body: expected a JSON array of links",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+		rows = make([]services.BulkLinkRow, len(reqs))
+		for i := range reqs {
+			rows[i].Request = reqs[i]
+		}
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid CSV file: " + strings.TrimPrefix(err.Error(), "invalid CSV: "),
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if workspaceID != nil {
+		for i := range rows {
+			if rows[i].Request.WorkspaceID == nil {
+				rows[i].Request.WorkspaceID = workspaceID
+			}
+		}
+	}
+
+	results, err := lc.linkService.BulkCreateLinks(userID, rows, mode == "atomic")
+	if err != nil {
+		if strings.Contains(err.Error(), "email not verified") {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "EMAIL_NOT_VERIFIED",
+					Message:   "Verify your email address before creating links",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "no links") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Provide at least one link",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "too many links") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   fmt.Sprintf("At most %d links can be created per request", lc.cfg.BulkLinksMax),
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to create links",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	response := models.LinkBulkResponse{
+		Mode:    mode,
+		Results: make([]models.LinkBulkResult, len(results)),
+	}
+	for i, result := range results {
+		response.Results[i] = models.LinkBulkResult{Row: result.Row, Link: result.Link}
+		if result.Err != nil {
+			response.Results[i].Error = bulkRowError(result.Err)
+			response.Failed++
+		}
+		if result.Link != nil {
+			response.Created++
+		}
+	}
+
+	// 201 when every row was created, 422 when none was and 207 otherwise
+	status := fiber.StatusMultiStatus
+	switch {
+	case response.Failed == 0:
+		status = fiber.StatusCreated
+	case response.Created == 0:
+		status = fiber.StatusUnprocessableEntity
+	}
+
+	return c.Status(status).JSON(response)
+}
+
+// bulkRowError maps the error of one bulk row like CreateLink maps its errors
+func bulkRowError(err error) *models.ErrorDetail {
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid row: "):
+		return &models.ErrorDetail{Code: "VALIDATION_ERROR", Message: strings.TrimPrefix(err.Error(), "invalid row: ")}
+	case strings.Contains(err.Error(), "workspace not found"):
+		return &models.ErrorDetail{Code: "WORKSPACE_NOT_FOUND", Message: "Workspace not found"}
+	case strings.Contains(err.Error(), "folder not found"):
+		return &models.ErrorDetail{Code: "FOLDER_NOT_FOUND", Message: "Folder not found"}
+	case strings.Contains(err.Error(), "invalid tag name"):
+		return &models.ErrorDetail{Code: "VALIDATION_ERROR", Message: "Tag names must be 1 to 50 characters long and must not contain commas"}
+	case strings.Contains(err.Error(), "tag already exists"):
+		return &models.ErrorDetail{Code: "CONFLICT", Message: "A tag with this name already exists in the workspace"}
+	case strings.Contains(err.Error(), "insufficient workspace role"):
+		return &models.ErrorDetail{Code: "FORBIDDEN", Message: "Your role in this workspace does not allow this action"}
+	case strings.Contains(err.Error(), "invalid short code"):
+		return &models.ErrorDetail{Code: "VALIDATION_ERROR", Message: "Invalid short code format"}
+	case strings.Contains(err.Error(), "reserved"):
+		return &models.ErrorDetail{Code: "VALIDATION_ERROR", Message: "Short code is reserved"}
+	case strings.Contains(err.Error(), "invalid expiration"):
+		return &models.ErrorDetail{Code: "VALIDATION_ERROR", Message: "expires_at must be in the future"}
+	case strings.Contains(err.Error(), "already exists"):
+		return &models.ErrorDetail{Code: "CONFLICT", Message: "Short code already exists"}
+	default:
+		return &models.ErrorDetail{Code: "INTERNAL_ERROR", Message: "Failed to create link"}
+	}
+}
+
 func (lc *LinkController) GetLink(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
 
@@ -100,6 +349,10 @@ func (lc *LinkController) GetLink(c *fiber.Ctx) error {
 
 	link, err := lc.linkService.GetLink(userID, linkID)
 	if err != nil {
+		if strings.Contains(err.Error(), "insufficient workspace role") {
+			return insufficientWorkspaceRole(c)
+		}
+
 		if strings.Contains(err.Error(), "not found") {
 			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
 				Error: models.ErrorDetail{
@@ -154,6 +407,72 @@ func (lc *LinkController) UpdateLink(c *fiber.Ctx) error {
 
 	link, err := lc.linkService.UpdateLink(userID, linkID, &req)
 	if err != nil {
+		if strings.Contains(err.Error(), "disabled by an admin") {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "LINK_DISABLED",
+					Message:   "This link was disabled by an administrator and cannot be reactivated",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "invalid password") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "password must be at least 4 characters",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "invalid expiration") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "expires_at must be in the future",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "conflicting expiration") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Send either expires_at or clear_expires_at, not both",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "invalid tag name") {
+			return invalidTagName(c)
+		}
+
+		if strings.Contains(err.Error(), "tag already exists") {
+			return tagConflict(c)
+		}
+
+		if strings.Contains(err.Error(), "invalid folder ID") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Invalid folder ID",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "folder not found") {
+			return folderNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "insufficient workspace role") {
+			return insufficientWorkspaceRole(c)
+		}
+
 		if strings.Contains(err.Error(), "not found") {
 			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
 				Error: models.ErrorDetail{
@@ -193,6 +512,10 @@ func (lc *LinkController) DeleteLink(c *fiber.Ctx) error {
 
 	err = lc.linkService.DeleteLink(userID, linkID)
 	if err != nil {
+		if strings.Contains(err.Error(), "insufficient workspace role") {
+			return insufficientWorkspaceRole(c)
+		}
+
 		if strings.Contains(err.Error(), "not found") {
 			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
 				Error: models.ErrorDetail{
@@ -215,16 +538,89 @@ func (lc *LinkController) DeleteLink(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
 
+// TransferLink moves a link to another workspace
+func (lc *LinkController) TransferLink(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	linkID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid link ID",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	var req models.LinkTransferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	link, err := lc.linkService.TransferLink(userID, linkID, &req)
+	if err != nil {
+		if strings.Contains(err.Error(), "tag already exists") {
+			return tagConflict(c)
+		}
+
+		if strings.Contains(err.Error(), "insufficient workspace role") {
+			return insufficientWorkspaceRole(c)
+		}
+
+		if strings.Contains(err.Error(), "workspace not found") {
+			return workspaceNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "not found") {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "LINK_NOT_FOUND",
+					Message:   "Short link not found",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to transfer link",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(link)
+}
+
 func (lc *LinkController) ListLinks(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
 
+	params, message := parseLinkListParams(c)
+	if params == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   message,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
 	// Parse query parameters
 	limitStr := c.Query("limit", "20")
 	offsetStr := c.Query("offset", "0")
-	query := c.Query("query", "")
-	activeStr := c.Query("active", "")
-	sortBy := c.Query("sort_by", "created_at")
-	orderBy := c.Query("order_by", "desc")
 
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 1 || limit > 100 {
@@ -236,91 +632,609 @@ func (lc *LinkController) ListLinks(c *fiber.Ctx) error {
 		offset = 0
 	}
 
-	var active *bool
-	if activeStr != "" {
-		switch activeStr {
-		case "true":
-			activeVal := true
-			active = &activeVal
-		case "false":
-			activeVal := false
-			active = &activeVal
+	params.Limit = limit
+	params.Offset = offset
+	params.Cursor = c.Query("cursor")
+
+	if params.Cursor != "" && params.Offset > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Use either cursor or offset, not both",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	// The total is counted for offset paging unless turned off, and for
+	// cursor paging only on request
+	params.IncludeTotal = params.Cursor == ""
+	switch c.Query("include_total") {
+	case "true":
+		params.IncludeTotal = true
+	case "false":
+		params.IncludeTotal = false
+	}
+
+	links, err := lc.linkService.ListLinks(userID, *params)
+	if err != nil {
+		if strings.Contains(err.Error(), "workspace not found") {
+			return workspaceNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "folder not found") {
+			return folderNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "invalid tag name") {
+			return invalidTagName(c)
+		}
+
+		if strings.Contains(err.Error(), "invalid search") {
+			return invalidSearch(c, err)
+		}
+
+		if strings.Contains(err.Error(), "sorting by relevance") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Results sorted by relevance are paged with offset, not cursor",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		if strings.Contains(err.Error(), "invalid cursor") {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "VALIDATION_ERROR",
+					Message:   "Invalid cursor. A cursor only works with the sort_by and order_by it was returned for",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to retrieve links",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(links)
+}
+
+// ExportLinks streams every link matching the ListLinks filters as CSV or as
+// JSON Lines, without paging
+func (lc *LinkController) ExportLinks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid format. Allowed values: csv, jsonl",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	params, message := parseLinkListParams(c)
+	if params == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   message,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	export, err := lc.linkService.ExportLinks(userID, *params)
+	if err != nil {
+		if strings.Contains(err.Error(), "workspace not found") {
+			return workspaceNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "folder not found") {
+			return folderNotFound(c)
+		}
+
+		if strings.Contains(err.Error(), "invalid tag name") {
+			return invalidTagName(c)
+		}
+
+		if strings.Contains(err.Error(), "invalid search") {
+			return invalidSearch(c, err)
+		}
+
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   "Failed to export links",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	filename := fmt.Sprintf("links-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	}
+
+	requestID := c.Locals("requestid").(string)
+
+	// The status and headers are sent before the first link is read, so an
+	// error while streaming can only end the response early
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		var err error
+		if format == "csv" {
+			err = writeLinksCSV(w, export)
+		} else {
+			err = writeLinksJSONL(w, export)
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Printf("Link export %s for user %s stopped: %v", requestID, userID, err)
+		}
+	})
+
+	return nil
+}
+
+// linkExportColumns are the columns of CSV exports
+var linkExportColumns = []string{
+	"id", "workspace_id", "folder_id", "short_code", "short_url", "target_url", "title", "tags", "is_active",
+	"click_count", "last_clicked_at", "expires_at", "max_clicks", "password_protected",
+	"disabled_at", "created_at", "updated_at",
+}
+
+// linkExportFlushEvery is the number of links written between flushes, so
+// the client receives the export while it is being read
+const linkExportFlushEvery = 100
+
+func writeLinksCSV(w *bufio.Writer, export func(fn func(link *models.LinkResponse) error) error) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(linkExportColumns); err != nil {
+		return err
+	}
+
+	written := 0
+	err := export(func(link *models.LinkResponse) error {
+		record := []string{
+			link.ID.String(),
+			link.WorkspaceID.String(),
+			optionalUUID(link.FolderID),
+			link.ShortCode,
+			link.ShortURL,
+			link.TargetURL,
+			optionalString(link.Title),
+			strings.Join(link.Tags, ","),
+			strconv.FormatBool(link.IsActive),
+			strconv.FormatInt(link.ClickCount, 10),
+			optionalTime(link.LastClickedAt),
+			optionalTime(link.ExpiresAt),
+			optionalInt(link.MaxClicks),
+			strconv.FormatBool(link.PasswordProtected),
+			optionalTime(link.DisabledAt),
+			link.CreatedAt.UTC().Format(time.RFC3339),
+			link.UpdatedAt.UTC().Format(time.RFC3339),
+		}
+		for i, value := range record {
+			record[i] = csvCell(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+
+		written++
+		if written%linkExportFlushEvery == 0 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+			return w.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell keeps spreadsheets from reading a cell as a formula by prefixing
+// values that start like one with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func writeLinksJSONL(w *bufio.Writer, export func(fn func(link *models.LinkResponse) error) error) error {
+	encoder := json.NewEncoder(w)
+
+	written := 0
+	return export(func(link *models.LinkResponse) error {
+		if err := encoder.Encode(link); err != nil {
+			return err
+		}
+
+		written++
+		if written%linkExportFlushEvery == 0 {
+			return w.Flush()
 		}
+		return nil
+	})
+}
+
+func optionalString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func optionalUUID(value *uuid.UUID) string {
+	if value == nil {
+		return ""
+	}
+	return value.String()
+}
+
+func optionalTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+func optionalInt(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+// parseLinkListParams reads the link filters shared by ListLinks and
+// ExportLinks from the query string. When they are invalid, message is the
+// message of the validation error response.
+func parseLinkListParams(c *fiber.Ctx) (params *services.LinkListOptions, message string) {
+	params = &services.LinkListOptions{
+		Query:   c.Query("query", ""),
+		Search:  strings.TrimSpace(c.Query("search", "")),
+		OrderBy: c.Query("order_by", "desc"),
+	}
+
+	// Searches are ranked by relevance unless another sort is asked for
+	defaultSort := "created_at"
+	if params.Search != "" {
+		defaultSort = "relevance"
+	}
+	params.SortBy = c.Query("sort_by", defaultSort)
+
+	switch c.Query("active", "") {
+	case "true":
+		activeVal := true
+		params.Active = &activeVal
+	case "false":
+		activeVal := false
+		params.Active = &activeVal
 	}
 
 	// Validate sort_by parameter
 	validSortFields := map[string]bool{
-		"created_at":       true,
-		"updated_at":       true,
+		"created_at":      true,
+		"updated_at":      true,
 		"title":           true,
 		"short_code":      true,
 		"click_count":     true,
 		"last_clicked_at": true,
 	}
 
-	if !validSortFields[sortBy] {
+	if params.SortBy == "relevance" {
+		if params.Search == "" {
+			return nil, "sort_by relevance requires a search"
+		}
+	} else if !validSortFields[params.SortBy] {
+		return nil, "Invalid sort_by field. Allowed values: created_at, updated_at, title, short_code, click_count, last_clicked_at, relevance"
+	}
+
+	// Validate order_by parameter
+	if params.OrderBy != "asc" && params.OrderBy != "desc" {
+		return nil, "Invalid order_by value. Allowed values: asc, desc"
+	}
+
+	// Without workspace_id the links of every workspace of the user are listed
+	if workspaceIDStr := c.Query("workspace_id"); workspaceIDStr != "" {
+		id, err := uuid.Parse(workspaceIDStr)
+		if err != nil {
+			return nil, "Invalid workspace ID"
+		}
+		params.WorkspaceID = &id
+	}
+
+	// tag may be repeated or hold several tags separated by commas
+	for _, value := range c.Context().QueryArgs().PeekMulti("tag") {
+		for _, tag := range strings.Split(string(value), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				params.Tags = append(params.Tags, tag)
+			}
+		}
+	}
+
+	switch c.Query("tag_mode", "any") {
+	case "any":
+	case "all":
+		params.MatchAllTags = true
+	default:
+		return nil, "Invalid tag_mode value. Allowed values: any, all"
+	}
+
+	// folder_id=none lists the links outside of any folder
+	switch folderIDStr := c.Query("folder_id"); folderIDStr {
+	case "":
+	case "none":
+		params.Unfiled = true
+	default:
+		id, err := uuid.Parse(folderIDStr)
+		if err != nil {
+			return nil, "Invalid folder ID"
+		}
+		params.FolderID = &id
+		params.IncludeSubfolders = c.Query("include_subfolders") == "true"
+	}
+
+	return params, ""
+}
+
+// invalidSearch is the response for search queries that cannot be parsed
+func invalidSearch(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "VALIDATION_ERROR",
+			Message:   "Invalid search: " + strings.TrimPrefix(err.Error(), "invalid search: "),
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+func (lc *LinkController) ListClicks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	linkIDStr := c.Params("id")
+	linkID, err := uuid.Parse(linkIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid link ID",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error: models.ErrorDetail{
 				Code:      "VALIDATION_ERROR",
-				Message:   "Invalid sort_by field. Allowed values: created_at, updated_at, title, short_code, click_count, last_clicked_at",
+				Message:   "Invalid from value. Expected RFC 3339 timestamp",
 				RequestID: c.Locals("requestid").(string),
 			},
 		})
 	}
 
-	// Validate order_by parameter
-	if orderBy != "asc" && orderBy != "desc" {
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error: models.ErrorDetail{
 				Code:      "VALIDATION_ERROR",
-				Message:   "Invalid order_by value. Allowed values: asc, desc",
+				Message:   "Invalid to value. Expected RFC 3339 timestamp",
 				RequestID: c.Locals("requestid").(string),
 			},
 		})
 	}
 
-	links, err := lc.linkService.ListLinks(userID, limit, offset, query, active, sortBy, orderBy)
+	events, err := lc.linkService.ListClickEvents(userID, linkID, from, to, limit, offset)
 	if err != nil {
+		if strings.Contains(err.Error(), "insufficient workspace role") {
+			return insufficientWorkspaceRole(c)
+		}
+
+		if strings.Contains(err.Error(), "not found") {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "LINK_NOT_FOUND",
+					Message:   "Short link not found",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error: models.ErrorDetail{
 				Code:      "INTERNAL_ERROR",
-				Message:   "Failed to retrieve links",
+				Message:   "Failed to retrieve click events",
 				RequestID: c.Locals("requestid").(string),
 			},
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(links)
+	return c.Status(fiber.StatusOK).JSON(events)
 }
 
 func (lc *LinkController) RedirectLink(c *fiber.Ctx) error {
 	shortCode := c.Params("shortCode")
 
 	link, err := lc.linkService.GetLinkByShortCode(shortCode)
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
-			Error: models.ErrorDetail{
-				Code:      "LINK_NOT_FOUND",
-				Message:   "Short link not found",
-				RequestID: c.Locals("requestid").(string),
-			},
-		})
+	if err != nil || !link.IsActive {
+		return linkNotFound(c)
 	}
 
-	if !link.IsActive {
-		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
-			Error: models.ErrorDetail{
-				Code:      "LINK_NOT_FOUND",
-				Message:   "Short link not found",
-				RequestID: c.Locals("requestid").(string),
-			},
-		})
+	if link.IsExpired() || link.IsExhausted() {
+		return linkExpired(c)
+	}
+
+	if link.IsPasswordProtected() && !lc.linkService.VerifyUnlockToken(link, c.Cookies(unlockCookieName(shortCode))) {
+		return renderUnlockPage(c, fiber.StatusOK, shortCode, "")
+	}
+
+	return lc.followLink(c, link)
+}
+
+// UnlockLink handles the password form of a password-protected link
+func (lc *LinkController) UnlockLink(c *fiber.Ctx) error {
+	shortCode := c.Params("shortCode")
+
+	link, err := lc.linkService.GetLinkByShortCode(shortCode)
+	if err != nil || !link.IsActive {
+		return linkNotFound(c)
+	}
+
+	if link.IsExpired() || link.IsExhausted() {
+		return linkExpired(c)
+	}
+
+	if !link.IsPasswordProtected() {
+		return c.Redirect("/"+shortCode, fiber.StatusSeeOther)
+	}
+
+	// The attempt is counted before the password is checked, so parallel
+	// guesses cannot pass the limit together. Only wrong attempts count, so a
+	// correct one is refunded.
+	key := c.IP() + ":" + shortCode
+	if allowed, _, resetTime := lc.unlockLimiter.Allow(key); !allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(int64(time.Until(resetTime).Seconds())+1, 10))
+		return renderUnlockPage(c, fiber.StatusTooManyRequests, shortCode,
+			fmt.Sprintf("Too many attempts. Try again after %v", time.Until(resetTime).Round(time.Second)))
+	}
+
+	if !lc.linkService.CheckLinkPassword(link, c.FormValue("password")) {
+		return renderUnlockPage(c, fiber.StatusUnauthorized, shortCode, "Incorrect password")
 	}
+	lc.unlockLimiter.Refund(key)
 
-	// Record click (async)
-	go func() {
-		lc.linkService.RecordClick(shortCode)
-	}()
+	token, expiresAt := lc.linkService.IssueUnlockToken(link)
+	c.Cookie(&fiber.Cookie{
+		Name:     unlockCookieName(shortCode),
+		Value:    token,
+		Path:     "/" + shortCode,
+		Expires:  expiresAt,
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return lc.followLink(c, link)
+}
+
+// followLink records the click and redirects to the link's target URL
+func (lc *LinkController) followLink(c *fiber.Ctx, link *models.Link) error {
+	shortCode := link.ShortCode
+
+	// Request values are only valid for the lifetime of the handler, so they are
+	// copied before being handed to the click recorder
+	userAgent := strings.Clone(c.Get(fiber.HeaderUserAgent))
+	event := &models.ClickEvent{
+		LinkID:    link.ID,
+		ClickedAt: time.Now(),
+		Referrer:  strings.Clone(c.Get(fiber.HeaderReferer)),
+		UserAgent: userAgent,
+		Browser:   utils.ParseBrowser(userAgent),
+		Country:   countryCode(c.Get(lc.cfg.GeoCountryHeader)),
+		IPHash:    lc.linkService.HashIP(c.IP()),
+		RequestID: strings.Clone(c.Locals("requestid").(string)),
+	}
+
+	if link.MaxClicks != nil {
+		// Links with a click budget are counted before redirecting so the
+		// budget is enforced atomically
+		if err := lc.linkService.RecordClick(shortCode, event); err != nil {
+			if strings.Contains(err.Error(), "expired") {
+				return linkExpired(c)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "INTERNAL_ERROR",
+					Message:   "Failed to record click",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+	} else {
+		lc.clickRecorder.Record(shortCode, event)
+	}
 
 	return c.Redirect(link.TargetURL, fiber.StatusFound)
 }
+
+// parseTimeQuery parses an optional RFC 3339 query parameter
+func parseTimeQuery(c *fiber.Ctx, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// countryCode normalizes the geo header value to an ISO 3166-1 alpha-2 code
+func countryCode(value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) != 2 || value == "XX" {
+		return ""
+	}
+	return strings.Clone(value)
+}
+
+func unlockCookieName(shortCode string) string {
+	return "cs_unlock_" + shortCode
+}
+
+func linkNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "LINK_NOT_FOUND",
+			Message:   "Short link not found",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+func linkExpired(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "LINK_EXPIRED",
+			Message:   "Short link has expired",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+)
+
+// oidcCookieName is the cookie that carries the login state from the login
+// redirect to the callback
+const oidcCookieName = "cleanshort_oidc"
+
+type OIDCController struct {
+	authService *services.AuthService
+	cfg         *config.Config
+}
+
+func NewOIDCController(authService *services.AuthService, cfg *config.Config) *OIDCController {
+	return &OIDCController{
+		authService: authService,
+		cfg:         cfg,
+	}
+}
+
+// Login redirects to the OpenID provider
+func (oc *OIDCController) Login(c *fiber.Ctx) error {
+	authURL, loginState, err := oc.authService.StartOIDCLogin(c.Context())
+	if err != nil {
+		return oidcError(c, err)
+	}
+
+	oc.setCookie(c, loginState, time.Now().Add(10*time.Minute))
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// Callback finishes the login when the provider redirects back and responds
+// like a password login
+func (oc *OIDCController) Callback(c *fiber.Ctx) error {
+	loginState := c.Cookies(oidcCookieName)
+	// The login state is single use
+	oc.setCookie(c, "", time.Unix(0, 0))
+
+	if providerError := c.Query("error"); providerError != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "UNAUTHORIZED",
+				Message:   "Identity provider denied the login: " + providerError,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if c.Query("code") == "" || c.Query("state") == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "code and state are required",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	authResponse, challenge, err := oc.authService.CompleteOIDCLogin(c.Context(), c.Query("code"), c.Query("state"), loginState, clientInfo(c))
+	if err != nil {
+		return oidcError(c, err)
+	}
+
+	if challenge != nil {
+		return c.Status(fiber.StatusOK).JSON(challenge)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(authResponse)
+}
+
+func (oc *OIDCController) setCookie(c *fiber.Ctx, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcCookieName,
+		Value:    value,
+		Path:     "/api/v1/auth/oidc",
+		Expires:  expires,
+		Secure:   strings.HasPrefix(oc.cfg.OIDCRedirectURL, "https://"),
+		HTTPOnly: true,
+		// Lax, so the cookie is sent on the top-level redirect from the provider
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// oidcError maps errors of the OIDC login to responses
+func oidcError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	code := "INTERNAL_ERROR"
+	message := "Failed to log in with the identity provider"
+
+	if strings.Contains(err.Error(), "account suspended") {
+		return accountSuspended(c)
+	}
+
+	switch {
+	case strings.Contains(err.Error(), "not configured"):
+		status, code, message = fiber.StatusNotFound, "NOT_FOUND", "Single sign-on is not configured"
+	case strings.Contains(err.Error(), "provider unavailable"):
+		status, code, message = fiber.StatusBadGateway, "BAD_GATEWAY", "Identity provider is unavailable"
+	case strings.Contains(err.Error(), "invalid oidc state"):
+		status, code, message = fiber.StatusBadRequest, "INVALID_STATE", "Login state is missing, invalid or expired, please start again"
+	case strings.Contains(err.Error(), "oidc login failed"):
+		status, code, message = fiber.StatusUnauthorized, "UNAUTHORIZED", "Identity provider login could not be verified"
+	case strings.Contains(err.Error(), "not verified"):
+		status, code, message = fiber.StatusForbidden, "EMAIL_NOT_VERIFIED", "Identity provider did not return a verified email"
+	case strings.Contains(err.Error(), "unverified account"):
+		status, code, message = fiber.StatusConflict, "CONFLICT", "An unverified account uses this email. Verify it or reset its password first"
+	case strings.Contains(err.Error(), "already in use"):
+		status, code, message = fiber.StatusConflict, "CONFLICT", "Email is already in use"
+	}
+
+	return c.Status(status).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      code,
+			Message:   message,
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
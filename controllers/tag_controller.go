@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+type TagController struct {
+	tagService *services.TagService
+}
+
+func NewTagController(tagService *services.TagService) *TagController {
+	return &TagController{
+		tagService: tagService,
+	}
+}
+
+// ListTags lists the tags of the workspace_id query parameter, or of the
+// personal workspace
+func (tc *TagController) ListTags(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, message := parseWorkspaceQuery(c)
+	if message != "" {
+		return workspaceValidationError(c, message)
+	}
+
+	tags, err := tc.tagService.ListTags(userID, workspaceID)
+	if err != nil {
+		return tagError(c, err, "Failed to retrieve tags")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(tags)
+}
+
+func (tc *TagController) CreateTag(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.TagCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	tag, err := tc.tagService.CreateTag(userID, &req)
+	if err != nil {
+		return tagError(c, err, "Failed to create tag")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tag)
+}
+
+func (tc *TagController) UpdateTag(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	tagID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid tag ID")
+	}
+
+	var req models.TagUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	tag, err := tc.tagService.UpdateTag(userID, tagID, &req)
+	if err != nil {
+		return tagError(c, err, "Failed to update tag")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(tag)
+}
+
+func (tc *TagController) DeleteTag(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	tagID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid tag ID")
+	}
+
+	if err := tc.tagService.DeleteTag(userID, tagID); err != nil {
+		return tagError(c, err, "Failed to delete tag")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// parseWorkspaceQuery reads the optional workspace_id query parameter. When
+// it is invalid, message is the message of the validation error response.
+func parseWorkspaceQuery(c *fiber.Ctx) (workspaceID *uuid.UUID, message string) {
+	value := c.Query("workspace_id")
+	if value == "" {
+		return nil, ""
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, "Invalid workspace ID"
+	}
+	return &id, ""
+}
+
+// invalidTagName is the response for tag names that are empty, too long or
+// contain commas
+func invalidTagName(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "VALIDATION_ERROR",
+			Message:   "Tag names must be 1 to 50 characters long and must not contain commas",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// tagConflict is the response for tag names already used in the workspace
+func tagConflict(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "CONFLICT",
+			Message:   "A tag with this name already exists in the workspace",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// tagError maps TagService errors to responses
+func tagError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case strings.Contains(err.Error(), "workspace not found"):
+		return workspaceNotFound(c)
+	case strings.Contains(err.Error(), "insufficient workspace role"):
+		return insufficientWorkspaceRole(c)
+	case strings.Contains(err.Error(), "tag not found"):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "TAG_NOT_FOUND",
+				Message:   "Tag not found",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "invalid tag name"):
+		return invalidTagName(c)
+	case strings.Contains(err.Error(), "tag already exists"):
+		return tagConflict(c)
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   fallback,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+type TwoFactorController struct {
+	twoFactorService *services.TwoFactorService
+}
+
+func NewTwoFactorController(twoFactorService *services.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{
+		twoFactorService: twoFactorService,
+	}
+}
+
+func (tc *TwoFactorController) GetStatus(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	status, err := tc.twoFactorService.GetStatus(userID)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to retrieve two-factor status")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+func (tc *TwoFactorController) Enable(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.TwoFactorEnableRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	setup, err := tc.twoFactorService.Enable(userID, &req)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to enable two-factor authentication")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(setup)
+}
+
+func (tc *TwoFactorController) Confirm(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.TwoFactorConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	codes, err := tc.twoFactorService.Confirm(userID, &req)
+	if err != nil {
+		return twoFactorError(c, err, "Failed to confirm two-factor authentication")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(codes)
+}
+
+func (tc *TwoFactorController) Disable(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.TwoFactorDisableRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	if err := tc.twoFactorService.Disable(userID, &req); err != nil {
+		return twoFactorError(c, err, "Failed to disable two-factor authentication")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// twoFactorError maps TwoFactorService errors to responses
+func twoFactorError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case strings.Contains(err.Error(), "invalid two-factor code"):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INVALID_2FA_CODE",
+				Message:   "Two-factor code is invalid or was already used",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "already enabled"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Two-factor authentication is already enabled",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "not set up"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Call the enable endpoint first",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "not enabled"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Two-factor authentication is not enabled",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	default:
+		return userError(c, err, fallback)
+	}
+}
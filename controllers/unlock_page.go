@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var unlockPageTemplate = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <meta name="robots" content="noindex">
+  <title>Password required</title>
+  <style>
+    body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f5f5f5; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+    form { background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 2px 8px rgba(0, 0, 0, 0.1); width: 100%; max-width: 320px; }
+    h1 { font-size: 1.25rem; margin: 0 0 1rem; }
+    input { width: 100%; padding: 0.5rem; margin-bottom: 1rem; box-sizing: border-box; }
+    button { width: 100%; padding: 0.5rem; }
+    .error { color: #c0392b; margin-bottom: 1rem; }
+  </style>
+</head>
+<body>
+  <form method="POST" action="/{{.ShortCode}}">
+    <h1>This link is password protected</h1>
+    {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+    <input type="password" name="password" placeholder="Password" autofocus required>
+    <button type="submit">Continue</button>
+  </form>
+</body>
+</html>
+`))
+
+type unlockPageData struct {
+	ShortCode string
+	Error     string
+}
+
+func renderUnlockPage(c *fiber.Ctx, status int, shortCode, errorMessage string) error {
+	var buf bytes.Buffer
+	if err := unlockPageTemplate.Execute(&buf, unlockPageData{ShortCode: shortCode, Error: errorMessage}); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Type("html", "utf-8")
+	return c.Status(status).Send(buf.Bytes())
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+type UserController struct {
+	userService *services.UserService
+}
+
+func NewUserController(userService *services.UserService) *UserController {
+	return &UserController{
+		userService: userService,
+	}
+}
+
+func (uc *UserController) GetMe(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	me, err := uc.userService.GetMe(userID)
+	if err != nil {
+		return userError(c, err, "Failed to retrieve user")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(me)
+}
+
+func (uc *UserController) UpdateMe(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.MeUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	user, err := uc.userService.UpdateEmail(userID, &req)
+	if err != nil {
+		return userError(c, err, "Failed to update user")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+func (uc *UserController) ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.PasswordChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	var currentSessionID *uuid.UUID
+	if sessionID, ok := c.Locals("sessionID").(uuid.UUID); ok {
+		currentSessionID = &sessionID
+	}
+
+	if err := uc.userService.ChangePassword(userID, currentSessionID, &req); err != nil {
+		return userError(c, err, "Failed to change password")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (uc *UserController) DeleteMe(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.AccountDeleteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "VALIDATION_ERROR",
+				Message:   "Invalid request body",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	if err := uc.userService.DeleteAccount(userID, &req); err != nil {
+		return userError(c, err, "Failed to delete account")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (uc *UserController) ResendVerificationEmail(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	if err := uc.userService.ResendVerificationEmail(userID); err != nil {
+		if strings.Contains(err.Error(), "already verified") {
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "CONFLICT",
+					Message:   "Email is already verified",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return userError(c, err, "Failed to send verification email")
+	}
+
+	return c.Status(fiber.StatusAccepted).Send(nil)
+}
+
+// userError maps UserService errors to responses
+func userError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case strings.Contains(err.Error(), "invalid password"):
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "FORBIDDEN",
+				Message:   "Password is incorrect",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "already in use"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Email already in use",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "sole owner"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "You are the only owner of a shared workspace. Make another member an owner first.",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "user not found"):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "NOT_FOUND",
+				Message:   "User not found",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   fallback,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+}
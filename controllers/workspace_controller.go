@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/services"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+type WorkspaceController struct {
+	workspaceService *services.WorkspaceService
+}
+
+func NewWorkspaceController(workspaceService *services.WorkspaceService) *WorkspaceController {
+	return &WorkspaceController{
+		workspaceService: workspaceService,
+	}
+}
+
+func (wc *WorkspaceController) ListWorkspaces(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaces, err := wc.workspaceService.ListWorkspaces(userID)
+	if err != nil {
+		return workspaceError(c, err, "Failed to retrieve workspaces")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(workspaces)
+}
+
+func (wc *WorkspaceController) CreateWorkspace(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.WorkspaceCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	workspace, err := wc.workspaceService.CreateWorkspace(userID, &req)
+	if err != nil {
+		return workspaceError(c, err, "Failed to create workspace")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(workspace)
+}
+
+func (wc *WorkspaceController) GetWorkspace(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	workspace, err := wc.workspaceService.GetWorkspace(userID, workspaceID)
+	if err != nil {
+		return workspaceError(c, err, "Failed to retrieve workspace")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(workspace)
+}
+
+func (wc *WorkspaceController) UpdateWorkspace(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	var req models.WorkspaceUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	workspace, err := wc.workspaceService.UpdateWorkspace(userID, workspaceID, &req)
+	if err != nil {
+		return workspaceError(c, err, "Failed to update workspace")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(workspace)
+}
+
+func (wc *WorkspaceController) DeleteWorkspace(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	if err := wc.workspaceService.DeleteWorkspace(userID, workspaceID); err != nil {
+		return workspaceError(c, err, "Failed to delete workspace")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (wc *WorkspaceController) ListMembers(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	members, err := wc.workspaceService.ListMembers(userID, workspaceID)
+	if err != nil {
+		return workspaceError(c, err, "Failed to retrieve members")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(members)
+}
+
+func (wc *WorkspaceController) UpdateMember(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	memberID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid user ID")
+	}
+
+	var req models.WorkspaceMemberUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	if err := wc.workspaceService.UpdateMemberRole(userID, workspaceID, memberID, &req); err != nil {
+		return workspaceError(c, err, "Failed to update member")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// RemoveMember removes a member, or lets the current user leave when the
+// user ID is their own
+func (wc *WorkspaceController) RemoveMember(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	memberID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid user ID")
+	}
+
+	if err := wc.workspaceService.RemoveMember(userID, workspaceID, memberID); err != nil {
+		return workspaceError(c, err, "Failed to remove member")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (wc *WorkspaceController) CreateInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	var req models.WorkspaceInvitationCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	invitation, err := wc.workspaceService.CreateInvitation(userID, workspaceID, &req)
+	if err != nil {
+		return workspaceError(c, err, "Failed to create invitation")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+func (wc *WorkspaceController) ListInvitations(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	invitations, err := wc.workspaceService.ListInvitations(userID, workspaceID)
+	if err != nil {
+		return workspaceError(c, err, "Failed to retrieve invitations")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(invitations)
+}
+
+func (wc *WorkspaceController) RevokeInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	workspaceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid workspace ID")
+	}
+
+	invitationID, err := uuid.Parse(c.Params("invitationId"))
+	if err != nil {
+		return workspaceValidationError(c, "Invalid invitation ID")
+	}
+
+	if err := wc.workspaceService.RevokeInvitation(userID, workspaceID, invitationID); err != nil {
+		return workspaceError(c, err, "Failed to revoke invitation")
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (wc *WorkspaceController) AcceptInvitation(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+
+	var req models.WorkspaceInvitationAcceptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return workspaceValidationError(c, "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.HandleValidationError(c, err)
+	}
+
+	workspace, err := wc.workspaceService.AcceptInvitation(userID, &req)
+	if err != nil {
+		return workspaceError(c, err, "Failed to accept invitation")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(workspace)
+}
+
+func workspaceValidationError(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "VALIDATION_ERROR",
+			Message:   message,
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// insufficientWorkspaceRole is the response for members whose workspace role
+// does not allow the request
+func insufficientWorkspaceRole(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "FORBIDDEN",
+			Message:   "Your role in this workspace does not allow this action",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// workspaceNotFound is the response for workspaces the user is not a member of
+func workspaceNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "WORKSPACE_NOT_FOUND",
+			Message:   "Workspace not found",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
+
+// workspaceError maps WorkspaceService errors to responses
+func workspaceError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case strings.Contains(err.Error(), "workspace not found"):
+		return workspaceNotFound(c)
+	case strings.Contains(err.Error(), "insufficient workspace role"):
+		return insufficientWorkspaceRole(c)
+	case strings.Contains(err.Error(), "member not found"):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "NOT_FOUND",
+				Message:   "Member not found",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "invitation not found"):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "NOT_FOUND",
+				Message:   "Invitation not found",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "workspace has links"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Move or delete the links of the workspace first",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "must keep an owner"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "A workspace must keep at least one owner",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "personal workspaces"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "Personal workspaces cannot be shared or deleted",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "already a member"):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "CONFLICT",
+				Message:   "User is already a member of the workspace",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "invalid or expired invitation"):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INVALID_TOKEN",
+				Message:   "Invitation is invalid, expired or already used",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "another email"):
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "FORBIDDEN",
+				Message:   "The invitation was sent to another email address",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	case strings.Contains(err.Error(), "email not verified"):
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "EMAIL_NOT_VERIFIED",
+				Message:   "Verify your email address before accepting invitations",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "INTERNAL_ERROR",
+				Message:   fallback,
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+}
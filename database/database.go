@@ -1,18 +1,72 @@
 package database
 
 import (
+	"database/sql"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
-	"github.com/zhakazx/cleanshort/models"
+	"github.com/glebarez/sqlite"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/repositories"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// Connect establishes a connection to the PostgreSQL database
-func Connect(dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+// Open returns the store for the configured DB_DRIVER. SQL backends are
+// migrated unless DB_AUTO_MIGRATE is off; the memory backend starts empty.
+func Open(cfg *config.Config) (*repositories.Store, error) {
+	switch cfg.DatabaseDriver {
+	case "memory":
+		log.Println("Using in-memory storage, data will be lost on exit")
+		return repositories.NewMemoryStore(), nil
+	case "postgres", "sqlite":
+		db, err := Connect(cfg.DatabaseDriver, cfg.DatabaseDSN)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.DatabaseAutoMigrate {
+			log.Println("Running database migrations...")
+			if err := Migrate(db); err != nil {
+				return nil, fmt.Errorf("failed to run migrations: %w", err)
+			}
+		} else if pending, err := PendingMigrations(db); err != nil {
+			return nil, err
+		} else if pending > 0 {
+			log.Printf("Database has %d pending migrations, run \"cleanshort migrate up\"", pending)
+		}
+
+		return repositories.NewGormStore(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q", cfg.DatabaseDriver)
+	}
+}
+
+// Connect establishes a connection to a PostgreSQL or SQLite database
+func Connect(driver, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case "postgres":
+		dialector = postgres.Open(dsn)
+	case "sqlite":
+		sqlDB, err := sql.Open(sqlite.DriverName, sqliteDSN(dsn))
+		if err != nil {
+			return nil, err
+		}
+		dialector = &sqlite.Dialector{Conn: &utcConnPool{db: sqlDB}}
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Info),
+		TranslateError: true,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
 	})
 	if err != nil {
 		return nil, err
@@ -24,54 +78,37 @@ func Connect(dsn string) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
+	if driver == "sqlite" {
+		// SQLite allows a single writer; one connection avoids "database is
+		// locked" errors and keeps ":memory:" databases on one connection
+		sqlDB.SetMaxOpenConns(1)
+	} else {
+		sqlDB.SetMaxIdleConns(10)
+		sqlDB.SetMaxOpenConns(100)
+	}
 
 	return db, nil
 }
 
-// Migrate runs the database migrations
-func Migrate(db *gorm.DB) error {
-	log.Println("Running database migrations...")
-
-	// Enable UUID extension
-	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"").Error; err != nil {
-		return err
+// sqliteDSN enables foreign keys (needed for ON DELETE CASCADE) and a busy
+// timeout unless the DSN already sets pragmas, and writes times in the format
+// the SQLite date functions read unless the DSN sets another
+func sqliteDSN(dsn string) string {
+	var params []string
+	if !strings.Contains(dsn, "_pragma=") {
+		params = append(params, "_pragma=foreign_keys(1)", "_pragma=busy_timeout(5000)")
 	}
-
-	// Auto-migrate models
-	err := db.AutoMigrate(
-		&models.User{},
-		&models.Link{},
-		&models.RefreshToken{},
-	)
-	if err != nil {
-		return err
+	if !strings.Contains(dsn, "_time_format=") {
+		params = append(params, "_time_format=sqlite")
 	}
-
-	if err := createIndexes(db); err != nil {
-		return err
+	if len(params) == 0 {
+		return dsn
 	}
 
-	log.Println("Database migrations completed successfully")
-	return nil
-}
-
-// createIndexes creates additional database indexes for performance
-func createIndexes(db *gorm.DB) error {
-	// Index for links table
-	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_links_user_code ON links(user_id, short_code)").Error; err != nil {
-		return err
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
 	}
 
-	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_links_short_code ON links(short_code)").Error; err != nil {
-		return err
-	}
-
-	// Index for refresh tokens
-	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id)").Error; err != nil {
-		return err
-	}
-
-	return nil
-}
\ No newline at end of file
+	return dsn + separator + strings.Join(params, "&")
+}
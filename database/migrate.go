@@ -0,0 +1,266 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLockKey identifies the PostgreSQL advisory lock held while
+// migrating, so replicas starting at the same time apply migrations one by one
+const migrationLockKey = 7_246_381_905
+
+// Migration is one versioned, reversible schema change. Up and Down run in a
+// transaction together with the schema_migrations bookkeeping.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// dialectSQL holds the statements of a migration step for each supported
+// dialect
+type dialectSQL struct {
+	Postgres []string
+	SQLite   []string
+}
+
+// sameSQL is a dialectSQL whose statements work on every dialect
+func sameSQL(statements ...string) dialectSQL {
+	return dialectSQL{Postgres: statements, SQLite: statements}
+}
+
+func (d dialectSQL) exec(tx *gorm.DB) error {
+	statements := d.Postgres
+	if tx.Dialector.Name() == "sqlite" {
+		statements = d.SQLite
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LatestVersion returns the version of the newest known migration
+func LatestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrate applies all pending migrations
+func Migrate(db *gorm.DB) error {
+	return MigrateTo(db, LatestVersion())
+}
+
+// MigrateDown rolls back the given number of applied migrations, newest first
+func MigrateDown(db *gorm.DB, steps int) error {
+	return withMigrationLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			if _, ok := applied[migrations[i].Version]; !ok {
+				continue
+			}
+			if err := runDown(conn, migrations[i]); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrateTo applies or rolls back migrations until the schema is at the given
+// version. Version 0 rolls back every migration.
+func MigrateTo(db *gorm.DB, version int) error {
+	if version != 0 && findMigration(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return withMigrationLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for v := range applied {
+			if findMigration(v) == nil {
+				if v > version {
+					return fmt.Errorf("database has migration %d applied, which this build does not know", v)
+				}
+				log.Printf("Database has unknown migration %d applied", v)
+			}
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; ok && m.Version > version {
+				if err := runDown(conn, m); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; !ok && m.Version <= version {
+				if err := runUp(conn, m); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatuses lists every known migration and when it was applied
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// PendingMigrations counts the known migrations that are not applied yet
+func PendingMigrations(db *gorm.DB) (int, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// withMigrationLock runs fn on a single connection while holding the
+// migration lock. PostgreSQL uses a session advisory lock; SQLite allows only
+// one writer, so it needs no extra locking.
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		// Connection hands out a single statement; start a session so that
+		// conditions do not leak from one query into the next
+		conn = conn.Session(&gorm.Session{})
+
+		if conn.Dialector.Name() == "postgres" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+			defer func() {
+				if err := conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey).Error; err != nil {
+					log.Println("Failed to release migration lock:", err)
+				}
+			}()
+		}
+
+		if err := ensureMigrationsTable(conn); err != nil {
+			return err
+		}
+
+		return fn(conn)
+	})
+}
+
+func ensureMigrationsTable(db *gorm.DB) error {
+	return sameSQL(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).exec(db)
+}
+
+func appliedVersions(db *gorm.DB) (map[int]time.Time, error) {
+	var rows []schemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+func runUp(db *gorm.DB, m Migration) error {
+	log.Printf("Applying migration %d %s", m.Version, m.Name)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d %s failed: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+func runDown(db *gorm.DB, m Migration) error {
+	log.Printf("Rolling back migration %d %s", m.Version, m.Name)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Where("version = ?", m.Version).Delete(&schemaMigration{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("rolling back migration %d %s failed: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+func findMigration(version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,568 @@
+package database
+
+import (
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+// migrations lists every schema change in version order. Append new
+// migrations at the end and never edit one that has been released.
+var migrations = []Migration{
+	{
+		// The baseline adopts databases created by the former AutoMigrate
+		// setup: every statement is a no-op when the object already exists.
+		Version: 1,
+		Name:    "baseline",
+		Up: dialectSQL{
+			Postgres: []string{
+				`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`,
+				`CREATE TABLE IF NOT EXISTS users (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					email TEXT NOT NULL,
+					password TEXT NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+				`CREATE TABLE IF NOT EXISTS links (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					short_code VARCHAR(32) NOT NULL,
+					target_url TEXT NOT NULL,
+					title TEXT,
+					is_active BOOLEAN NOT NULL DEFAULT true,
+					click_count BIGINT NOT NULL DEFAULT 0,
+					last_clicked_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`ALTER TABLE links
+					ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ,
+					ADD COLUMN IF NOT EXISTS max_clicks BIGINT,
+					ADD COLUMN IF NOT EXISTS password_hash TEXT`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_links_short_code ON links(short_code)`,
+				`CREATE INDEX IF NOT EXISTS idx_links_user ON links(user_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_links_user_code ON links(user_id, short_code)`,
+				`CREATE TABLE IF NOT EXISTS refresh_tokens (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					token_hash TEXT NOT NULL,
+					revoked BOOLEAN NOT NULL DEFAULT false,
+					expires_at TIMESTAMPTZ NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash)`,
+				`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id)`,
+				`CREATE TABLE IF NOT EXISTS click_events (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					link_id UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+					clicked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					referrer TEXT NOT NULL DEFAULT '',
+					user_agent TEXT NOT NULL DEFAULT '',
+					browser VARCHAR(32) NOT NULL DEFAULT '',
+					country VARCHAR(2) NOT NULL DEFAULT '',
+					ip_hash VARCHAR(64) NOT NULL,
+					request_id VARCHAR(64) NOT NULL DEFAULT ''
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_click_events_link_time ON click_events(link_id, clicked_at)`,
+			},
+			// SQLite keeps timestamps as text; the defaults use the same
+			// layout GORM writes so that values compare correctly
+			SQLite: []string{
+				`CREATE TABLE IF NOT EXISTS users (
+					id TEXT PRIMARY KEY,
+					email TEXT NOT NULL,
+					password TEXT NOT NULL,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+				`CREATE TABLE IF NOT EXISTS links (
+					id TEXT PRIMARY KEY,
+					user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					short_code VARCHAR(32) NOT NULL,
+					target_url TEXT NOT NULL,
+					title TEXT,
+					is_active BOOLEAN NOT NULL DEFAULT 1,
+					click_count INTEGER NOT NULL DEFAULT 0,
+					last_clicked_at DATETIME,
+					expires_at DATETIME,
+					max_clicks INTEGER,
+					password_hash TEXT,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_links_short_code ON links(short_code)`,
+				`CREATE INDEX IF NOT EXISTS idx_links_user ON links(user_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_links_user_code ON links(user_id, short_code)`,
+				`CREATE TABLE IF NOT EXISTS refresh_tokens (
+					id TEXT PRIMARY KEY,
+					user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					token_hash TEXT NOT NULL,
+					revoked BOOLEAN NOT NULL DEFAULT 0,
+					expires_at DATETIME NOT NULL,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash)`,
+				`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id)`,
+				`CREATE TABLE IF NOT EXISTS click_events (
+					id TEXT PRIMARY KEY,
+					link_id TEXT NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+					clicked_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					referrer TEXT NOT NULL DEFAULT '',
+					user_agent TEXT NOT NULL DEFAULT '',
+					browser VARCHAR(32) NOT NULL DEFAULT '',
+					country VARCHAR(2) NOT NULL DEFAULT '',
+					ip_hash VARCHAR(64) NOT NULL,
+					request_id VARCHAR(64) NOT NULL DEFAULT ''
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_click_events_link_time ON click_events(link_id, clicked_at)`,
+			},
+		}.exec,
+		Down: sameSQL(
+			`DROP TABLE IF EXISTS click_events`,
+			`DROP TABLE IF EXISTS refresh_tokens`,
+			`DROP TABLE IF EXISTS links`,
+			`DROP TABLE IF EXISTS users`,
+		).exec,
+	},
+	{
+		Version: 2,
+		Name:    "create_api_keys",
+		Up: dialectSQL{
+			Postgres: []string{
+				`CREATE TABLE api_keys (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					name TEXT NOT NULL,
+					prefix VARCHAR(16) NOT NULL,
+					key_hash TEXT NOT NULL,
+					last_used_at TIMESTAMPTZ,
+					revoked_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys(key_hash)`,
+				`CREATE INDEX idx_api_keys_user ON api_keys(user_id)`,
+			},
+			SQLite: []string{
+				`CREATE TABLE api_keys (
+					id TEXT PRIMARY KEY,
+					user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					name TEXT NOT NULL,
+					prefix VARCHAR(16) NOT NULL,
+					key_hash TEXT NOT NULL,
+					last_used_at DATETIME,
+					revoked_at DATETIME,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys(key_hash)`,
+				`CREATE INDEX idx_api_keys_user ON api_keys(user_id)`,
+			},
+		}.exec,
+		Down: sameSQL(`DROP TABLE IF EXISTS api_keys`).exec,
+	},
+	{
+		// Keys created before scopes existed keep full access
+		Version: 3,
+		Name:    "add_api_key_scopes",
+		Up: sameSQL(
+			`ALTER TABLE api_keys ADD COLUMN scopes TEXT NOT NULL DEFAULT ''`,
+			`UPDATE api_keys SET scopes = 'links:read links:write links:delete stats:read api_keys:manage'`,
+		).exec,
+		Down: sameSQL(`ALTER TABLE api_keys DROP COLUMN scopes`).exec,
+	},
+	{
+		// Existing tokens each start their own family
+		Version: 4,
+		Name:    "add_refresh_token_families",
+		Up: dialectSQL{
+			Postgres: []string{
+				`ALTER TABLE refresh_tokens ADD COLUMN family_id UUID`,
+				`UPDATE refresh_tokens SET family_id = id`,
+				`ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL`,
+				`CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id)`,
+			},
+			SQLite: []string{
+				`ALTER TABLE refresh_tokens ADD COLUMN family_id TEXT NOT NULL DEFAULT ''`,
+				`UPDATE refresh_tokens SET family_id = id`,
+				`CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id)`,
+			},
+		}.exec,
+		Down: sameSQL(
+			`DROP INDEX IF EXISTS idx_refresh_tokens_family`,
+			`ALTER TABLE refresh_tokens DROP COLUMN family_id`,
+		).exec,
+	},
+	{
+		Version: 5,
+		Name:    "add_refresh_token_client_info",
+		Up: dialectSQL{
+			Postgres: []string{
+				`ALTER TABLE refresh_tokens
+					ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+					ADD COLUMN ip_address VARCHAR(64) NOT NULL DEFAULT '',
+					ADD COLUMN last_used_at TIMESTAMPTZ`,
+			},
+			SQLite: []string{
+				`ALTER TABLE refresh_tokens ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE refresh_tokens ADD COLUMN ip_address VARCHAR(64) NOT NULL DEFAULT ''`,
+				`ALTER TABLE refresh_tokens ADD COLUMN last_used_at DATETIME`,
+			},
+		}.exec,
+		Down: dialectSQL{
+			Postgres: []string{
+				`ALTER TABLE refresh_tokens
+					DROP COLUMN user_agent,
+					DROP COLUMN ip_address,
+					DROP COLUMN last_used_at`,
+			},
+			SQLite: []string{
+				`ALTER TABLE refresh_tokens DROP COLUMN user_agent`,
+				`ALTER TABLE refresh_tokens DROP COLUMN ip_address`,
+				`ALTER TABLE refresh_tokens DROP COLUMN last_used_at`,
+			},
+		}.exec,
+	},
+	{
+		Version: 6,
+		Name:    "add_user_email_verified_at",
+		Up: dialectSQL{
+			Postgres: []string{`ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ`},
+			SQLite:   []string{`ALTER TABLE users ADD COLUMN email_verified_at DATETIME`},
+		}.exec,
+		Down: sameSQL(`ALTER TABLE users DROP COLUMN email_verified_at`).exec,
+	},
+	{
+		Version: 7,
+		Name:    "create_email_tokens",
+		Up: dialectSQL{
+			Postgres: []string{
+				`CREATE TABLE email_tokens (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					purpose VARCHAR(32) NOT NULL,
+					email TEXT NOT NULL,
+					token_hash TEXT NOT NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					used_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE UNIQUE INDEX idx_email_tokens_token_hash ON email_tokens(token_hash)`,
+				`CREATE INDEX idx_email_tokens_user ON email_tokens(user_id)`,
+			},
+			SQLite: []string{
+				`CREATE TABLE email_tokens (
+					id TEXT PRIMARY KEY,
+					user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					purpose VARCHAR(32) NOT NULL,
+					email TEXT NOT NULL,
+					token_hash TEXT NOT NULL,
+					expires_at DATETIME NOT NULL,
+					used_at DATETIME,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE UNIQUE INDEX idx_email_tokens_token_hash ON email_tokens(token_hash)`,
+				`CREATE INDEX idx_email_tokens_user ON email_tokens(user_id)`,
+			},
+		}.exec,
+		Down: sameSQL(`DROP TABLE IF EXISTS email_tokens`).exec,
+	},
+	{
+		Version: 8,
+		Name:    "add_two_factor",
+		Up: dialectSQL{
+			Postgres: []string{
+				`ALTER TABLE users
+					ADD COLUMN totp_secret TEXT,
+					ADD COLUMN totp_enabled_at TIMESTAMPTZ,
+					ADD COLUMN totp_last_step BIGINT`,
+				`CREATE TABLE recovery_codes (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					code_hash TEXT NOT NULL,
+					used_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id)`,
+			},
+			SQLite: []string{
+				`ALTER TABLE users ADD COLUMN totp_secret TEXT`,
+				`ALTER TABLE users ADD COLUMN totp_enabled_at DATETIME`,
+				`ALTER TABLE users ADD COLUMN totp_last_step BIGINT`,
+				`CREATE TABLE recovery_codes (
+					id TEXT PRIMARY KEY,
+					user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					code_hash TEXT NOT NULL,
+					used_at DATETIME,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id)`,
+			},
+		}.exec,
+		Down: sameSQL(
+			`DROP TABLE IF EXISTS recovery_codes`,
+			`ALTER TABLE users DROP COLUMN totp_last_step`,
+			`ALTER TABLE users DROP COLUMN totp_enabled_at`,
+			`ALTER TABLE users DROP COLUMN totp_secret`,
+		).exec,
+	},
+	{
+		Version: 9,
+		Name:    "add_roles_and_suspension",
+		Up: dialectSQL{
+			Postgres: []string{
+				`ALTER TABLE users
+					ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+					ADD COLUMN suspended_at TIMESTAMPTZ`,
+				`ALTER TABLE links ADD COLUMN disabled_at TIMESTAMPTZ`,
+			},
+			SQLite: []string{
+				`ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user'`,
+				`ALTER TABLE users ADD COLUMN suspended_at DATETIME`,
+				`ALTER TABLE links ADD COLUMN disabled_at DATETIME`,
+			},
+		}.exec,
+		Down: sameSQL(
+			`ALTER TABLE links DROP COLUMN disabled_at`,
+			`ALTER TABLE users DROP COLUMN suspended_at`,
+			`ALTER TABLE users DROP COLUMN role`,
+		).exec,
+	},
+	{
+		// Every user gets a personal workspace with the user's ID, which takes
+		// over their links
+		Version: 10,
+		Name:    "create_workspaces",
+		Up: dialectSQL{
+			Postgres: []string{
+				`CREATE TABLE workspaces (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					name TEXT NOT NULL,
+					personal BOOLEAN NOT NULL DEFAULT FALSE,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE TABLE workspace_members (
+					workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					role VARCHAR(16) NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					PRIMARY KEY (workspace_id, user_id)
+				)`,
+				`CREATE INDEX idx_workspace_members_user ON workspace_members(user_id)`,
+				`CREATE TABLE workspace_invitations (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					email TEXT NOT NULL,
+					role VARCHAR(16) NOT NULL,
+					token_hash TEXT NOT NULL,
+					invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					accepted_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE UNIQUE INDEX idx_workspace_invitations_token_hash ON workspace_invitations(token_hash)`,
+				`CREATE INDEX idx_workspace_invitations_workspace ON workspace_invitations(workspace_id)`,
+				`INSERT INTO workspaces (id, name, personal, created_at, updated_at)
+					SELECT id, 'Personal', TRUE, created_at, created_at FROM users`,
+				`INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+					SELECT id, id, 'owner', created_at FROM users`,
+				`ALTER TABLE links ADD COLUMN workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE`,
+				`UPDATE links SET workspace_id = user_id`,
+				`ALTER TABLE links ALTER COLUMN workspace_id SET NOT NULL`,
+				`CREATE INDEX idx_links_workspace ON links(workspace_id)`,
+			},
+			// SQLite cannot add a NOT NULL column without a default, so
+			// links.workspace_id stays nullable there
+			SQLite: []string{
+				`CREATE TABLE workspaces (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL,
+					personal BOOLEAN NOT NULL DEFAULT 0,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE TABLE workspace_members (
+					workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					role VARCHAR(16) NOT NULL,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					PRIMARY KEY (workspace_id, user_id)
+				)`,
+				`CREATE INDEX idx_workspace_members_user ON workspace_members(user_id)`,
+				`CREATE TABLE workspace_invitations (
+					id TEXT PRIMARY KEY,
+					workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					email TEXT NOT NULL,
+					role VARCHAR(16) NOT NULL,
+					token_hash TEXT NOT NULL,
+					invited_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+					expires_at DATETIME NOT NULL,
+					accepted_at DATETIME,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE UNIQUE INDEX idx_workspace_invitations_token_hash ON workspace_invitations(token_hash)`,
+				`CREATE INDEX idx_workspace_invitations_workspace ON workspace_invitations(workspace_id)`,
+				`INSERT INTO workspaces (id, name, personal, created_at, updated_at)
+					SELECT id, 'Personal', 1, created_at, created_at FROM users`,
+				`INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+					SELECT id, id, 'owner', created_at FROM users`,
+				`ALTER TABLE links ADD COLUMN workspace_id TEXT REFERENCES workspaces(id) ON DELETE CASCADE`,
+				`UPDATE links SET workspace_id = user_id`,
+				`CREATE INDEX idx_links_workspace ON links(workspace_id)`,
+			},
+		}.exec,
+		Down: sameSQL(
+			`DROP INDEX IF EXISTS idx_links_workspace`,
+			`ALTER TABLE links DROP COLUMN workspace_id`,
+			`DROP TABLE IF EXISTS workspace_invitations`,
+			`DROP TABLE IF EXISTS workspace_members`,
+			`DROP TABLE IF EXISTS workspaces`,
+		).exec,
+	},
+	{
+		Version: 11,
+		Name:    "create_tags_and_folders",
+		Up: dialectSQL{
+			Postgres: []string{
+				`CREATE TABLE tags (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					name VARCHAR(50) NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE UNIQUE INDEX idx_tags_workspace_name ON tags(workspace_id, name)`,
+				`CREATE TABLE link_tags (
+					link_id UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+					tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+					PRIMARY KEY (link_id, tag_id)
+				)`,
+				`CREATE INDEX idx_link_tags_tag ON link_tags(tag_id)`,
+				`CREATE TABLE folders (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					parent_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+					name VARCHAR(100) NOT NULL,
+					created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+				)`,
+				`CREATE INDEX idx_folders_workspace ON folders(workspace_id)`,
+				`CREATE INDEX idx_folders_parent ON folders(parent_id)`,
+				`ALTER TABLE links ADD COLUMN folder_id UUID REFERENCES folders(id) ON DELETE SET NULL`,
+				`CREATE INDEX idx_links_folder ON links(folder_id)`,
+			},
+			SQLite: []string{
+				`CREATE TABLE tags (
+					id TEXT PRIMARY KEY,
+					workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					name VARCHAR(50) NOT NULL,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE UNIQUE INDEX idx_tags_workspace_name ON tags(workspace_id, name)`,
+				`CREATE TABLE link_tags (
+					link_id TEXT NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+					tag_id TEXT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+					PRIMARY KEY (link_id, tag_id)
+				)`,
+				`CREATE INDEX idx_link_tags_tag ON link_tags(tag_id)`,
+				`CREATE TABLE folders (
+					id TEXT PRIMARY KEY,
+					workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+					parent_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+					name VARCHAR(100) NOT NULL,
+					created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+					updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+				)`,
+				`CREATE INDEX idx_folders_workspace ON folders(workspace_id)`,
+				`CREATE INDEX idx_folders_parent ON folders(parent_id)`,
+				`ALTER TABLE links ADD COLUMN folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL`,
+				`CREATE INDEX idx_links_folder ON links(folder_id)`,
+			},
+		}.exec,
+		Down: sameSQL(
+			`DROP INDEX IF EXISTS idx_links_folder`,
+			`ALTER TABLE links DROP COLUMN folder_id`,
+			`DROP TABLE IF EXISTS folders`,
+			`DROP TABLE IF EXISTS link_tags`,
+			`DROP TABLE IF EXISTS tags`,
+		).exec,
+	},
+	{
+		// target_host backs the domain: search qualifier. On PostgreSQL the
+		// generated search_vector and search_text columns back full-text and
+		// trigram search over short codes, titles and target URLs.
+		Version: 12,
+		Name:    "add_link_search",
+		Up: func(tx *gorm.DB) error {
+			err := dialectSQL{
+				Postgres: []string{
+					`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+					`ALTER TABLE links ADD COLUMN target_host VARCHAR(255) NOT NULL DEFAULT ''`,
+					`CREATE INDEX idx_links_target_host ON links(target_host)`,
+					`ALTER TABLE links ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+						setweight(to_tsvector('simple'::regconfig, short_code), 'A') ||
+						setweight(to_tsvector('simple'::regconfig, coalesce(title, '')), 'B') ||
+						setweight(to_tsvector('simple'::regconfig, target_url), 'C')
+					) STORED`,
+					`CREATE INDEX idx_links_search_vector ON links USING GIN (search_vector)`,
+					`ALTER TABLE links ADD COLUMN search_text TEXT GENERATED ALWAYS AS (
+						lower(short_code || ' ' || coalesce(title, '') || ' ' || target_url)
+					) STORED`,
+					`CREATE INDEX idx_links_search_text ON links USING GIN (search_text gin_trgm_ops)`,
+				},
+				SQLite: []string{
+					`ALTER TABLE links ADD COLUMN target_host VARCHAR(255) NOT NULL DEFAULT ''`,
+					`CREATE INDEX idx_links_target_host ON links(target_host)`,
+				},
+			}.exec(tx)
+			if err != nil {
+				return err
+			}
+			return backfillTargetHosts(tx)
+		},
+		// pg_trgm is left installed, other schemas of the database may use it
+		Down: dialectSQL{
+			Postgres: []string{
+				`DROP INDEX IF EXISTS idx_links_search_text`,
+				`ALTER TABLE links DROP COLUMN search_text`,
+				`DROP INDEX IF EXISTS idx_links_search_vector`,
+				`ALTER TABLE links DROP COLUMN search_vector`,
+				`DROP INDEX IF EXISTS idx_links_target_host`,
+				`ALTER TABLE links DROP COLUMN target_host`,
+			},
+			SQLite: []string{
+				`DROP INDEX IF EXISTS idx_links_target_host`,
+				`ALTER TABLE links DROP COLUMN target_host`,
+			},
+		}.exec,
+	},
+	{
+		Version: 13,
+		Name:    "add_user_pending_email",
+		Up:      sameSQL(`ALTER TABLE users ADD COLUMN pending_email TEXT`).exec,
+		Down:    sameSQL(`ALTER TABLE users DROP COLUMN pending_email`).exec,
+	},
+}
+
+// backfillTargetHosts sets the target_host of the links created before the
+// column existed
+func backfillTargetHosts(tx *gorm.DB) error {
+	type linkURL struct {
+		ID        string
+		TargetURL string
+	}
+
+	var batch []linkURL
+	return tx.Table("links").Select("id, target_url").FindInBatches(&batch, 500, func(batchTx *gorm.DB, _ int) error {
+		for _, link := range batch {
+			host := models.TargetHost(link.TargetURL)
+			if host == "" {
+				continue
+			}
+			if err := tx.Table("links").Where("id = ?", link.ID).Update("target_host", host).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
@@ -0,0 +1,175 @@
+// Package jwtkeys holds the keys that sign and verify access tokens
+package jwtkeys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zhakazx/cleanshort/config"
+)
+
+// ErrUnknownKey is returned for tokens signed with a key that is not in the set
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet signs access tokens with one key and verifies them against every
+// key that is still accepted. With a shared secret it signs HS256 tokens
+// without a key ID; with key files it signs RS256 or EdDSA tokens whose kid
+// header names the verification key. Only tokens of the issuer with an
+// expiry time are accepted.
+type KeySet struct {
+	issuer     string
+	method     jwt.SigningMethod
+	signingKey interface{}
+	keyID      string
+
+	secret  []byte
+	keys    map[string]*publicKey
+	ordered []*publicKey
+	methods []string
+}
+
+// Load builds the key set from JWT_SIGNING_KEY_FILE and
+// JWT_VERIFICATION_KEY_FILES, falling back to HS256 with JWT_SECRET
+func Load(cfg *config.Config) (*KeySet, error) {
+	if cfg.JWTSigningKeyFile == "" {
+		if len(cfg.JWTVerificationKeyFiles) > 0 {
+			return nil, errors.New("JWT_VERIFICATION_KEY_FILES requires JWT_SIGNING_KEY_FILE")
+		}
+		return NewHMAC(cfg.BaseURL, []byte(cfg.JWTSecret)), nil
+	}
+
+	private, public, err := readKeyFile(cfg.JWTSigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if private == nil {
+		return nil, fmt.Errorf("%s: JWT_SIGNING_KEY_FILE must contain a private key", cfg.JWTSigningKeyFile)
+	}
+
+	var verification []interface{}
+	for _, path := range cfg.JWTVerificationKeyFiles {
+		_, key, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	return New(cfg.BaseURL, private, public, verification...)
+}
+
+// NewHMAC returns a key set that signs and verifies HS256 tokens of issuer
+// with secret
+func NewHMAC(issuer string, secret []byte) *KeySet {
+	return &KeySet{
+		issuer:     issuer,
+		method:     jwt.SigningMethodHS256,
+		signingKey: secret,
+		secret:     secret,
+		methods:    []string{jwt.SigningMethodHS256.Alg()},
+	}
+}
+
+// New returns a key set for tokens of issuer that signs with an RSA or
+// Ed25519 private key and verifies with its public key and the given extra
+// public keys
+func New(issuer string, private, public interface{}, verification ...interface{}) (*KeySet, error) {
+	signing, err := newPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		issuer:     issuer,
+		method:     signing.method(),
+		signingKey: private,
+		keyID:      signing.id,
+		keys:       make(map[string]*publicKey),
+	}
+	ks.add(signing)
+
+	for _, key := range verification {
+		pk, err := newPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ks.add(pk)
+	}
+
+	return ks, nil
+}
+
+func (ks *KeySet) add(key *publicKey) {
+	if _, ok := ks.keys[key.id]; ok {
+		return
+	}
+	ks.keys[key.id] = key
+	ks.ordered = append(ks.ordered, key)
+
+	for _, method := range ks.methods {
+		if method == key.alg {
+			return
+		}
+	}
+	ks.methods = append(ks.methods, key.alg)
+}
+
+// Sign returns the signed token for claims
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	if ks.keyID != "" {
+		token.Header["kid"] = ks.keyID
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+// Parse verifies a token and decodes its claims into claims
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, ks.keyFunc,
+		jwt.WithValidMethods(ks.methods),
+		jwt.WithIssuer(ks.issuer),
+		jwt.WithExpirationRequired(),
+	)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	if ks.secret != nil {
+		return ks.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// Keys are bound to one algorithm, so a token cannot pick another one
+	if token.Method.Alg() != key.alg {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.key, nil
+}
+
+// JWKS returns the public verification keys. It is empty for HS256, whose
+// secret cannot be published.
+func (ks *KeySet) JWKS() *JWKS {
+	set := &JWKS{Keys: make([]JWK, 0, len(ks.ordered))}
+	for _, key := range ks.ordered {
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	return set
+}
+
+func readKeyFile(path string) (private, public interface{}, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	private, public, err = parsePEM(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return private, public, nil
+}
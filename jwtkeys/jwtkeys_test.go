@@ -0,0 +1,43 @@
+package jwtkeys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseChecksIssuerAndExpiry(t *testing.T) {
+	const issuer = "https://short.test"
+	keys := NewHMAC(issuer, []byte("test-secret"))
+	expiresAt := jwt.NewNumericDate(time.Now().Add(time.Minute))
+
+	tests := []struct {
+		name   string
+		keys   *KeySet
+		claims jwt.RegisteredClaims
+		valid  bool
+	}{
+		{"valid", keys, jwt.RegisteredClaims{Issuer: issuer, ExpiresAt: expiresAt}, true},
+		{"other issuer", keys, jwt.RegisteredClaims{Issuer: "https://other.test", ExpiresAt: expiresAt}, false},
+		{"no issuer", keys, jwt.RegisteredClaims{ExpiresAt: expiresAt}, false},
+		{"no expiry", keys, jwt.RegisteredClaims{Issuer: issuer}, false},
+		{"expired", keys, jwt.RegisteredClaims{Issuer: issuer, ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}, false},
+		{"other secret", NewHMAC(issuer, []byte("other-secret")), jwt.RegisteredClaims{Issuer: issuer, ExpiresAt: expiresAt}, false},
+	}
+
+	for _, tt := range tests {
+		token, err := tt.keys.Sign(tt.claims)
+		if err != nil {
+			t.Fatalf("%s: sign: %v", tt.name, err)
+		}
+
+		_, err = keys.Parse(token, &jwt.RegisteredClaims{})
+		if tt.valid && err != nil {
+			t.Errorf("%s: rejected: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogMailer writes emails to a file, or to the application log when no path is
+// set, instead of sending them. It is meant for development.
+type LogMailer struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	if err := checkHeader(msg.To); err != nil {
+		return err
+	}
+	if err := checkHeader(msg.Subject); err != nil {
+		return err
+	}
+
+	if m.path == "" {
+		log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), msg.To, msg.Subject, strings.TrimRight(msg.Body, "\n"), strings.Repeat("-", 72))
+	return err
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zhakazx/cleanshort/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST must be set when MAIL_DRIVER is smtp")
+		}
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "log":
+		return NewLogMailer(cfg.MailLogPath), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", cfg.MailDriver)
+	}
+}
+
+// checkHeader rejects header values that could inject further headers
+func checkHeader(value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("invalid mail header: contains a line break")
+	}
+	return nil
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,19 +19,33 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/zhakazx/cleanshort/config"
 	"github.com/zhakazx/cleanshort/database"
+	"github.com/zhakazx/cleanshort/jwtkeys"
+	"github.com/zhakazx/cleanshort/mailer"
+	"github.com/zhakazx/cleanshort/models"
 	"github.com/zhakazx/cleanshort/routes"
+	"github.com/zhakazx/cleanshort/services"
 )
 
 func main() {
 	cfg := config.Load()
 
-	db, err := database.Connect(cfg.DatabaseDSN)
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	if err := database.Migrate(db); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		if err := runRole(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	store, err := database.Open(cfg)
+	if err != nil {
+		log.Fatal("Failed to open database:", err)
 	}
 
 	app := fiber.New(fiber.Config{
@@ -56,7 +74,7 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders: "Origin,Content-Type,Accept,Authorization,X-API-Key",
 	}))
 
 	// Health check endpoints
@@ -66,18 +84,25 @@ func main() {
 
 	app.Get("/readyz", func(c *fiber.Ctx) error {
 		// Check database connection
-		sqlDB, err := db.DB()
-		if err != nil {
-			return c.Status(503).JSON(fiber.Map{"status": "not ready", "error": "database connection failed"})
-		}
-		if err := sqlDB.Ping(); err != nil {
+		if err := store.Ping(); err != nil {
 			return c.Status(503).JSON(fiber.Map{"status": "not ready", "error": "database ping failed"})
 		}
 		return c.JSON(fiber.Map{"status": "ready"})
 	})
 
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up mailer:", err)
+	}
+
+	keys, err := jwtkeys.Load(cfg)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+
 	// Setup routes
-	routes.Setup(app, db, cfg)
+	clickRecorder := services.NewClickRecorder(store, cfg)
+	routes.Setup(app, store, cfg, mail, keys, clickRecorder)
 
 	// Start server in a goroutine
 	go func() {
@@ -103,5 +128,96 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// Flush clicks that are still buffered
+	if err := clickRecorder.Close(ctx); err != nil {
+		log.Println("Failed to flush pending clicks:", err)
+	}
+
 	log.Println("Server exited")
 }
+
+// runMigrate implements "cleanshort migrate up|down [steps]|status|to <version>"
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.DatabaseDriver == "memory" {
+		return fmt.Errorf("migrations do not apply to the memory driver")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: cleanshort migrate up|down [steps]|status|to <version>")
+	}
+
+	db, err := database.Connect(cfg.DatabaseDriver, cfg.DatabaseDSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	switch args[0] {
+	case "up":
+		return database.Migrate(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		return database.MigrateDown(db, steps)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: cleanshort migrate to <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		return database.MigrateTo(db, version)
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// runRole implements "cleanshort role <email> user|admin"
+func runRole(cfg *config.Config, args []string) error {
+	if cfg.DatabaseDriver == "memory" {
+		return fmt.Errorf("roles cannot be set on the memory driver")
+	}
+
+	if len(args) != 2 || (args[1] != models.RoleUser && args[1] != models.RoleAdmin) {
+		return fmt.Errorf("usage: cleanshort role <email> user|admin")
+	}
+
+	store, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	user, err := store.Users.FindByEmail(strings.ToLower(strings.TrimSpace(args[0])))
+	if err != nil {
+		return fmt.Errorf("failed to find user %s: %w", args[0], err)
+	}
+
+	user.Role = args[1]
+	user.UpdatedAt = time.Now()
+	if err := store.Users.Update(user, "role", "updated_at"); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s now has the %s role\n", user.Email, user.Role)
+	return nil
+}
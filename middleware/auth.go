@@ -1,23 +1,48 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/jwtkeys"
 	"github.com/zhakazx/cleanshort/models"
 )
 
 type JWTClaims struct {
 	UserID string `json:"sub"`
 	Email  string `json:"email"`
+	// Scope is a space-separated list of scopes
+	Scope string `json:"scope"`
+	// Role is informational for other services; AuthMiddleware reads the
+	// current role from the database
+	Role string `json:"role,omitempty"`
+	// SessionID is the refresh token family the token was issued for
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(cfg *config.Config) fiber.Handler {
+// APIKeyAuthenticator resolves API keys for AuthMiddleware
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (*models.APIKey, error)
+}
+
+// UserAuthenticator checks the user of an access token for AuthMiddleware
+type UserAuthenticator interface {
+	AuthenticateUser(userID uuid.UUID) (*models.User, error)
+}
+
+// AuthMiddleware accepts a JWT access token or an API key, either as a bearer
+// token or in the X-API-Key header. Credentials of suspended users are
+// rejected.
+func AuthMiddleware(keys *jwtkeys.KeySet, users UserAuthenticator, apiKeys APIKeyAuthenticator) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if apiKey := c.Get("X-API-Key"); apiKey != "" {
+			return authenticateAPIKey(c, apiKeys, apiKey)
+		}
+
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
@@ -41,12 +66,12 @@ func AuthMiddleware(cfg *config.Config) fiber.Handler {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid signing method")
-			}
-			return []byte(cfg.JWTSecret), nil
-		})
+		// A JWT has three dot-separated parts, API keys contain no dots
+		if strings.Count(tokenString, ".") != 2 {
+			return authenticateAPIKey(c, apiKeys, tokenString)
+		}
+
+		token, err := keys.Parse(tokenString, &JWTClaims{})
 
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
@@ -81,10 +106,141 @@ func AuthMiddleware(cfg *config.Config) fiber.Handler {
 			})
 		}
 
+		// Suspensions and role changes apply to tokens issued before them
+		user, err := users.AuthenticateUser(userID)
+		if err != nil {
+			return userAuthError(c, err)
+		}
+
 		// Set user context
 		c.Locals("userID", userID)
 		c.Locals("userEmail", claims.Email)
+		c.Locals("role", user.Role)
+		// Tokens issued before scopes were introduced have no scope claim and
+		// keep full access until they expire
+		scopes := models.AllScopes
+		if claims.Scope != "" {
+			scopes = models.SplitScopes(claims.Scope)
+		}
+		c.Locals("scopes", scopes)
+
+		if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+			c.Locals("sessionID", sessionID)
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireRole rejects requests of users without the role. It must run after
+// AuthMiddleware.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if userRole, _ := c.Locals("role").(string); userRole != role {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "FORBIDDEN",
+					Message:   "This endpoint requires the " + role + " role",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
 
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// RequireScope rejects requests whose credential lacks the scope. It must run
+// after AuthMiddleware.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, _ := c.Locals("scopes").([]string)
+		if !models.HasScope(scopes, scope) {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "INSUFFICIENT_SCOPE",
+					Message:   "This credential requires the " + scope + " scope",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+func authenticateAPIKey(c *fiber.Ctx, apiKeys APIKeyAuthenticator, key string) error {
+	if apiKeys == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "UNAUTHORIZED",
+				Message:   "Invalid or expired token",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	apiKey, err := apiKeys.AuthenticateAPIKey(key)
+	if err != nil {
+		if !strings.Contains(err.Error(), "api key") {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error: models.ErrorDetail{
+					Code:      "INTERNAL_ERROR",
+					Message:   "Failed to authenticate API key",
+					RequestID: c.Locals("requestid").(string),
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "UNAUTHORIZED",
+				Message:   "Invalid or revoked API key",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if apiKey.User.IsSuspended() {
+		return userAuthError(c, errors.New("account suspended"))
+	}
+
+	// Set user context
+	c.Locals("userID", apiKey.UserID)
+	c.Locals("userEmail", apiKey.User.Email)
+	c.Locals("role", apiKey.User.Role)
+	c.Locals("apiKeyID", apiKey.ID)
+	c.Locals("scopes", apiKey.ScopeList())
+
+	return c.Next()
+}
+
+func userAuthError(c *fiber.Ctx, err error) error {
+	if strings.Contains(err.Error(), "account suspended") {
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "ACCOUNT_SUSPENDED",
+				Message:   "This account has been suspended",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	if strings.Contains(err.Error(), "user not found") {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error: models.ErrorDetail{
+				Code:      "UNAUTHORIZED",
+				Message:   "Invalid or expired token",
+				RequestID: c.Locals("requestid").(string),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error: models.ErrorDetail{
+			Code:      "INTERNAL_ERROR",
+			Message:   "Failed to authenticate user",
+			RequestID: c.Locals("requestid").(string),
+		},
+	})
+}
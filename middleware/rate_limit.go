@@ -60,6 +60,23 @@ func (rl *RateLimiter) Allow(key string) (bool, int, time.Time) {
 	return true, rl.limit - len(validRequests), now.Add(rl.window)
 }
 
+// Refund takes back the newest request recorded for the key, for callers that
+// record a request with Allow before they know whether it counts
+func (rl *RateLimiter) Refund(key string) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	requests := rl.requests[key]
+	if len(requests) == 0 {
+		return
+	}
+	if len(requests) == 1 {
+		delete(rl.requests, key)
+		return
+	}
+	rl.requests[key] = requests[:len(requests)-1]
+}
+
 func (rl *RateLimiter) cleanup() {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllowIsAtomic(t *testing.T) {
+	const limit = 5
+	rl := NewRateLimiter(limit, time.Minute)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _, _ := rl.Allow("key"); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != limit {
+		t.Fatalf("%d parallel requests allowed, want %d", allowed.Load(), limit)
+	}
+}
+
+func TestRateLimiterRefund(t *testing.T) {
+	rl := NewRateLimiter(2, time.Minute)
+
+	rl.Allow("key")
+	rl.Allow("key")
+	if ok, _, _ := rl.Allow("key"); ok {
+		t.Fatal("request over the limit allowed")
+	}
+
+	rl.Refund("key")
+	if ok, _, _ := rl.Allow("key"); !ok {
+		t.Fatal("request after a refund not allowed")
+	}
+
+	// Refunds of unknown keys do nothing
+	rl.Refund("other")
+	if ok, _, _ := rl.Allow("other"); !ok {
+		t.Fatal("request of another key not allowed")
+	}
+}
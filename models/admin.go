@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AdminUserResponse struct {
+	UserResponse
+	SuspendedAt *time.Time `json:"suspended_at"`
+	Links       LinkCounts `json:"links"`
+}
+
+type AdminUserListResponse struct {
+	Users  []AdminUserResponse `json:"users"`
+	Total  int64               `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+type AdminLinkResponse struct {
+	LinkResponse
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type AdminLinkListResponse struct {
+	Links  []AdminLinkResponse `json:"links"`
+	Total  int64               `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+type UserStats struct {
+	Total     int64 `json:"total"`
+	Verified  int64 `json:"verified"`
+	Suspended int64 `json:"suspended"`
+	Admins    int64 `json:"admins"`
+}
+
+type LinkStats struct {
+	Total    int64 `json:"total"`
+	Active   int64 `json:"active"`
+	Disabled int64 `json:"disabled"`
+	Clicks   int64 `json:"clicks"`
+}
+
+type AdminStatsResponse struct {
+	Users UserStats `json:"users"`
+	Links LinkStats `json:"links"`
+}
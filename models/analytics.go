@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LinkStatsResponse struct {
+	LinkID         uuid.UUID     `json:"link_id"`
+	From           time.Time     `json:"from"`
+	To             time.Time     `json:"to"`
+	Interval       string        `json:"interval"`
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Series         []StatsBucket `json:"series"`
+	TopReferrers   []StatsCount  `json:"top_referrers"`
+	TopBrowsers    []StatsCount  `json:"top_browsers"`
+	TopCountries   []StatsCount  `json:"top_countries"`
+}
+
+type StatsBucket struct {
+	Start          time.Time `json:"start"`
+	Clicks         int64     `json:"clicks"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+}
+
+type StatsCount struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
@@ -8,8 +8,10 @@ type AuthResponse struct {
 }
 
 type TokenRefreshResponse struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int64  `json:"expires_in"`
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
 }
 
 type ErrorResponse struct {
@@ -17,7 +19,8 @@ type ErrorResponse struct {
 }
 
 type ErrorDetail struct {
-	Code      string `json:"code"`
-	Message   string `json:"message"`
-	RequestID string `json:"request_id,omitempty"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RequestID  string `json:"request_id,omitempty"`
+	RetryAfter int64  `json:"retry_after,omitempty"` // seconds, for ACCOUNT_LOCKED
 }
\ No newline at end of file
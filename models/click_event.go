@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ClickEvent struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	LinkID    uuid.UUID `json:"link_id" gorm:"type:uuid;not null"`
+	ClickedAt time.Time `json:"clicked_at" gorm:"not null;default:now()"`
+	Referrer  string    `json:"referrer" gorm:"type:text;not null;default:''"`
+	UserAgent string    `json:"user_agent" gorm:"type:text;not null;default:''"`
+	Browser   string    `json:"browser" gorm:"type:varchar(32);not null;default:''"`
+	Country   string    `json:"country" gorm:"type:varchar(2);not null;default:''"`
+	IPHash    string    `json:"-" gorm:"type:varchar(64);not null"`
+	RequestID string    `json:"request_id" gorm:"type:varchar(64);not null;default:''"`
+
+	Link Link `json:"-" gorm:"foreignKey:LinkID;constraint:OnDelete:CASCADE"`
+}
+
+// BeforeCreate hook to generate UUID if not set
+func (e *ClickEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+type ClickEventListResponse struct {
+	Events []ClickEvent `json:"events"`
+	Total  int64        `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
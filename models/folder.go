@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Folder groups links of a workspace. Folders without a parent are at the top
+// of the hierarchy; deleting a folder deletes its subfolders and moves their
+// links out of any folder.
+type Folder struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WorkspaceID uuid.UUID  `json:"workspace_id" gorm:"type:uuid;not null;index:idx_folders_workspace"`
+	ParentID    *uuid.UUID `json:"parent_id" gorm:"type:uuid;index:idx_folders_parent"`
+	Name        string     `json:"name" gorm:"type:varchar(100);not null"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"not null;default:now()"`
+
+	Workspace Workspace `json:"-" gorm:"foreignKey:WorkspaceID;constraint:OnDelete:CASCADE"`
+}
+
+// BeforeCreate hook to generate UUID if not set
+func (f *Folder) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return nil
+}
+
+type FolderCreateRequest struct {
+	Name     string     `json:"name" validate:"required,max=100"`
+	ParentID *uuid.UUID `json:"parent_id,omitempty"`
+	// WorkspaceID defaults to the workspace of the parent, or else to the
+	// personal workspace of the user
+	WorkspaceID *uuid.UUID `json:"workspace_id,omitempty"`
+}
+
+type FolderUpdateRequest struct {
+	Name *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	// An empty ParentID moves the folder to the top of the hierarchy
+	ParentID *string `json:"parent_id,omitempty"`
+}
+
+type FolderResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	WorkspaceID uuid.UUID  `json:"workspace_id"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Name        string     `json:"name"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type FolderListResponse struct {
+	Folders []FolderResponse `json:"folders"`
+}
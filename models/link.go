@@ -1,25 +1,38 @@
 package models
 
 import (
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// Link is owned by a workspace. UserID is the member who created it.
 type Link struct {
 	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	UserID        uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index:idx_links_user"`
+	WorkspaceID   uuid.UUID  `json:"workspace_id" gorm:"type:uuid;not null;index:idx_links_workspace"`
+	FolderID      *uuid.UUID `json:"folder_id" gorm:"type:uuid;index:idx_links_folder"`
 	ShortCode     string     `json:"short_code" gorm:"type:varchar(32);uniqueIndex;not null" validate:"required,min=4,max=32,alphanum"`
 	TargetURL     string     `json:"target_url" gorm:"type:text;not null" validate:"required,url,max=2048"`
+	TargetHost    string     `json:"-" gorm:"type:varchar(255);not null;default:'';index:idx_links_target_host"` // see TargetHost
 	Title         *string    `json:"title" gorm:"type:text"`
 	IsActive      bool       `json:"is_active" gorm:"not null;default:true"`
 	ClickCount    int64      `json:"click_count" gorm:"not null;default:0"`
 	LastClickedAt *time.Time `json:"last_clicked_at"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	MaxClicks     *int64     `json:"max_clicks"`
+	PasswordHash  *string    `json:"-" gorm:"type:text"`
+	DisabledAt    *time.Time `json:"disabled_at"` // set when an admin deactivates the link
 	CreatedAt     time.Time  `json:"created_at" gorm:"not null;default:now()"`
 	UpdatedAt     time.Time  `json:"updated_at" gorm:"not null;default:now()"`
 
-	User User `json:"user,omitempty" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	User      User      `json:"user,omitempty" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Workspace Workspace `json:"-" gorm:"foreignKey:WorkspaceID;constraint:OnDelete:CASCADE"`
+	Folder    *Folder   `json:"-" gorm:"foreignKey:FolderID;constraint:OnDelete:SET NULL"`
+	Tags      []Tag     `json:"-" gorm:"many2many:link_tags"`
 }
 
 // BeforeCreate hook to generate UUID if not set
@@ -30,35 +43,110 @@ func (l *Link) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// TargetHost returns the lowercased host name of a target URL, without port,
+// for searching links by domain
+func TargetHost(targetURL string) string {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// IsExpired checks if the link's expiration time has passed
+func (l *Link) IsExpired() bool {
+	return l.ExpiresAt != nil && !time.Now().Before(*l.ExpiresAt)
+}
+
+// IsExhausted checks if the link has used up its click budget
+func (l *Link) IsExhausted() bool {
+	return l.MaxClicks != nil && l.ClickCount >= *l.MaxClicks
+}
+
+// IsDisabled checks if an admin deactivated the link. Owners cannot
+// reactivate disabled links.
+func (l *Link) IsDisabled() bool {
+	return l.DisabledAt != nil
+}
+
+// IsPasswordProtected checks if visitors must enter a password before being redirected
+func (l *Link) IsPasswordProtected() bool {
+	return l.PasswordHash != nil
+}
+
 type LinkCreateRequest struct {
-	TargetURL string  `json:"target_url" validate:"required,url,max=2048"`
-	ShortCode *string `json:"short_code,omitempty" validate:"omitempty,min=4,max=32,alphanum"`
-	Title     *string `json:"title,omitempty"`
-	IsActive  *bool   `json:"is_active,omitempty"`
+	TargetURL string     `json:"target_url" validate:"required,url,max=2048"`
+	ShortCode *string    `json:"short_code,omitempty" validate:"omitempty,min=4,max=32,alphanum"`
+	Title     *string    `json:"title,omitempty"`
+	IsActive  *bool      `json:"is_active,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	MaxClicks *int64     `json:"max_clicks,omitempty" validate:"omitempty,min=1"`
+	Password  *string    `json:"password,omitempty" validate:"omitempty,min=4,max=72"`
+	// WorkspaceID defaults to the personal workspace of the user
+	WorkspaceID *uuid.UUID `json:"workspace_id,omitempty"`
+	// FolderID must be a folder of the same workspace
+	FolderID *uuid.UUID `json:"folder_id,omitempty"`
+	// Tags are tag names; missing tags are created in the workspace
+	Tags []string `json:"tags,omitempty" validate:"omitempty,max=20,dive,max=50"`
 }
 
 type LinkUpdateRequest struct {
-	TargetURL *string `json:"target_url,omitempty" validate:"omitempty,url,max=2048"`
-	Title     *string `json:"title,omitempty"`
-	IsActive  *bool   `json:"is_active,omitempty"`
+	TargetURL *string    `json:"target_url,omitempty" validate:"omitempty,url,max=2048"`
+	Title     *string    `json:"title,omitempty"`
+	IsActive  *bool      `json:"is_active,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ClearExpiresAt removes the expiry time
+	ClearExpiresAt bool `json:"clear_expires_at,omitempty"`
+	// MaxClicks of 0 removes the click budget
+	MaxClicks *int64 `json:"max_clicks,omitempty" validate:"omitempty,min=0"`
+	// An empty Password removes the password protection
+	Password *string `json:"password,omitempty" validate:"omitempty,max=72"`
+	// An empty FolderID moves the link out of its folder
+	FolderID *string `json:"folder_id,omitempty"`
+	// Tags replace the tags of the link; an empty list removes them all
+	Tags *[]string `json:"tags,omitempty" validate:"omitempty,max=20,dive,max=50"`
 }
 
 type LinkResponse struct {
-	ID            uuid.UUID  `json:"id"`
-	ShortCode     string     `json:"short_code"`
-	ShortURL      string     `json:"short_url"`
-	TargetURL     string     `json:"target_url"`
-	Title         *string    `json:"title"`
-	IsActive      bool       `json:"is_active"`
-	ClickCount    int64      `json:"click_count"`
-	LastClickedAt *time.Time `json:"last_clicked_at,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	ID                uuid.UUID  `json:"id"`
+	WorkspaceID       uuid.UUID  `json:"workspace_id"`
+	FolderID          *uuid.UUID `json:"folder_id"`
+	Tags              []string   `json:"tags"`
+	ShortCode         string     `json:"short_code"`
+	ShortURL          string     `json:"short_url"`
+	TargetURL         string     `json:"target_url"`
+	Title             *string    `json:"title"`
+	IsActive          bool       `json:"is_active"`
+	ClickCount        int64      `json:"click_count"`
+	LastClickedAt     *time.Time `json:"last_clicked_at,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	MaxClicks         *int64     `json:"max_clicks,omitempty"`
+	PasswordProtected bool       `json:"password_protected"`
+	DisabledAt        *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
 type LinkListResponse struct {
 	Links []LinkResponse `json:"links"`
-	Total int64          `json:"total"`
-	Limit int            `json:"limit"`
-	Offset int           `json:"offset"`
-}
\ No newline at end of file
+	// Total is only set when the total was requested
+	Total  *int64 `json:"total,omitempty"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	// NextCursor continues the listing after this page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// LinkBulkResult reports one row of a bulk create. Rows are numbered from 1.
+type LinkBulkResult struct {
+	Row   int           `json:"row"`
+	Link  *LinkResponse `json:"link,omitempty"`
+	Error *ErrorDetail  `json:"error,omitempty"`
+}
+
+type LinkBulkResponse struct {
+	Mode    string           `json:"mode"`
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Results []LinkBulkResult `json:"results"`
+}
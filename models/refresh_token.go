@@ -10,11 +10,17 @@ import (
 type RefreshToken struct {
 	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	FamilyID  uuid.UUID `json:"family_id" gorm:"type:uuid;not null;index:idx_refresh_tokens_family"` // shared by all tokens rotated from one login
 	TokenHash string    `json:"-" gorm:"type:text;uniqueIndex;not null"`
 	Revoked   bool      `json:"revoked" gorm:"not null;default:false"`
 	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
 	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
 
+	// Client that logged in or last refreshed the session
+	UserAgent  string     `json:"user_agent" gorm:"type:text;not null;default:''"`
+	IPAddress  string     `json:"ip_address" gorm:"type:varchar(64);not null;default:''"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
 
@@ -36,6 +42,27 @@ func (rt *RefreshToken) IsValid() bool {
 	return !rt.Revoked && !rt.IsExpired()
 }
 
+// ClientInfo describes the client a refresh token is issued to
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionResponse describes one login. Its ID is the refresh token family ID,
+// which stays the same while the refresh token rotates.
+type SessionResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,59 @@
+package models
+
+import "strings"
+
+// Scopes limit what an access token or API key may do
+const (
+	ScopeLinksRead     = "links:read"
+	ScopeLinksWrite    = "links:write"
+	ScopeLinksDelete   = "links:delete"
+	ScopeStatsRead     = "stats:read"
+	ScopeAPIKeysManage = "api_keys:manage"
+	ScopeAccountManage = "account:manage"
+	// ScopeWorkspacesManage allows changing workspaces, their members and
+	// invitations. Listing them only needs ScopeLinksRead.
+	ScopeWorkspacesManage = "workspaces:manage"
+	// ScopeAdmin is only granted to admins
+	ScopeAdmin = "admin"
+)
+
+// AllScopes lists every scope of a regular user. Access tokens issued at login
+// carry all of them.
+var AllScopes = []string{
+	ScopeLinksRead,
+	ScopeLinksWrite,
+	ScopeLinksDelete,
+	ScopeStatsRead,
+	ScopeAPIKeysManage,
+	ScopeAccountManage,
+	ScopeWorkspacesManage,
+}
+
+// ScopesForRole returns the scopes of access tokens issued to a user with
+// the role
+func ScopesForRole(role string) []string {
+	if role == RoleAdmin {
+		return append(append([]string{}, AllScopes...), ScopeAdmin)
+	}
+	return AllScopes
+}
+
+// HasScope reports whether scopes contains scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// JoinScopes encodes scopes as a space-separated string, as in OAuth 2.0
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// SplitScopes decodes a space-separated scope string
+func SplitScopes(scopes string) []string {
+	return strings.Fields(scopes)
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tag labels links of a workspace. Names are unique within a workspace and
+// stored in lowercase.
+type Tag struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WorkspaceID uuid.UUID `json:"workspace_id" gorm:"type:uuid;not null;uniqueIndex:idx_tags_workspace_name"`
+	Name        string    `json:"name" gorm:"type:varchar(50);not null;uniqueIndex:idx_tags_workspace_name"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null;default:now()"`
+
+	Workspace Workspace `json:"-" gorm:"foreignKey:WorkspaceID;constraint:OnDelete:CASCADE"`
+}
+
+// BeforeCreate hook to generate UUID if not set
+func (t *Tag) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// LinkTag is a row of the join table between links and tags
+type LinkTag struct {
+	LinkID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TagID  uuid.UUID `gorm:"type:uuid;primaryKey;index:idx_link_tags_tag"`
+}
+
+type TagCreateRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+	// WorkspaceID defaults to the personal workspace of the user
+	WorkspaceID *uuid.UUID `json:"workspace_id,omitempty"`
+}
+
+type TagUpdateRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type TagResponse struct {
+	ID          uuid.UUID `json:"id"`
+	WorkspaceID uuid.UUID `json:"workspace_id"`
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type TagListResponse struct {
+	Tags []TagResponse `json:"tags"`
+}
@@ -7,12 +7,25 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Email     string    `json:"email" gorm:"type:text;uniqueIndex;not null" validate:"required,email"`
-	Password  string    `json:"-" gorm:"type:text;not null" validate:"required,min=8"`
-	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:now()"`
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Email           string     `json:"email" gorm:"type:text;uniqueIndex;not null" validate:"required,email"`
+	Password        string     `json:"-" gorm:"type:text;not null" validate:"required,min=8"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PendingEmail    *string    `json:"-" gorm:"type:text"` // requested new email, applied once verified
+	TOTPSecret      *string    `json:"-" gorm:"column:totp_secret;type:text"`
+	TOTPEnabledAt   *time.Time `json:"-" gorm:"column:totp_enabled_at"`
+	TOTPLastStep    *int64     `json:"-" gorm:"column:totp_last_step"` // newest time step used, to stop code replays
+	Role            string     `json:"role" gorm:"type:varchar(16);not null;default:'user'"`
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"not null;default:now()"`
 
 	Links         []Link         `json:"links,omitempty" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	RefreshTokens []RefreshToken `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
@@ -23,6 +36,9 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
 	}
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
 	return nil
 }
 
@@ -37,7 +53,57 @@ type UserLoginRequest struct {
 }
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID               uuid.UUID `json:"id"`
+	Email            string    `json:"email"`
+	EmailVerified    bool      `json:"email_verified"`
+	PendingEmail     *string   `json:"pending_email,omitempty"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	Role             string    `json:"role"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// IsEmailVerified checks if the user has verified their current email
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsAdmin checks if the user may use the admin API
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsSuspended checks if an admin has blocked the user from the API
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
+// IsTwoFactorEnabled checks if the user has confirmed TOTP enrolment
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+type LinkCounts struct {
+	Total  int64 `json:"total"`
+	Active int64 `json:"active"`
+}
+
+type MeResponse struct {
+	UserResponse
+	Links LinkCounts `json:"links"`
+}
+
+// MeUpdateRequest changes the email of the current user. The new email has to
+// be verified again.
+type MeUpdateRequest struct {
+	Email           string `json:"email" validate:"required,email"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+type AccountDeleteRequest struct {
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Roles of a workspace member. Viewers can read the links of the workspace,
+// editors can also change them and owners can also manage the workspace.
+const (
+	WorkspaceRoleViewer = "viewer"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleOwner  = "owner"
+)
+
+var workspaceRoleRanks = map[string]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleEditor: 2,
+	WorkspaceRoleOwner:  3,
+}
+
+// WorkspaceRoleAtLeast reports whether role grants everything required does
+func WorkspaceRoleAtLeast(role, required string) bool {
+	rank, ok := workspaceRoleRanks[role]
+	return ok && rank >= workspaceRoleRanks[required]
+}
+
+// Workspace owns links on behalf of its members. Every user has a personal
+// workspace with the same ID as the user, which cannot be shared.
+type Workspace struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name      string    `json:"name" gorm:"type:text;not null"`
+	Personal  bool      `json:"personal" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:now()"`
+}
+
+// BeforeCreate hook to generate UUID if not set
+func (w *Workspace) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID `json:"workspace_id" gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey;index:idx_workspace_members_user"`
+	Role        string    `json:"role" gorm:"type:varchar(16);not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null;default:now()"`
+
+	Workspace Workspace `json:"-" gorm:"foreignKey:WorkspaceID;constraint:OnDelete:CASCADE"`
+	User      User      `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// WorkspaceInvitation invites an email address to join a workspace. Only the
+// hash of the token sent by email is stored.
+type WorkspaceInvitation struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	WorkspaceID uuid.UUID  `json:"workspace_id" gorm:"type:uuid;not null;index:idx_workspace_invitations_workspace"`
+	Email       string     `json:"email" gorm:"type:text;not null"`
+	Role        string     `json:"role" gorm:"type:varchar(16);not null"`
+	TokenHash   string     `json:"-" gorm:"type:text;uniqueIndex;not null"`
+	InvitedBy   *uuid.UUID `json:"invited_by" gorm:"type:uuid"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"not null;default:now()"`
+
+	Workspace Workspace `json:"-" gorm:"foreignKey:WorkspaceID;constraint:OnDelete:CASCADE"`
+}
+
+// BeforeCreate hook to generate UUID if not set
+func (i *WorkspaceInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsPending checks if the invitation can still be accepted
+func (i *WorkspaceInvitation) IsPending() bool {
+	return i.AcceptedAt == nil && time.Now().Before(i.ExpiresAt)
+}
+
+type WorkspaceCreateRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type WorkspaceUpdateRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type WorkspaceMemberUpdateRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+type WorkspaceInvitationCreateRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+type WorkspaceInvitationAcceptRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// LinkTransferRequest moves a link to another workspace
+type LinkTransferRequest struct {
+	WorkspaceID uuid.UUID `json:"workspace_id" validate:"required"`
+}
+
+type WorkspaceResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Personal  bool      `json:"personal"`
+	Role      string    `json:"role"` // role of the current user
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WorkspaceListResponse struct {
+	Workspaces []WorkspaceResponse `json:"workspaces"`
+}
+
+type WorkspaceMemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WorkspaceMemberListResponse struct {
+	Members []WorkspaceMemberResponse `json:"members"`
+}
+
+type WorkspaceInvitationResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	InvitedBy *uuid.UUID `json:"invited_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type WorkspaceInvitationListResponse struct {
+	Invitations []WorkspaceInvitationResponse `json:"invitations"`
+}
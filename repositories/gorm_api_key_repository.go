@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+type gormAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAPIKeyRepository) Create(key *models.APIKey) error {
+	return translateError(r.db.Create(key).Error)
+}
+
+func (r *gormAPIKeyRepository) FindByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("key_hash = ?", keyHash).Preload("User").First(&key).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (r *gormAPIKeyRepository) FindByID(id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("id = ?", id).First(&key).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (r *gormAPIKeyRepository) ListByUser(userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *gormAPIKeyRepository) Revoke(id uuid.UUID, at time.Time) error {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormAPIKeyRepository) Touch(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", at).Error
+}
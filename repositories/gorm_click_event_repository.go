@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+const clickEventBatchSize = 500
+
+type gormClickEventRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormClickEventRepository) Create(events ...*models.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return translateError(r.db.CreateInBatches(events, clickEventBatchSize).Error)
+}
+
+func (r *gormClickEventRepository) List(filter ClickEventFilter) ([]models.ClickEvent, int64, error) {
+	var events []models.ClickEvent
+	var total int64
+
+	db := r.db.Model(&models.ClickEvent{}).Where("link_id = ?", filter.LinkID)
+
+	if filter.From != nil {
+		db = db.Where("clicked_at >= ?", *filter.From)
+	}
+
+	if filter.To != nil {
+		db = db.Where("clicked_at < ?", *filter.To)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := db.Order("clicked_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+func (r *gormClickEventRepository) inRange(linkID uuid.UUID, from, to time.Time) *gorm.DB {
+	return r.db.Model(&models.ClickEvent{}).
+		Where("link_id = ? AND clicked_at >= ? AND clicked_at < ?", linkID, from, to)
+}
+
+func (r *gormClickEventRepository) Totals(linkID uuid.UUID, from, to time.Time) (int64, int64, error) {
+	var totals struct {
+		Clicks         int64
+		UniqueVisitors int64
+	}
+	err := r.inRange(linkID, from, to).
+		Select("COUNT(*) AS clicks, COUNT(DISTINCT ip_hash) AS unique_visitors").
+		Scan(&totals).Error
+
+	return totals.Clicks, totals.UniqueVisitors, err
+}
+
+func (r *gormClickEventRepository) Buckets(linkID uuid.UUID, from, to time.Time, interval string) ([]models.StatsBucket, error) {
+	bucketExpr, err := r.bucketExpression(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Start          string
+		Clicks         int64
+		UniqueVisitors int64
+	}
+	if err := r.inRange(linkID, from, to).
+		Select(bucketExpr + " AS start, COUNT(*) AS clicks, COUNT(DISTINCT ip_hash) AS unique_visitors").
+		Group("start").
+		Order("start").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	buckets := make([]models.StatsBucket, 0, len(rows))
+	for _, row := range rows {
+		start, err := time.Parse(bucketLayout, row.Start)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, models.StatsBucket{
+			Start:          start,
+			Clicks:         row.Clicks,
+			UniqueVisitors: row.UniqueVisitors,
+		})
+	}
+
+	return buckets, nil
+}
+
+// bucketLayout is the text format both dialects render bucket starts in
+const bucketLayout = "2006-01-02 15:04:05"
+
+// bucketExpression truncates clicked_at to the start of its UTC interval and
+// renders it as text, so both dialects scan the same way. Weeks start on Monday.
+func (r *gormClickEventRepository) bucketExpression(interval string) (string, error) {
+	if r.db.Dialector.Name() == "sqlite" {
+		switch interval {
+		case "hour":
+			return "strftime('%Y-%m-%d %H:00:00', clicked_at)", nil
+		case "day":
+			return "strftime('%Y-%m-%d 00:00:00', clicked_at)", nil
+		case "week":
+			return "strftime('%Y-%m-%d 00:00:00', clicked_at, 'weekday 0', '-6 days')", nil
+		}
+	} else {
+		switch interval {
+		case "hour", "day", "week":
+			return fmt.Sprintf("to_char(date_trunc('%s', clicked_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD HH24:MI:SS')", interval), nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid interval %q", interval)
+}
+
+func (r *gormClickEventRepository) TopValues(linkID uuid.UUID, from, to time.Time, field string, limit int) ([]models.StatsCount, error) {
+	if !clickEventFields[field] {
+		return nil, fmt.Errorf("invalid click event field %q", field)
+	}
+
+	counts := []models.StatsCount{}
+	err := r.inRange(linkID, from, to).
+		Select(field + " AS value, COUNT(*) AS clicks").
+		Group(field).
+		Order("clicks DESC, value").
+		Limit(limit).
+		Scan(&counts).Error
+
+	return counts, err
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+type gormEmailTokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormEmailTokenRepository) Create(token *models.EmailToken) error {
+	return translateError(r.db.Create(token).Error)
+}
+
+func (r *gormEmailTokenRepository) FindByHash(tokenHash string) (*models.EmailToken, error) {
+	var token models.EmailToken
+	if err := r.db.Where("token_hash = ?", tokenHash).Preload("User").First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (r *gormEmailTokenRepository) Use(id uuid.UUID, at time.Time) (bool, error) {
+	result := r.db.Model(&models.EmailToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormEmailTokenRepository) UseAllForUser(userID uuid.UUID, purpose string, at time.Time) error {
+	return r.db.Model(&models.EmailToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+}
+
+func (r *gormEmailTokenRepository) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&models.EmailToken{}).Error
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+type gormFolderRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormFolderRepository) Create(folder *models.Folder) error {
+	return translateError(r.db.Create(folder).Error)
+}
+
+func (r *gormFolderRepository) FindByID(id uuid.UUID) (*models.Folder, error) {
+	var folder models.Folder
+	if err := r.db.Where("id = ?", id).First(&folder).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &folder, nil
+}
+
+func (r *gormFolderRepository) List(workspaceID uuid.UUID) ([]models.Folder, error) {
+	var folders []models.Folder
+	err := r.db.Where("workspace_id = ?", workspaceID).Order("name, id").Find(&folders).Error
+	return folders, err
+}
+
+func (r *gormFolderRepository) Update(folder *models.Folder, columns ...string) error {
+	return translateError(r.db.Model(folder).Select(columns).Updates(folder).Error)
+}
+
+// Delete relies on the foreign keys to delete the subfolders and to move the
+// links out of the deleted folders
+func (r *gormFolderRepository) Delete(id uuid.UUID) error {
+	result := r.db.Where("id = ?", id).Delete(&models.Folder{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,446 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormLinkRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormLinkRepository) Create(link *models.Link) error {
+	return translateError(r.db.Create(link).Error)
+}
+
+func (r *gormLinkRepository) FindByID(id uuid.UUID) (*models.Link, error) {
+	var link models.Link
+	if err := r.db.Where("id = ?", id).Preload("Tags").First(&link).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &link, nil
+}
+
+func (r *gormLinkRepository) FindByShortCode(shortCode string) (*models.Link, error) {
+	var link models.Link
+	if err := r.db.Where("short_code = ?", shortCode).First(&link).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &link, nil
+}
+
+func (r *gormLinkRepository) ShortCodeExists(shortCode string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Link{}).Where("short_code = ?", shortCode).Limit(1).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *gormLinkRepository) Update(link *models.Link, columns ...string) error {
+	return translateError(r.db.Model(link).Select(columns).Updates(link).Error)
+}
+
+func (r *gormLinkRepository) Delete(id uuid.UUID) error {
+	result := r.db.Where("id = ?", id).Delete(&models.Link{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormLinkRepository) SetTags(linkID uuid.UUID, tagIDs []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("link_id = ?", linkID).Delete(&models.LinkTag{}).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) == 0 {
+			return nil
+		}
+
+		rows := make([]models.LinkTag, len(tagIDs))
+		for i, tagID := range tagIDs {
+			rows[i] = models.LinkTag{LinkID: linkID, TagID: tagID}
+		}
+		return translateError(tx.Create(&rows).Error)
+	})
+}
+
+func (r *gormLinkRepository) CountByUser(userID uuid.UUID) (int64, int64, error) {
+	var counts struct {
+		Total  int64
+		Active int64
+	}
+	err := r.db.Model(&models.Link{}).
+		Select("COUNT(*) AS total, COUNT(CASE WHEN is_active THEN 1 END) AS active").
+		Where("user_id = ?", userID).
+		Scan(&counts).Error
+	return counts.Total, counts.Active, err
+}
+
+func (r *gormLinkRepository) CountByWorkspace(workspaceID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Link{}).Where("workspace_id = ?", workspaceID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormLinkRepository) ShortCodesByWorkspace(workspaceID uuid.UUID) ([]string, error) {
+	var shortCodes []string
+	if err := r.db.Model(&models.Link{}).Where("workspace_id = ?", workspaceID).Pluck("short_code", &shortCodes).Error; err != nil {
+		return nil, err
+	}
+	return shortCodes, nil
+}
+
+func (r *gormLinkRepository) ReassignCreator(workspaceID, fromUserID, toUserID uuid.UUID) error {
+	return r.db.Model(&models.Link{}).
+		Where("workspace_id = ? AND user_id = ?", workspaceID, fromUserID).
+		Update("user_id", toUserID).Error
+}
+
+func (r *gormLinkRepository) List(filter LinkFilter) ([]models.Link, int64, error) {
+	var links []models.Link
+	var total int64
+
+	db := r.filtered(filter)
+	if !filter.SkipTotal {
+		if err := db.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	order, err := r.linkOrder(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if filter.After != nil && filter.SortBy != LinkSortRelevance {
+		db = r.afterLinkCursor(db, filter)
+	}
+
+	if err := db.Order(order).Limit(filter.Limit).Offset(filter.Offset).Preload("Tags").Find(&links).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return links, total, nil
+}
+
+// linkBatchSize is the number of links Each reads per query
+const linkBatchSize = 500
+
+func (r *gormLinkRepository) Each(filter LinkFilter, fn func(link *models.Link) error) error {
+	order, err := r.linkOrder(filter)
+	if err != nil {
+		return err
+	}
+
+	// Each batch continues after the last link of the previous one, so links
+	// created or deleted meanwhile do not shift the batches. A relevance rank
+	// is no position to continue from, so those batches are read by offset.
+	relevance := filter.SortBy == LinkSortRelevance
+	for offset := 0; ; offset += linkBatchSize {
+		db := r.filtered(filter)
+		if relevance {
+			db = db.Offset(offset)
+		} else if filter.After != nil {
+			db = r.afterLinkCursor(db, filter)
+		}
+
+		var links []models.Link
+		if err := db.Order(order).Limit(linkBatchSize).Preload("Tags").Find(&links).Error; err != nil {
+			return err
+		}
+
+		for i := range links {
+			if err := fn(&links[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(links) < linkBatchSize {
+			return nil
+		}
+
+		if relevance {
+			continue
+		}
+		cursor := LinkCursorAt(&links[len(links)-1], filter.SortBy)
+		filter.After = &cursor
+	}
+}
+
+// filtered applies the conditions of filter, without ordering or paging
+func (r *gormLinkRepository) filtered(filter LinkFilter) *gorm.DB {
+	db := r.db.Model(&models.Link{})
+	if filter.UserID != uuid.Nil {
+		db = db.Where("user_id = ?", filter.UserID)
+	}
+
+	if filter.WorkspaceIDs != nil {
+		db = db.Where("workspace_id IN ?", filter.WorkspaceIDs)
+	}
+
+	if filter.Active != nil {
+		db = db.Where("is_active = ?", *filter.Active)
+	}
+
+	if len(filter.Tags) > 0 {
+		db = db.Where("id IN (?)", r.taggedLinkIDs(filter.Tags, filter.MatchAllTags))
+	}
+
+	if filter.FolderIDs != nil {
+		db = db.Where("folder_id IN ?", filter.FolderIDs)
+	}
+
+	if filter.Unfiled {
+		db = db.Where("folder_id IS NULL")
+	}
+
+	if filter.Query != "" {
+		searchPattern := "%" + strings.ToLower(filter.Query) + "%"
+		db = db.Where("LOWER(short_code) LIKE ? OR LOWER(title) LIKE ?", searchPattern, searchPattern)
+	}
+
+	if filter.Search != nil {
+		db = r.searched(db, filter.Search)
+	}
+
+	return db
+}
+
+// taggedLinkIDs selects the IDs of the links with any of the tag names, or
+// with all of them
+func (r *gormLinkRepository) taggedLinkIDs(names []string, all bool) *gorm.DB {
+	tagged := r.db.Table("link_tags").
+		Select("link_tags.link_id").
+		Joins("JOIN tags ON tags.id = link_tags.tag_id").
+		Where("tags.name IN ?", names)
+	if all {
+		tagged = tagged.Group("link_tags.link_id").Having("COUNT(DISTINCT tags.name) = ?", len(names))
+	}
+	return tagged
+}
+
+// searched limits db to the links matching search
+func (r *gormLinkRepository) searched(db *gorm.DB, search *LinkSearch) *gorm.DB {
+	if len(search.Terms) > 0 {
+		if r.db.Dialector.Name() == "sqlite" {
+			for _, term := range search.Terms {
+				pattern := "%" + term + "%"
+				db = db.Where("LOWER(short_code) LIKE ? OR LOWER(title) LIKE ? OR LOWER(target_url) LIKE ?", pattern, pattern, pattern)
+			}
+		} else {
+			// The full-text match uses the GIN index of search_vector. As a
+			// fallback the trigram index of search_text finds parts of words,
+			// like path segments of URLs, and near matches of misspelled terms.
+			text := searchText(search.Terms)
+			conditions := []string{"search_vector @@ websearch_to_tsquery('simple', ?)", "? <% search_text"}
+			args := []interface{}{text, text}
+
+			contains := make([]string, len(search.Terms))
+			for i, term := range search.Terms {
+				contains[i] = "search_text LIKE ?"
+				args = append(args, "%"+term+"%")
+			}
+			conditions = append(conditions, "("+strings.Join(contains, " AND ")+")")
+			db = db.Where(strings.Join(conditions, " OR "), args...)
+		}
+	}
+
+	if len(search.Domains) > 0 {
+		conditions := make([]string, len(search.Domains))
+		var args []interface{}
+		for i, domain := range search.Domains {
+			conditions[i] = "target_host = ? OR target_host LIKE ?"
+			args = append(args, domain, "%."+domain)
+		}
+		db = db.Where(strings.Join(conditions, " OR "), args...)
+	}
+
+	if len(search.Tags) > 0 {
+		db = db.Where("id IN (?)", r.taggedLinkIDs(search.Tags, true))
+	}
+
+	if search.MinClicks != nil {
+		db = db.Where("click_count >= ?", *search.MinClicks)
+	}
+	if search.MaxClicks != nil {
+		db = db.Where("click_count <= ?", *search.MaxClicks)
+	}
+
+	db = r.inTimeRange(db, "created_at", search.Created)
+	return r.inTimeRange(db, "last_clicked_at", search.Clicked)
+}
+
+// inTimeRange limits db to the links whose timestamp column is in timeRange.
+// NULL values are never in a range.
+func (r *gormLinkRepository) inTimeRange(db *gorm.DB, column string, timeRange TimeRange) *gorm.DB {
+	expression := r.sortExpression(column, column)
+	value := r.sortExpression(column, "?")
+	if timeRange.From != nil {
+		db = db.Where(expression+" >= "+value, timeRange.From.UTC())
+	}
+	if timeRange.To != nil {
+		db = db.Where(expression+" < "+value, timeRange.To.UTC())
+	}
+	return db
+}
+
+// searchText turns search terms back into a query for websearch_to_tsquery,
+// quoting phrases
+func searchText(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		if strings.Contains(term, " ") {
+			term = `"` + term + `"`
+		}
+		quoted[i] = term
+	}
+	return strings.Join(quoted, " ")
+}
+
+// linkOrder orders by the sort column of filter, breaking ties by ID so the
+// order is the same on every query
+func (r *gormLinkRepository) linkOrder(filter LinkFilter) (interface{}, error) {
+	if filter.SortBy == LinkSortRelevance {
+		return r.relevanceOrder(filter.Search), nil
+	}
+	if !linkSortColumns[filter.SortBy] {
+		return "", fmt.Errorf("invalid sort column %q", filter.SortBy)
+	}
+
+	direction := "ASC"
+	if filter.OrderBy == "desc" {
+		direction = "DESC"
+	}
+
+	// Build the order clause
+	orderClause := fmt.Sprintf("%s %s", r.sortExpression(filter.SortBy, filter.SortBy), direction)
+
+	// Sort NULL values last in both directions, as the keyset conditions of
+	// afterLinkCursor expect
+	if linkNullableSortColumns[filter.SortBy] {
+		orderClause += " NULLS LAST"
+	}
+
+	return orderClause + ", id " + direction, nil
+}
+
+// relevanceOrder ranks full-text matches on PostgreSQL, where matches of the
+// short code weigh most and matches of the target URL least, and adds the
+// trigram similarity. SQLite adds weights for every term found in the short
+// code, title or target URL instead. Newer links come first among equals.
+func (r *gormLinkRepository) relevanceOrder(search *LinkSearch) clause.OrderBy {
+	newest := r.sortExpression("created_at", "created_at") + " DESC, id DESC"
+	if search == nil || len(search.Terms) == 0 {
+		return clause.OrderBy{Expression: clause.Expr{SQL: newest}}
+	}
+
+	if r.db.Dialector.Name() != "sqlite" {
+		text := searchText(search.Terms)
+		return clause.OrderBy{Expression: clause.Expr{
+			SQL:  "ts_rank(search_vector, websearch_to_tsquery('simple', ?)) + word_similarity(?, search_text) DESC, " + newest,
+			Vars: []interface{}{text, text},
+		}}
+	}
+
+	weights := make([]string, len(search.Terms))
+	var vars []interface{}
+	for i, term := range search.Terms {
+		weights[i] = "CASE WHEN LOWER(short_code) LIKE ? THEN 3 ELSE 0 END + " +
+			"CASE WHEN LOWER(title) LIKE ? THEN 2 ELSE 0 END + " +
+			"CASE WHEN LOWER(target_url) LIKE ? THEN 1 ELSE 0 END"
+		pattern := "%" + term + "%"
+		vars = append(vars, pattern, pattern, pattern)
+	}
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:  strings.Join(weights, " + ") + " DESC, " + newest,
+		Vars: vars,
+	}}
+}
+
+// afterLinkCursor limits db to the links after filter.After in the order of
+// linkOrder. The sort column has been checked by linkOrder.
+func (r *gormLinkRepository) afterLinkCursor(db *gorm.DB, filter LinkFilter) *gorm.DB {
+	column := r.sortExpression(filter.SortBy, filter.SortBy)
+	value := r.sortExpression(filter.SortBy, "?")
+
+	op := ">"
+	if filter.OrderBy == "desc" {
+		op = "<"
+	}
+	cursor := filter.After
+
+	if cursor.Value == nil {
+		// Only NULL values, ordered by ID, follow a NULL value
+		return db.Where(fmt.Sprintf("%s IS NULL AND id %s ?", column, op), cursor.ID)
+	}
+
+	condition := fmt.Sprintf("%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s ?)", column, op, value)
+	if linkNullableSortColumns[filter.SortBy] {
+		condition += fmt.Sprintf(" OR %s IS NULL", column)
+	}
+	return db.Where("("+condition+")", cursor.Value, cursor.Value, cursor.ID)
+}
+
+// sortExpression returns how operand, the sort column or a value of it, is
+// compared when sorting by column. SQLite stores timestamps as text with
+// varying numbers of fractional digits, which only compare correctly once
+// normalized.
+func (r *gormLinkRepository) sortExpression(column, operand string) string {
+	if r.db.Dialector.Name() != "sqlite" {
+		return operand
+	}
+
+	switch column {
+	case "created_at", "updated_at", "last_clicked_at":
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:%%f', %s)", operand)
+	}
+	return operand
+}
+
+func (r *gormLinkRepository) Stats() (*models.LinkStats, error) {
+	var stats models.LinkStats
+	err := r.db.Model(&models.Link{}).
+		Select("COUNT(*) AS total, COUNT(CASE WHEN is_active THEN 1 END) AS active, " +
+			"COUNT(disabled_at) AS disabled, COALESCE(SUM(click_count), 0) AS clicks").
+		Scan(&stats).Error
+	return &stats, err
+}
+
+func (r *gormLinkRepository) ClaimClick(shortCode string, at time.Time) (bool, error) {
+	result := r.db.Model(&models.Link{}).
+		Where("short_code = ?", shortCode).
+		Where("expires_at IS NULL OR expires_at > ?", at).
+		Where("max_clicks IS NULL OR click_count < max_clicks").
+		Updates(map[string]interface{}{
+			"click_count":     gorm.Expr("click_count + 1"),
+			"last_clicked_at": at,
+		})
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormLinkRepository) AddClicks(shortCode string, clicks int64, lastClickedAt time.Time) error {
+	return r.db.Model(&models.Link{}).
+		Where("short_code = ?", shortCode).
+		Updates(map[string]interface{}{
+			"click_count":     gorm.Expr("click_count + ?", clicks),
+			"last_clicked_at": gorm.Expr("CASE WHEN last_clicked_at IS NULL OR last_clicked_at < ? THEN ? ELSE last_clicked_at END", lastClickedAt, lastClickedAt),
+		}).Error
+}
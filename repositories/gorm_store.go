@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// NewGormStore returns a Store backed by a GORM connection. It supports the
+// postgres and sqlite dialects.
+func NewGormStore(db *gorm.DB) *Store {
+	store := &Store{
+		Users:                &gormUserRepository{db: db},
+		Links:                &gormLinkRepository{db: db},
+		RefreshTokens:        &gormRefreshTokenRepository{db: db},
+		APIKeys:              &gormAPIKeyRepository{db: db},
+		EmailTokens:          &gormEmailTokenRepository{db: db},
+		RecoveryCodes:        &gormRecoveryCodeRepository{db: db},
+		ClickEvents:          &gormClickEventRepository{db: db},
+		Workspaces:           &gormWorkspaceRepository{db: db},
+		WorkspaceInvitations: &gormWorkspaceInvitationRepository{db: db},
+		Tags:                 &gormTagRepository{db: db},
+		Folders:              &gormFolderRepository{db: db},
+	}
+
+	store.transaction = func(fn func(tx *Store) error) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			return fn(NewGormStore(tx))
+		})
+	}
+
+	store.ping = func() error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Ping()
+	}
+
+	return store
+}
+
+// translateError maps GORM errors to repository errors
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	default:
+		return err
+	}
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+type gormTagRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTagRepository) Create(tag *models.Tag) error {
+	return translateError(r.db.Create(tag).Error)
+}
+
+func (r *gormTagRepository) FindByID(id uuid.UUID) (*models.Tag, error) {
+	var tag models.Tag
+	if err := r.db.Where("id = ?", id).First(&tag).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &tag, nil
+}
+
+func (r *gormTagRepository) FindByNames(workspaceID uuid.UUID, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	if len(names) == 0 {
+		return tags, nil
+	}
+	err := r.db.Where("workspace_id = ? AND name IN ?", workspaceID, names).Find(&tags).Error
+	return tags, err
+}
+
+func (r *gormTagRepository) List(workspaceID uuid.UUID) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := r.db.Where("workspace_id = ?", workspaceID).Order("name").Find(&tags).Error
+	return tags, err
+}
+
+func (r *gormTagRepository) Update(tag *models.Tag, columns ...string) error {
+	return translateError(r.db.Model(tag).Select(columns).Updates(tag).Error)
+}
+
+func (r *gormTagRepository) Delete(id uuid.UUID) error {
+	result := r.db.Where("id = ?", id).Delete(&models.Tag{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return translateError(r.db.Create(user).Error)
+}
+
+func (r *gormUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) Update(user *models.User, columns ...string) error {
+	return translateError(r.db.Model(user).Select(columns).Updates(user).Error)
+}
+
+func (r *gormUserRepository) UseTOTPStep(id uuid.UUID, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", id, step).
+		Update("totp_last_step", step)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormUserRepository) Delete(id uuid.UUID) error {
+	result := r.db.Where("id = ?", id).Delete(&models.User{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormUserRepository) List(filter UserFilter) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	db := r.db.Model(&models.User{})
+
+	if filter.Query != "" {
+		db = db.Where("LOWER(email) LIKE ?", "%"+strings.ToLower(filter.Query)+"%")
+	}
+
+	if filter.Role != "" {
+		db = db.Where("role = ?", filter.Role)
+	}
+
+	if filter.Suspended != nil {
+		if *filter.Suspended {
+			db = db.Where("suspended_at IS NOT NULL")
+		} else {
+			db = db.Where("suspended_at IS NULL")
+		}
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := db.Order("created_at DESC, id").Limit(filter.Limit).Offset(filter.Offset).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (r *gormUserRepository) Stats() (*models.UserStats, error) {
+	var stats models.UserStats
+	err := r.db.Model(&models.User{}).
+		Select("COUNT(*) AS total, COUNT(email_verified_at) AS verified, COUNT(suspended_at) AS suspended, "+
+			"COUNT(CASE WHEN role = ? THEN 1 END) AS admins", models.RoleAdmin).
+		Scan(&stats).Error
+	return &stats, err
+}
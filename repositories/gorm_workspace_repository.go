@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"gorm.io/gorm"
+)
+
+type gormWorkspaceRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormWorkspaceRepository) Create(workspace *models.Workspace) error {
+	return translateError(r.db.Create(workspace).Error)
+}
+
+func (r *gormWorkspaceRepository) FindByID(id uuid.UUID) (*models.Workspace, error) {
+	var workspace models.Workspace
+	if err := r.db.Where("id = ?", id).First(&workspace).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &workspace, nil
+}
+
+func (r *gormWorkspaceRepository) Update(workspace *models.Workspace, columns ...string) error {
+	return translateError(r.db.Model(workspace).Select(columns).Updates(workspace).Error)
+}
+
+func (r *gormWorkspaceRepository) Delete(id uuid.UUID) error {
+	result := r.db.Where("id = ?", id).Delete(&models.Workspace{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormWorkspaceRepository) AddMember(member *models.WorkspaceMember) error {
+	return translateError(r.db.Create(member).Error)
+}
+
+func (r *gormWorkspaceRepository) FindMember(workspaceID, userID uuid.UUID) (*models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	err := r.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).
+		Preload("Workspace").
+		First(&member).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &member, nil
+}
+
+func (r *gormWorkspaceRepository) ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error) {
+	var members []models.WorkspaceMember
+	err := r.db.Where("workspace_id = ?", workspaceID).
+		Preload("User").
+		Order("created_at, user_id").
+		Find(&members).Error
+	return members, err
+}
+
+func (r *gormWorkspaceRepository) ListMemberships(userID uuid.UUID) ([]models.WorkspaceMember, error) {
+	var members []models.WorkspaceMember
+	err := r.db.Where("user_id = ?", userID).
+		Preload("Workspace").
+		Order("created_at, workspace_id").
+		Find(&members).Error
+	return members, err
+}
+
+func (r *gormWorkspaceRepository) UpdateMemberRole(workspaceID, userID uuid.UUID, role string) error {
+	result := r.db.Model(&models.WorkspaceMember{}).
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormWorkspaceRepository) RemoveMember(workspaceID, userID uuid.UUID) error {
+	result := r.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Delete(&models.WorkspaceMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type gormWorkspaceInvitationRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormWorkspaceInvitationRepository) Create(invitation *models.WorkspaceInvitation) error {
+	return translateError(r.db.Create(invitation).Error)
+}
+
+func (r *gormWorkspaceInvitationRepository) FindByHash(tokenHash string) (*models.WorkspaceInvitation, error) {
+	var invitation models.WorkspaceInvitation
+	if err := r.db.Where("token_hash = ?", tokenHash).Preload("Workspace").First(&invitation).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &invitation, nil
+}
+
+func (r *gormWorkspaceInvitationRepository) ListPending(workspaceID uuid.UUID, now time.Time) ([]models.WorkspaceInvitation, error) {
+	var invitations []models.WorkspaceInvitation
+	err := r.db.Where("workspace_id = ? AND accepted_at IS NULL AND expires_at > ?", workspaceID, now).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *gormWorkspaceInvitationRepository) Accept(id uuid.UUID, at time.Time) (bool, error) {
+	result := r.db.Model(&models.WorkspaceInvitation{}).
+		Where("id = ? AND accepted_at IS NULL", id).
+		Update("accepted_at", at)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormWorkspaceInvitationRepository) Delete(workspaceID, id uuid.UUID) error {
+	result := r.db.Where("id = ? AND workspace_id = ?", id, workspaceID).Delete(&models.WorkspaceInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormWorkspaceInvitationRepository) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&models.WorkspaceInvitation{}).Error
+}
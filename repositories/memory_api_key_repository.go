@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryAPIKeyRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryAPIKeyRepository) Create(key *models.APIKey) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.users[key.UserID]; !ok {
+			return ErrNotFound
+		}
+		for _, existing := range d.apiKeys {
+			if existing.KeyHash == key.KeyHash {
+				return ErrDuplicate
+			}
+		}
+
+		if key.ID == uuid.Nil {
+			key.ID = uuid.New()
+		}
+		if key.CreatedAt.IsZero() {
+			key.CreatedAt = time.Now()
+		}
+
+		stored := *key
+		stored.User = models.User{}
+		d.apiKeys[key.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryAPIKeyRepository) FindByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.apiKeys {
+			if stored.KeyHash == keyHash {
+				key = *stored
+				if user, ok := d.users[stored.UserID]; ok {
+					key.User = *user
+				}
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *memoryAPIKeyRepository) FindByID(id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.conn.read(func(d *memoryData) error {
+		stored, ok := d.apiKeys[id]
+		if !ok {
+			return ErrNotFound
+		}
+		key = *stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *memoryAPIKeyRepository) ListByUser(userID uuid.UUID) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.apiKeys {
+			if stored.UserID == userID {
+				keys = append(keys, *stored)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) Revoke(id uuid.UUID, at time.Time) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.apiKeys[id]
+		if !ok || stored.RevokedAt != nil {
+			return ErrNotFound
+		}
+
+		updated := *stored
+		updated.RevokedAt = &at
+		d.apiKeys[id] = &updated
+		return nil
+	})
+}
+
+func (r *memoryAPIKeyRepository) Touch(id uuid.UUID, at time.Time) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.apiKeys[id]
+		if !ok {
+			return nil
+		}
+
+		updated := *stored
+		updated.LastUsedAt = &at
+		d.apiKeys[id] = &updated
+		return nil
+	})
+}
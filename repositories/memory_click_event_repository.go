@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryClickEventRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryClickEventRepository) Create(events ...*models.ClickEvent) error {
+	return r.conn.write(func(d *memoryData) error {
+		for _, event := range events {
+			if _, ok := d.links[event.LinkID]; !ok {
+				return ErrNotFound
+			}
+		}
+
+		for _, event := range events {
+			if event.ID == uuid.Nil {
+				event.ID = uuid.New()
+			}
+			stored := *event
+			stored.Link = models.Link{}
+			d.clickEvents = append(d.clickEvents, &stored)
+		}
+		return nil
+	})
+}
+
+// inRange returns copies of the click events of a link that match the time bounds
+func (r *memoryClickEventRepository) inRange(linkID uuid.UUID, from, to *time.Time) ([]models.ClickEvent, error) {
+	var events []models.ClickEvent
+	err := r.conn.read(func(d *memoryData) error {
+		for _, event := range d.clickEvents {
+			if event.LinkID != linkID {
+				continue
+			}
+			if from != nil && event.ClickedAt.Before(*from) {
+				continue
+			}
+			if to != nil && !event.ClickedAt.Before(*to) {
+				continue
+			}
+			events = append(events, *event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+func (r *memoryClickEventRepository) List(filter ClickEventFilter) ([]models.ClickEvent, int64, error) {
+	events, err := r.inRange(filter.LinkID, filter.From, filter.To)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ClickedAt.After(events[j].ClickedAt)
+	})
+
+	return paginate(events, filter.Limit, filter.Offset), int64(len(events)), nil
+}
+
+func (r *memoryClickEventRepository) Totals(linkID uuid.UUID, from, to time.Time) (int64, int64, error) {
+	events, err := r.inRange(linkID, &from, &to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	visitors := make(map[string]bool)
+	for _, event := range events {
+		visitors[event.IPHash] = true
+	}
+
+	return int64(len(events)), int64(len(visitors)), nil
+}
+
+func (r *memoryClickEventRepository) Buckets(linkID uuid.UUID, from, to time.Time, interval string) ([]models.StatsBucket, error) {
+	if interval != "hour" && interval != "day" && interval != "week" {
+		return nil, fmt.Errorf("invalid interval %q", interval)
+	}
+
+	events, err := r.inRange(linkID, &from, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	type bucket struct {
+		clicks   int64
+		visitors map[string]bool
+	}
+	byStart := make(map[time.Time]*bucket)
+	for _, event := range events {
+		start := TruncateToInterval(event.ClickedAt, interval)
+		b := byStart[start]
+		if b == nil {
+			b = &bucket{visitors: make(map[string]bool)}
+			byStart[start] = b
+		}
+		b.clicks++
+		b.visitors[event.IPHash] = true
+	}
+
+	buckets := make([]models.StatsBucket, 0, len(byStart))
+	for start, b := range byStart {
+		buckets = append(buckets, models.StatsBucket{
+			Start:          start,
+			Clicks:         b.clicks,
+			UniqueVisitors: int64(len(b.visitors)),
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+
+	return buckets, nil
+}
+
+func (r *memoryClickEventRepository) TopValues(linkID uuid.UUID, from, to time.Time, field string, limit int) ([]models.StatsCount, error) {
+	if !clickEventFields[field] {
+		return nil, fmt.Errorf("invalid click event field %q", field)
+	}
+
+	events, err := r.inRange(linkID, &from, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	clicks := make(map[string]int64)
+	for _, event := range events {
+		switch field {
+		case ClickEventReferrer:
+			clicks[event.Referrer]++
+		case ClickEventBrowser:
+			clicks[event.Browser]++
+		case ClickEventCountry:
+			clicks[event.Country]++
+		}
+	}
+
+	counts := make([]models.StatsCount, 0, len(clicks))
+	for value, n := range clicks {
+		counts = append(counts, models.StatsCount{Value: value, Clicks: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Clicks != counts[j].Clicks {
+			return counts[i].Clicks > counts[j].Clicks
+		}
+		return counts[i].Value < counts[j].Value
+	})
+
+	return paginate(counts, limit, 0), nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryEmailTokenRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryEmailTokenRepository) Create(token *models.EmailToken) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.users[token.UserID]; !ok {
+			return ErrNotFound
+		}
+		for _, existing := range d.emailTokens {
+			if existing.TokenHash == token.TokenHash {
+				return ErrDuplicate
+			}
+		}
+
+		if token.ID == uuid.Nil {
+			token.ID = uuid.New()
+		}
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+
+		stored := *token
+		stored.User = models.User{}
+		d.emailTokens[token.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryEmailTokenRepository) FindByHash(tokenHash string) (*models.EmailToken, error) {
+	var token models.EmailToken
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.emailTokens {
+			if stored.TokenHash == tokenHash {
+				token = *stored
+				if user, ok := d.users[stored.UserID]; ok {
+					token.User = *user
+				}
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *memoryEmailTokenRepository) Use(id uuid.UUID, at time.Time) (bool, error) {
+	var used bool
+	err := r.conn.write(func(d *memoryData) error {
+		stored, ok := d.emailTokens[id]
+		if !ok || stored.UsedAt != nil {
+			return nil
+		}
+
+		updated := *stored
+		updated.UsedAt = &at
+		d.emailTokens[id] = &updated
+		used = true
+		return nil
+	})
+	return used, err
+}
+
+func (r *memoryEmailTokenRepository) UseAllForUser(userID uuid.UUID, purpose string, at time.Time) error {
+	return r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.emailTokens {
+			if stored.UserID != userID || stored.Purpose != purpose || stored.UsedAt != nil {
+				continue
+			}
+
+			updated := *stored
+			updated.UsedAt = &at
+			d.emailTokens[id] = &updated
+		}
+		return nil
+	})
+}
+
+func (r *memoryEmailTokenRepository) DeleteExpired(before time.Time) error {
+	return r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.emailTokens {
+			if stored.ExpiresAt.Before(before) {
+				delete(d.emailTokens, id)
+			}
+		}
+		return nil
+	})
+}
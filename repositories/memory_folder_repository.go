@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryFolderRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryFolderRepository) Create(folder *models.Folder) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.workspaces[folder.WorkspaceID]; !ok {
+			return ErrNotFound
+		}
+		if folder.ParentID != nil {
+			if _, ok := d.folders[*folder.ParentID]; !ok {
+				return ErrNotFound
+			}
+		}
+
+		if folder.ID == uuid.Nil {
+			folder.ID = uuid.New()
+		}
+		now := time.Now()
+		if folder.CreatedAt.IsZero() {
+			folder.CreatedAt = now
+		}
+		if folder.UpdatedAt.IsZero() {
+			folder.UpdatedAt = now
+		}
+
+		stored := *folder
+		stored.Workspace = models.Workspace{}
+		d.folders[folder.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryFolderRepository) FindByID(id uuid.UUID) (*models.Folder, error) {
+	var folder models.Folder
+	err := r.conn.read(func(d *memoryData) error {
+		stored, ok := d.folders[id]
+		if !ok {
+			return ErrNotFound
+		}
+		folder = *stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (r *memoryFolderRepository) List(workspaceID uuid.UUID) ([]models.Folder, error) {
+	folders := []models.Folder{}
+	err := r.conn.read(func(d *memoryData) error {
+		for _, folder := range d.folders {
+			if folder.WorkspaceID == workspaceID {
+				folders = append(folders, *folder)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(folders, func(i, j int) bool {
+		if folders[i].Name != folders[j].Name {
+			return folders[i].Name < folders[j].Name
+		}
+		return folders[i].ID.String() < folders[j].ID.String()
+	})
+	return folders, nil
+}
+
+func (r *memoryFolderRepository) Update(folder *models.Folder, columns ...string) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.folders[folder.ID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		updated := *stored
+		for _, column := range columns {
+			switch column {
+			case "name":
+				updated.Name = folder.Name
+			case "parent_id":
+				if folder.ParentID != nil {
+					if _, ok := d.folders[*folder.ParentID]; !ok {
+						return ErrNotFound
+					}
+				}
+				updated.ParentID = folder.ParentID
+			case "updated_at":
+				updated.UpdatedAt = folder.UpdatedAt
+			default:
+				return fmt.Errorf("unknown folder column %q", column)
+			}
+		}
+
+		d.folders[folder.ID] = &updated
+		return nil
+	})
+}
+
+func (r *memoryFolderRepository) Delete(id uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.folders[id]; !ok {
+			return ErrNotFound
+		}
+
+		d.deleteFolders(func(folder *models.Folder) bool {
+			return folder.ID == id
+		})
+		return nil
+	})
+}
+
+// deleteFolders removes the matching folders and their subfolders, and
+// mirrors ON DELETE SET NULL on the folders of links
+func (d *memoryData) deleteFolders(match func(folder *models.Folder) bool) {
+	deleted := make(map[uuid.UUID]bool)
+	for id, folder := range d.folders {
+		if match(folder) {
+			deleted[id] = true
+		}
+	}
+
+	// Mirror ON DELETE CASCADE on parent_id, one level of subfolders at a time
+	for found := len(deleted) > 0; found; {
+		found = false
+		for id, folder := range d.folders {
+			if !deleted[id] && folder.ParentID != nil && deleted[*folder.ParentID] {
+				deleted[id] = true
+				found = true
+			}
+		}
+	}
+	if len(deleted) == 0 {
+		return
+	}
+
+	for id := range deleted {
+		delete(d.folders, id)
+	}
+	for id, link := range d.links {
+		if link.FolderID != nil && deleted[*link.FolderID] {
+			updated := *link
+			updated.FolderID = nil
+			d.links[id] = &updated
+		}
+	}
+}
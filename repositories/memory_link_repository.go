@@ -0,0 +1,613 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryLinkRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryLinkRepository) Create(link *models.Link) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.users[link.UserID]; !ok {
+			return ErrNotFound
+		}
+		if _, ok := d.workspaces[link.WorkspaceID]; !ok {
+			return ErrNotFound
+		}
+		if link.FolderID != nil {
+			if _, ok := d.folders[*link.FolderID]; !ok {
+				return ErrNotFound
+			}
+		}
+		for _, existing := range d.links {
+			if existing.ShortCode == link.ShortCode {
+				return ErrDuplicate
+			}
+		}
+
+		if link.ID == uuid.Nil {
+			link.ID = uuid.New()
+		}
+		now := time.Now()
+		if link.CreatedAt.IsZero() {
+			link.CreatedAt = now
+		}
+		if link.UpdatedAt.IsZero() {
+			link.UpdatedAt = now
+		}
+
+		stored := *link
+		stored.User = models.User{}
+		stored.Workspace = models.Workspace{}
+		stored.Folder = nil
+		stored.Tags = nil
+		d.links[link.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryLinkRepository) FindByID(id uuid.UUID) (*models.Link, error) {
+	var link models.Link
+	err := r.conn.read(func(d *memoryData) error {
+		stored, ok := d.links[id]
+		if !ok {
+			return ErrNotFound
+		}
+		link = d.withTags(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *memoryLinkRepository) FindByShortCode(shortCode string) (*models.Link, error) {
+	var link models.Link
+	err := r.conn.read(func(d *memoryData) error {
+		stored := d.linkByShortCode(shortCode)
+		if stored == nil {
+			return ErrNotFound
+		}
+		link = *stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *memoryLinkRepository) ShortCodeExists(shortCode string) (bool, error) {
+	var exists bool
+	err := r.conn.read(func(d *memoryData) error {
+		exists = d.linkByShortCode(shortCode) != nil
+		return nil
+	})
+	return exists, err
+}
+
+func (r *memoryLinkRepository) Update(link *models.Link, columns ...string) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.links[link.ID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		updated := *stored
+		for _, column := range columns {
+			if err := setLinkColumn(&updated, link, column); err != nil {
+				return err
+			}
+		}
+
+		d.links[link.ID] = &updated
+		return nil
+	})
+}
+
+func (r *memoryLinkRepository) Delete(id uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.links[id]; !ok {
+			return ErrNotFound
+		}
+
+		d.deleteLinks(func(link *models.Link) bool {
+			return link.ID == id
+		})
+		return nil
+	})
+}
+
+func (r *memoryLinkRepository) SetTags(linkID uuid.UUID, tagIDs []uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.links[linkID]; !ok {
+			return ErrNotFound
+		}
+
+		stored := make([]uuid.UUID, 0, len(tagIDs))
+		seen := make(map[uuid.UUID]bool, len(tagIDs))
+		for _, tagID := range tagIDs {
+			if _, ok := d.tags[tagID]; !ok {
+				return ErrNotFound
+			}
+			if seen[tagID] {
+				return ErrDuplicate
+			}
+			seen[tagID] = true
+			stored = append(stored, tagID)
+		}
+
+		if len(stored) == 0 {
+			delete(d.linkTags, linkID)
+		} else {
+			d.linkTags[linkID] = stored
+		}
+		return nil
+	})
+}
+
+func (r *memoryLinkRepository) CountByUser(userID uuid.UUID) (int64, int64, error) {
+	var total, active int64
+	err := r.conn.read(func(d *memoryData) error {
+		for _, link := range d.links {
+			if link.UserID == userID {
+				total++
+				if link.IsActive {
+					active++
+				}
+			}
+		}
+		return nil
+	})
+	return total, active, err
+}
+
+func (r *memoryLinkRepository) CountByWorkspace(workspaceID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.conn.read(func(d *memoryData) error {
+		for _, link := range d.links {
+			if link.WorkspaceID == workspaceID {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *memoryLinkRepository) ShortCodesByWorkspace(workspaceID uuid.UUID) ([]string, error) {
+	shortCodes := []string{}
+	err := r.conn.read(func(d *memoryData) error {
+		for _, link := range d.links {
+			if link.WorkspaceID == workspaceID {
+				shortCodes = append(shortCodes, link.ShortCode)
+			}
+		}
+		return nil
+	})
+	return shortCodes, err
+}
+
+func (r *memoryLinkRepository) ReassignCreator(workspaceID, fromUserID, toUserID uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.users[toUserID]; !ok {
+			return ErrNotFound
+		}
+		for id, link := range d.links {
+			if link.WorkspaceID != workspaceID || link.UserID != fromUserID {
+				continue
+			}
+
+			updated := *link
+			updated.UserID = toUserID
+			d.links[id] = &updated
+		}
+		return nil
+	})
+}
+
+func (r *memoryLinkRepository) List(filter LinkFilter) ([]models.Link, int64, error) {
+	relevance := filter.SortBy == LinkSortRelevance
+	if !relevance && !linkSortColumns[filter.SortBy] {
+		return nil, 0, fmt.Errorf("invalid sort column %q", filter.SortBy)
+	}
+
+	var matched []models.Link
+	scores := make(map[uuid.UUID]int)
+	err := r.conn.read(func(d *memoryData) error {
+		query := strings.ToLower(filter.Query)
+		var workspaces map[uuid.UUID]bool
+		if filter.WorkspaceIDs != nil {
+			workspaces = make(map[uuid.UUID]bool, len(filter.WorkspaceIDs))
+			for _, id := range filter.WorkspaceIDs {
+				workspaces[id] = true
+			}
+		}
+		var folders map[uuid.UUID]bool
+		if filter.FolderIDs != nil {
+			folders = make(map[uuid.UUID]bool, len(filter.FolderIDs))
+			for _, id := range filter.FolderIDs {
+				folders[id] = true
+			}
+		}
+
+		for _, link := range d.links {
+			if filter.UserID != uuid.Nil && link.UserID != filter.UserID {
+				continue
+			}
+			if workspaces != nil && !workspaces[link.WorkspaceID] {
+				continue
+			}
+			if filter.Active != nil && link.IsActive != *filter.Active {
+				continue
+			}
+			if query != "" && !strings.Contains(strings.ToLower(link.ShortCode), query) &&
+				(link.Title == nil || !strings.Contains(strings.ToLower(*link.Title), query)) {
+				continue
+			}
+			if folders != nil && (link.FolderID == nil || !folders[*link.FolderID]) {
+				continue
+			}
+			if filter.Unfiled && link.FolderID != nil {
+				continue
+			}
+			if len(filter.Tags) > 0 && !hasTags(d.linkTagNames(link.ID), filter.Tags, filter.MatchAllTags) {
+				continue
+			}
+			if filter.Search != nil {
+				if !d.matchesSearch(link, filter.Search) {
+					continue
+				}
+				if relevance {
+					for _, term := range filter.Search.Terms {
+						scores[link.ID] += searchWeight(link, term)
+					}
+				}
+			}
+			matched = append(matched, d.withTags(link))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	desc := filter.OrderBy == "desc"
+	less := func(a, b *models.Link) bool {
+		if relevance {
+			// Best matches first, then newest first like the SQL backends
+			if scores[a.ID] != scores[b.ID] {
+				return scores[a.ID] > scores[b.ID]
+			}
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID.String() > b.ID.String()
+		}
+		if lessLink(a, b, filter.SortBy, desc) {
+			return true
+		}
+		if lessLink(b, a, filter.SortBy, desc) {
+			return false
+		}
+		// Break ties by ID like the SQL backends
+		if desc {
+			return a.ID.String() > b.ID.String()
+		}
+		return a.ID.String() < b.ID.String()
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return less(&matched[i], &matched[j])
+	})
+
+	var total int64
+	if !filter.SkipTotal {
+		total = int64(len(matched))
+	}
+
+	if filter.After != nil && !relevance {
+		position := linkAtCursor(filter.SortBy, filter.After)
+		start := sort.Search(len(matched), func(i int) bool {
+			return less(position, &matched[i])
+		})
+		matched = matched[start:]
+	}
+
+	return paginate(matched, filter.Limit, filter.Offset), total, nil
+}
+
+// linkAtCursor builds a link with the sort column and ID of cursor, to compare
+// other links with
+func linkAtCursor(column string, cursor *LinkCursor) *models.Link {
+	link := &models.Link{ID: cursor.ID}
+	switch value := cursor.Value.(type) {
+	case time.Time:
+		switch column {
+		case "created_at":
+			link.CreatedAt = value
+		case "updated_at":
+			link.UpdatedAt = value
+		case "last_clicked_at":
+			link.LastClickedAt = &value
+		}
+	case string:
+		if column == "title" {
+			link.Title = &value
+		} else {
+			link.ShortCode = value
+		}
+	case int64:
+		link.ClickCount = value
+	}
+	return link
+}
+
+// Each iterates over a snapshot of the matching links, which the memory
+// backend holds in memory anyway
+func (r *memoryLinkRepository) Each(filter LinkFilter, fn func(link *models.Link) error) error {
+	filter.Limit, filter.Offset = 0, 0
+	links, _, err := r.List(filter)
+	if err != nil {
+		return err
+	}
+
+	for i := range links {
+		if err := fn(&links[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryLinkRepository) Stats() (*models.LinkStats, error) {
+	var stats models.LinkStats
+	err := r.conn.read(func(d *memoryData) error {
+		for _, link := range d.links {
+			stats.Total++
+			if link.IsActive {
+				stats.Active++
+			}
+			if link.DisabledAt != nil {
+				stats.Disabled++
+			}
+			stats.Clicks += link.ClickCount
+		}
+		return nil
+	})
+	return &stats, err
+}
+
+func (r *memoryLinkRepository) ClaimClick(shortCode string, at time.Time) (bool, error) {
+	var claimed bool
+	err := r.conn.write(func(d *memoryData) error {
+		stored := d.linkByShortCode(shortCode)
+		if stored == nil {
+			return nil
+		}
+		if stored.ExpiresAt != nil && !stored.ExpiresAt.After(at) {
+			return nil
+		}
+		if stored.MaxClicks != nil && stored.ClickCount >= *stored.MaxClicks {
+			return nil
+		}
+
+		updated := *stored
+		updated.ClickCount++
+		updated.LastClickedAt = &at
+		d.links[stored.ID] = &updated
+		claimed = true
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *memoryLinkRepository) AddClicks(shortCode string, clicks int64, lastClickedAt time.Time) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored := d.linkByShortCode(shortCode)
+		if stored == nil {
+			return nil
+		}
+
+		updated := *stored
+		updated.ClickCount += clicks
+		if updated.LastClickedAt == nil || updated.LastClickedAt.Before(lastClickedAt) {
+			updated.LastClickedAt = &lastClickedAt
+		}
+		d.links[stored.ID] = &updated
+		return nil
+	})
+}
+
+// deleteLinks removes the matching links together with their tags and click
+// events
+func (d *memoryData) deleteLinks(match func(link *models.Link) bool) {
+	deleted := make(map[uuid.UUID]bool)
+	for id, link := range d.links {
+		if match(link) {
+			deleted[id] = true
+			delete(d.links, id)
+			delete(d.linkTags, id)
+		}
+	}
+	if len(deleted) == 0 {
+		return
+	}
+
+	events := d.clickEvents[:0:0]
+	for _, event := range d.clickEvents {
+		if !deleted[event.LinkID] {
+			events = append(events, event)
+		}
+	}
+	d.clickEvents = events
+}
+
+func (d *memoryData) linkByShortCode(shortCode string) *models.Link {
+	for _, link := range d.links {
+		if link.ShortCode == shortCode {
+			return link
+		}
+	}
+	return nil
+}
+
+// setLinkColumn copies one column, named as in the database, from src to dst
+func setLinkColumn(dst, src *models.Link, column string) error {
+	switch column {
+	case "user_id":
+		dst.UserID = src.UserID
+	case "workspace_id":
+		dst.WorkspaceID = src.WorkspaceID
+	case "short_code":
+		dst.ShortCode = src.ShortCode
+	case "target_url":
+		dst.TargetURL = src.TargetURL
+	case "target_host":
+		dst.TargetHost = src.TargetHost
+	case "title":
+		dst.Title = src.Title
+	case "is_active":
+		dst.IsActive = src.IsActive
+	case "click_count":
+		dst.ClickCount = src.ClickCount
+	case "last_clicked_at":
+		dst.LastClickedAt = src.LastClickedAt
+	case "expires_at":
+		dst.ExpiresAt = src.ExpiresAt
+	case "max_clicks":
+		dst.MaxClicks = src.MaxClicks
+	case "password_hash":
+		dst.PasswordHash = src.PasswordHash
+	case "disabled_at":
+		dst.DisabledAt = src.DisabledAt
+	case "folder_id":
+		dst.FolderID = src.FolderID
+	case "updated_at":
+		dst.UpdatedAt = src.UpdatedAt
+	default:
+		return fmt.Errorf("unknown link column %q", column)
+	}
+	return nil
+}
+
+// matchesSearch reports whether link matches search like the SQLite backend
+func (d *memoryData) matchesSearch(link *models.Link, search *LinkSearch) bool {
+	for _, term := range search.Terms {
+		if searchWeight(link, term) == 0 {
+			return false
+		}
+	}
+
+	if len(search.Domains) > 0 {
+		found := false
+		for _, domain := range search.Domains {
+			if link.TargetHost == domain || strings.HasSuffix(link.TargetHost, "."+domain) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(search.Tags) > 0 && !hasTags(d.linkTagNames(link.ID), search.Tags, true) {
+		return false
+	}
+
+	if search.MinClicks != nil && link.ClickCount < *search.MinClicks {
+		return false
+	}
+	if search.MaxClicks != nil && link.ClickCount > *search.MaxClicks {
+		return false
+	}
+
+	if !search.Created.Contains(link.CreatedAt) {
+		return false
+	}
+	if search.Clicked.IsSet() && (link.LastClickedAt == nil || !search.Clicked.Contains(*link.LastClickedAt)) {
+		return false
+	}
+
+	return true
+}
+
+// searchWeight weighs a search term found in the short code 3, in the title
+// 2 and in the target URL 1, and adds up the weights
+func searchWeight(link *models.Link, term string) int {
+	weight := 0
+	if strings.Contains(strings.ToLower(link.ShortCode), term) {
+		weight += 3
+	}
+	if link.Title != nil && strings.Contains(strings.ToLower(*link.Title), term) {
+		weight += 2
+	}
+	if strings.Contains(strings.ToLower(link.TargetURL), term) {
+		weight++
+	}
+	return weight
+}
+
+// hasTags reports whether names contains any of tags, or all of them
+func hasTags(names map[string]bool, tags []string, all bool) bool {
+	for _, tag := range tags {
+		if names[tag] != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// lessLink orders links like the SQL backends: by the sort column, with NULL
+// titles and last_clicked_at values last
+func lessLink(a, b *models.Link, column string, desc bool) bool {
+	less := func(x, y bool) bool {
+		if desc {
+			return y
+		}
+		return x
+	}
+
+	switch column {
+	case "created_at":
+		return less(a.CreatedAt.Before(b.CreatedAt), b.CreatedAt.Before(a.CreatedAt))
+	case "updated_at":
+		return less(a.UpdatedAt.Before(b.UpdatedAt), b.UpdatedAt.Before(a.UpdatedAt))
+	case "short_code":
+		return less(a.ShortCode < b.ShortCode, b.ShortCode < a.ShortCode)
+	case "click_count":
+		return less(a.ClickCount < b.ClickCount, b.ClickCount < a.ClickCount)
+	case "title":
+		if a.Title == nil || b.Title == nil {
+			return a.Title != nil && b.Title == nil
+		}
+		return less(*a.Title < *b.Title, *b.Title < *a.Title)
+	case "last_clicked_at":
+		if a.LastClickedAt == nil || b.LastClickedAt == nil {
+			return a.LastClickedAt != nil && b.LastClickedAt == nil
+		}
+		return less(a.LastClickedAt.Before(*b.LastClickedAt), b.LastClickedAt.Before(*a.LastClickedAt))
+	}
+	return false
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
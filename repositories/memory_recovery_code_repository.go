@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryRecoveryCodeRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryRecoveryCodeRepository) ReplaceForUser(userID uuid.UUID, codeHashes []string) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.users[userID]; !ok {
+			return ErrNotFound
+		}
+
+		for id, stored := range d.recoveryCodes {
+			if stored.UserID == userID {
+				delete(d.recoveryCodes, id)
+			}
+		}
+
+		now := time.Now()
+		for _, codeHash := range codeHashes {
+			code := &models.RecoveryCode{
+				ID:        uuid.New(),
+				UserID:    userID,
+				CodeHash:  codeHash,
+				CreatedAt: now,
+			}
+			d.recoveryCodes[code.ID] = code
+		}
+		return nil
+	})
+}
+
+func (r *memoryRecoveryCodeRepository) Use(userID uuid.UUID, codeHash string, at time.Time) (bool, error) {
+	var used bool
+	err := r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.recoveryCodes {
+			if stored.UserID != userID || stored.CodeHash != codeHash || stored.UsedAt != nil {
+				continue
+			}
+
+			updated := *stored
+			updated.UsedAt = &at
+			d.recoveryCodes[id] = &updated
+			used = true
+			return nil
+		}
+		return nil
+	})
+	return used, err
+}
+
+func (r *memoryRecoveryCodeRepository) CountUnused(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.recoveryCodes {
+			if stored.UserID == userID && stored.UsedAt == nil {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *memoryRecoveryCodeRepository) DeleteByUser(userID uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.recoveryCodes {
+			if stored.UserID == userID {
+				delete(d.recoveryCodes, id)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryRefreshTokenRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.users[token.UserID]; !ok {
+			return ErrNotFound
+		}
+		for _, existing := range d.refreshTokens {
+			if existing.TokenHash == token.TokenHash {
+				return ErrDuplicate
+			}
+		}
+
+		if token.ID == uuid.Nil {
+			token.ID = uuid.New()
+		}
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+
+		stored := *token
+		stored.User = models.User{}
+		d.refreshTokens[token.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryRefreshTokenRepository) FindByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.refreshTokens {
+			if stored.TokenHash == tokenHash {
+				token = *stored
+				if user, ok := d.users[stored.UserID]; ok {
+					token.User = *user
+				}
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeByHash(tokenHash string) error {
+	return r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.refreshTokens {
+			if stored.TokenHash == tokenHash {
+				updated := *stored
+				updated.Revoked = true
+				d.refreshTokens[id] = &updated
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (r *memoryRefreshTokenRepository) RevokeIfActive(id uuid.UUID) (bool, error) {
+	var revoked bool
+	err := r.conn.write(func(d *memoryData) error {
+		stored, ok := d.refreshTokens[id]
+		if !ok || stored.Revoked {
+			return nil
+		}
+
+		updated := *stored
+		updated.Revoked = true
+		d.refreshTokens[id] = &updated
+		revoked = true
+		return nil
+	})
+	return revoked, err
+}
+
+func (r *memoryRefreshTokenRepository) RevokeFamily(familyID uuid.UUID) (int64, error) {
+	var revoked int64
+	err := r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.refreshTokens {
+			if stored.FamilyID == familyID && !stored.Revoked {
+				updated := *stored
+				updated.Revoked = true
+				d.refreshTokens[id] = &updated
+				revoked++
+			}
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+func (r *memoryRefreshTokenRepository) RevokeAllForUser(userID uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.refreshTokens {
+			if stored.UserID == userID && !stored.Revoked {
+				updated := *stored
+				updated.Revoked = true
+				d.refreshTokens[id] = &updated
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryRefreshTokenRepository) ListActiveByUser(userID uuid.UUID, now time.Time) ([]models.RefreshToken, error) {
+	tokens := []models.RefreshToken{}
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.refreshTokens {
+			if stored.UserID == userID && !stored.Revoked && stored.ExpiresAt.After(now) {
+				tokens = append(tokens, *stored)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return lastUsed(tokens[i]).After(lastUsed(tokens[j]))
+	})
+	return tokens, nil
+}
+
+func lastUsed(token models.RefreshToken) time.Time {
+	if token.LastUsedAt != nil {
+		return *token.LastUsedAt
+	}
+	return token.CreatedAt
+}
+
+func (r *memoryRefreshTokenRepository) DeleteExpired(before time.Time) error {
+	return r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.refreshTokens {
+			if stored.ExpiresAt.Before(before) {
+				delete(d.refreshTokens, id)
+			}
+		}
+		return nil
+	})
+}
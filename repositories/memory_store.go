@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+// memoryData holds the records of the in-memory backend. Stored records are
+// never modified in place: updates store a modified copy, so cloning the data
+// for a transaction only has to copy the maps.
+type memoryData struct {
+	users         map[uuid.UUID]*models.User
+	links         map[uuid.UUID]*models.Link
+	refreshTokens map[uuid.UUID]*models.RefreshToken
+	apiKeys       map[uuid.UUID]*models.APIKey
+	emailTokens   map[uuid.UUID]*models.EmailToken
+	recoveryCodes map[uuid.UUID]*models.RecoveryCode
+	clickEvents   []*models.ClickEvent
+	workspaces    map[uuid.UUID]*models.Workspace
+	members       map[memberKey]*models.WorkspaceMember
+	invitations   map[uuid.UUID]*models.WorkspaceInvitation
+	tags          map[uuid.UUID]*models.Tag
+	folders       map[uuid.UUID]*models.Folder
+	// linkTags holds the tag IDs of each link. The slices are replaced, not
+	// modified.
+	linkTags map[uuid.UUID][]uuid.UUID
+}
+
+// memberKey is the primary key of a workspace membership
+type memberKey struct {
+	workspaceID uuid.UUID
+	userID      uuid.UUID
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		users:         make(map[uuid.UUID]*models.User),
+		links:         make(map[uuid.UUID]*models.Link),
+		refreshTokens: make(map[uuid.UUID]*models.RefreshToken),
+		apiKeys:       make(map[uuid.UUID]*models.APIKey),
+		emailTokens:   make(map[uuid.UUID]*models.EmailToken),
+		recoveryCodes: make(map[uuid.UUID]*models.RecoveryCode),
+		workspaces:    make(map[uuid.UUID]*models.Workspace),
+		members:       make(map[memberKey]*models.WorkspaceMember),
+		invitations:   make(map[uuid.UUID]*models.WorkspaceInvitation),
+		tags:          make(map[uuid.UUID]*models.Tag),
+		folders:       make(map[uuid.UUID]*models.Folder),
+		linkTags:      make(map[uuid.UUID][]uuid.UUID),
+	}
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		users:         make(map[uuid.UUID]*models.User, len(d.users)),
+		links:         make(map[uuid.UUID]*models.Link, len(d.links)),
+		refreshTokens: make(map[uuid.UUID]*models.RefreshToken, len(d.refreshTokens)),
+		apiKeys:       make(map[uuid.UUID]*models.APIKey, len(d.apiKeys)),
+		emailTokens:   make(map[uuid.UUID]*models.EmailToken, len(d.emailTokens)),
+		recoveryCodes: make(map[uuid.UUID]*models.RecoveryCode, len(d.recoveryCodes)),
+		clickEvents:   d.clickEvents[:len(d.clickEvents):len(d.clickEvents)],
+		workspaces:    make(map[uuid.UUID]*models.Workspace, len(d.workspaces)),
+		members:       make(map[memberKey]*models.WorkspaceMember, len(d.members)),
+		invitations:   make(map[uuid.UUID]*models.WorkspaceInvitation, len(d.invitations)),
+		tags:          make(map[uuid.UUID]*models.Tag, len(d.tags)),
+		folders:       make(map[uuid.UUID]*models.Folder, len(d.folders)),
+		linkTags:      make(map[uuid.UUID][]uuid.UUID, len(d.linkTags)),
+	}
+	for id, user := range d.users {
+		c.users[id] = user
+	}
+	for id, link := range d.links {
+		c.links[id] = link
+	}
+	for id, token := range d.refreshTokens {
+		c.refreshTokens[id] = token
+	}
+	for id, key := range d.apiKeys {
+		c.apiKeys[id] = key
+	}
+	for id, token := range d.emailTokens {
+		c.emailTokens[id] = token
+	}
+	for id, code := range d.recoveryCodes {
+		c.recoveryCodes[id] = code
+	}
+	for id, workspace := range d.workspaces {
+		c.workspaces[id] = workspace
+	}
+	for key, member := range d.members {
+		c.members[key] = member
+	}
+	for id, invitation := range d.invitations {
+		c.invitations[id] = invitation
+	}
+	for id, tag := range d.tags {
+		c.tags[id] = tag
+	}
+	for id, folder := range d.folders {
+		c.folders[id] = folder
+	}
+	for id, tagIDs := range d.linkTags {
+		c.linkTags[id] = tagIDs
+	}
+	return c
+}
+
+type memoryDB struct {
+	mutex sync.RWMutex
+	data  *memoryData
+}
+
+// memoryConn gives repositories access to the data, either directly under the
+// database lock or, inside a transaction, to the transaction's private copy
+type memoryConn struct {
+	db *memoryDB
+	tx *memoryData
+}
+
+func (c *memoryConn) read(fn func(d *memoryData) error) error {
+	if c.tx != nil {
+		return fn(c.tx)
+	}
+
+	c.db.mutex.RLock()
+	defer c.db.mutex.RUnlock()
+	return fn(c.db.data)
+}
+
+func (c *memoryConn) write(fn func(d *memoryData) error) error {
+	if c.tx != nil {
+		return fn(c.tx)
+	}
+
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	return fn(c.db.data)
+}
+
+// NewMemoryStore returns a Store that keeps all records in process memory.
+// Everything is lost when the process exits.
+func NewMemoryStore() *Store {
+	return newMemoryStore(&memoryConn{db: &memoryDB{data: newMemoryData()}})
+}
+
+func newMemoryStore(conn *memoryConn) *Store {
+	store := &Store{
+		Users:                &memoryUserRepository{conn: conn},
+		Links:                &memoryLinkRepository{conn: conn},
+		RefreshTokens:        &memoryRefreshTokenRepository{conn: conn},
+		APIKeys:              &memoryAPIKeyRepository{conn: conn},
+		EmailTokens:          &memoryEmailTokenRepository{conn: conn},
+		RecoveryCodes:        &memoryRecoveryCodeRepository{conn: conn},
+		ClickEvents:          &memoryClickEventRepository{conn: conn},
+		Workspaces:           &memoryWorkspaceRepository{conn: conn},
+		WorkspaceInvitations: &memoryWorkspaceInvitationRepository{conn: conn},
+		Tags:                 &memoryTagRepository{conn: conn},
+		Folders:              &memoryFolderRepository{conn: conn},
+	}
+
+	store.transaction = func(fn func(tx *Store) error) error {
+		if conn.tx != nil {
+			return fn(store)
+		}
+
+		conn.db.mutex.Lock()
+		defer conn.db.mutex.Unlock()
+
+		tx := conn.db.data.clone()
+		if err := fn(newMemoryStore(&memoryConn{db: conn.db, tx: tx})); err != nil {
+			return err
+		}
+
+		conn.db.data = tx
+		return nil
+	}
+
+	store.ping = func() error {
+		return nil
+	}
+
+	return store
+}
@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryTagRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryTagRepository) Create(tag *models.Tag) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.workspaces[tag.WorkspaceID]; !ok {
+			return ErrNotFound
+		}
+		if d.tagByName(tag.WorkspaceID, tag.Name) != nil {
+			return ErrDuplicate
+		}
+
+		if tag.ID == uuid.Nil {
+			tag.ID = uuid.New()
+		}
+		if tag.CreatedAt.IsZero() {
+			tag.CreatedAt = time.Now()
+		}
+
+		stored := *tag
+		stored.Workspace = models.Workspace{}
+		d.tags[tag.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryTagRepository) FindByID(id uuid.UUID) (*models.Tag, error) {
+	var tag models.Tag
+	err := r.conn.read(func(d *memoryData) error {
+		stored, ok := d.tags[id]
+		if !ok {
+			return ErrNotFound
+		}
+		tag = *stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *memoryTagRepository) FindByNames(workspaceID uuid.UUID, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := r.conn.read(func(d *memoryData) error {
+		for _, name := range names {
+			if tag := d.tagByName(workspaceID, name); tag != nil {
+				tags = append(tags, *tag)
+			}
+		}
+		return nil
+	})
+	return tags, err
+}
+
+func (r *memoryTagRepository) List(workspaceID uuid.UUID) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	err := r.conn.read(func(d *memoryData) error {
+		for _, tag := range d.tags {
+			if tag.WorkspaceID == workspaceID {
+				tags = append(tags, *tag)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (r *memoryTagRepository) Update(tag *models.Tag, columns ...string) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.tags[tag.ID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		updated := *stored
+		for _, column := range columns {
+			switch column {
+			case "name":
+				if existing := d.tagByName(stored.WorkspaceID, tag.Name); existing != nil && existing.ID != tag.ID {
+					return ErrDuplicate
+				}
+				updated.Name = tag.Name
+			default:
+				return fmt.Errorf("unknown tag column %q", column)
+			}
+		}
+
+		d.tags[tag.ID] = &updated
+		return nil
+	})
+}
+
+func (r *memoryTagRepository) Delete(id uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.tags[id]; !ok {
+			return ErrNotFound
+		}
+
+		d.deleteTags(func(tag *models.Tag) bool {
+			return tag.ID == id
+		})
+		return nil
+	})
+}
+
+// deleteTags removes the matching tags and mirrors ON DELETE CASCADE on
+// link_tags
+func (d *memoryData) deleteTags(match func(tag *models.Tag) bool) {
+	deleted := make(map[uuid.UUID]bool)
+	for id, tag := range d.tags {
+		if match(tag) {
+			deleted[id] = true
+			delete(d.tags, id)
+		}
+	}
+	if len(deleted) == 0 {
+		return
+	}
+
+	for linkID, tagIDs := range d.linkTags {
+		kept := make([]uuid.UUID, 0, len(tagIDs))
+		for _, tagID := range tagIDs {
+			if !deleted[tagID] {
+				kept = append(kept, tagID)
+			}
+		}
+		if len(kept) != len(tagIDs) {
+			d.linkTags[linkID] = kept
+		}
+	}
+}
+
+func (d *memoryData) tagByName(workspaceID uuid.UUID, name string) *models.Tag {
+	for _, tag := range d.tags {
+		if tag.WorkspaceID == workspaceID && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// linkTagNames returns the tag names of a link
+func (d *memoryData) linkTagNames(linkID uuid.UUID) map[string]bool {
+	names := make(map[string]bool, len(d.linkTags[linkID]))
+	for _, tagID := range d.linkTags[linkID] {
+		if tag, ok := d.tags[tagID]; ok {
+			names[tag.Name] = true
+		}
+	}
+	return names
+}
+
+// withTags returns a copy of link with its Tags loaded
+func (d *memoryData) withTags(link *models.Link) models.Link {
+	loaded := *link
+	loaded.Tags = nil
+	for _, tagID := range d.linkTags[link.ID] {
+		if tag, ok := d.tags[tagID]; ok {
+			loaded.Tags = append(loaded.Tags, *tag)
+		}
+	}
+	return loaded
+}
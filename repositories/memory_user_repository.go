@@ -0,0 +1,253 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryUserRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryUserRepository) Create(user *models.User) error {
+	return r.conn.write(func(d *memoryData) error {
+		for _, existing := range d.users {
+			if existing.Email == user.Email {
+				return ErrDuplicate
+			}
+		}
+
+		if user.ID == uuid.Nil {
+			user.ID = uuid.New()
+		}
+		now := time.Now()
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = now
+		}
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = now
+		}
+		if user.Role == "" {
+			user.Role = models.RoleUser
+		}
+
+		stored := *user
+		d.users[user.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := r.conn.read(func(d *memoryData) error {
+		stored, ok := d.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		user = *stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.users {
+			if stored.Email == email {
+				user = *stored
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) Update(user *models.User, columns ...string) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.users[user.ID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		for _, column := range columns {
+			if column != "email" {
+				continue
+			}
+			for id, existing := range d.users {
+				if id != user.ID && existing.Email == user.Email {
+					return ErrDuplicate
+				}
+			}
+		}
+
+		updated := *stored
+		for _, column := range columns {
+			if err := setUserColumn(&updated, user, column); err != nil {
+				return err
+			}
+		}
+
+		d.users[user.ID] = &updated
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) Delete(id uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.users[id]; !ok {
+			return ErrNotFound
+		}
+		delete(d.users, id)
+
+		// Mirror ON DELETE CASCADE and SET NULL
+		d.deleteLinks(func(link *models.Link) bool {
+			return link.UserID == id
+		})
+
+		for tokenID, token := range d.refreshTokens {
+			if token.UserID == id {
+				delete(d.refreshTokens, tokenID)
+			}
+		}
+		for keyID, key := range d.apiKeys {
+			if key.UserID == id {
+				delete(d.apiKeys, keyID)
+			}
+		}
+		for tokenID, token := range d.emailTokens {
+			if token.UserID == id {
+				delete(d.emailTokens, tokenID)
+			}
+		}
+		for codeID, code := range d.recoveryCodes {
+			if code.UserID == id {
+				delete(d.recoveryCodes, codeID)
+			}
+		}
+		for key := range d.members {
+			if key.userID == id {
+				delete(d.members, key)
+			}
+		}
+		for invitationID, invitation := range d.invitations {
+			if invitation.InvitedBy != nil && *invitation.InvitedBy == id {
+				updated := *invitation
+				updated.InvitedBy = nil
+				d.invitations[invitationID] = &updated
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) UseTOTPStep(id uuid.UUID, step int64) (bool, error) {
+	var used bool
+	err := r.conn.write(func(d *memoryData) error {
+		stored, ok := d.users[id]
+		if !ok || (stored.TOTPLastStep != nil && *stored.TOTPLastStep >= step) {
+			return nil
+		}
+
+		updated := *stored
+		updated.TOTPLastStep = &step
+		d.users[id] = &updated
+		used = true
+		return nil
+	})
+	return used, err
+}
+
+func (r *memoryUserRepository) List(filter UserFilter) ([]models.User, int64, error) {
+	var matched []models.User
+	err := r.conn.read(func(d *memoryData) error {
+		query := strings.ToLower(filter.Query)
+		for _, user := range d.users {
+			if query != "" && !strings.Contains(strings.ToLower(user.Email), query) {
+				continue
+			}
+			if filter.Role != "" && user.Role != filter.Role {
+				continue
+			}
+			if filter.Suspended != nil && user.IsSuspended() != *filter.Suspended {
+				continue
+			}
+			matched = append(matched, *user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].ID.String() < matched[j].ID.String()
+	})
+
+	return paginate(matched, filter.Limit, filter.Offset), int64(len(matched)), nil
+}
+
+func (r *memoryUserRepository) Stats() (*models.UserStats, error) {
+	var stats models.UserStats
+	err := r.conn.read(func(d *memoryData) error {
+		for _, user := range d.users {
+			stats.Total++
+			if user.IsEmailVerified() {
+				stats.Verified++
+			}
+			if user.IsSuspended() {
+				stats.Suspended++
+			}
+			if user.IsAdmin() {
+				stats.Admins++
+			}
+		}
+		return nil
+	})
+	return &stats, err
+}
+
+// setUserColumn copies one column, named as in the database, from src to dst
+func setUserColumn(dst, src *models.User, column string) error {
+	switch column {
+	case "email":
+		dst.Email = src.Email
+	case "password":
+		dst.Password = src.Password
+	case "email_verified_at":
+		dst.EmailVerifiedAt = src.EmailVerifiedAt
+	case "pending_email":
+		dst.PendingEmail = src.PendingEmail
+	case "totp_secret":
+		dst.TOTPSecret = src.TOTPSecret
+	case "totp_enabled_at":
+		dst.TOTPEnabledAt = src.TOTPEnabledAt
+	case "totp_last_step":
+		dst.TOTPLastStep = src.TOTPLastStep
+	case "role":
+		dst.Role = src.Role
+	case "suspended_at":
+		dst.SuspendedAt = src.SuspendedAt
+	case "updated_at":
+		dst.UpdatedAt = src.UpdatedAt
+	default:
+		return fmt.Errorf("unknown user column %q", column)
+	}
+	return nil
+}
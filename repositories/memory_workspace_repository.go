@@ -0,0 +1,346 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+type memoryWorkspaceRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryWorkspaceRepository) Create(workspace *models.Workspace) error {
+	return r.conn.write(func(d *memoryData) error {
+		if workspace.ID == uuid.Nil {
+			workspace.ID = uuid.New()
+		}
+		if _, ok := d.workspaces[workspace.ID]; ok {
+			return ErrDuplicate
+		}
+
+		now := time.Now()
+		if workspace.CreatedAt.IsZero() {
+			workspace.CreatedAt = now
+		}
+		if workspace.UpdatedAt.IsZero() {
+			workspace.UpdatedAt = now
+		}
+
+		stored := *workspace
+		d.workspaces[workspace.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryWorkspaceRepository) FindByID(id uuid.UUID) (*models.Workspace, error) {
+	var workspace models.Workspace
+	err := r.conn.read(func(d *memoryData) error {
+		stored, ok := d.workspaces[id]
+		if !ok {
+			return ErrNotFound
+		}
+		workspace = *stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+func (r *memoryWorkspaceRepository) Update(workspace *models.Workspace, columns ...string) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.workspaces[workspace.ID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		updated := *stored
+		for _, column := range columns {
+			switch column {
+			case "name":
+				updated.Name = workspace.Name
+			case "updated_at":
+				updated.UpdatedAt = workspace.UpdatedAt
+			default:
+				return fmt.Errorf("unknown workspace column %q", column)
+			}
+		}
+
+		d.workspaces[workspace.ID] = &updated
+		return nil
+	})
+}
+
+func (r *memoryWorkspaceRepository) Delete(id uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.workspaces[id]; !ok {
+			return ErrNotFound
+		}
+		delete(d.workspaces, id)
+
+		// Mirror ON DELETE CASCADE
+		d.deleteLinks(func(link *models.Link) bool {
+			return link.WorkspaceID == id
+		})
+		for key := range d.members {
+			if key.workspaceID == id {
+				delete(d.members, key)
+			}
+		}
+		for invitationID, invitation := range d.invitations {
+			if invitation.WorkspaceID == id {
+				delete(d.invitations, invitationID)
+			}
+		}
+		d.deleteTags(func(tag *models.Tag) bool {
+			return tag.WorkspaceID == id
+		})
+		d.deleteFolders(func(folder *models.Folder) bool {
+			return folder.WorkspaceID == id
+		})
+
+		return nil
+	})
+}
+
+func (r *memoryWorkspaceRepository) AddMember(member *models.WorkspaceMember) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.workspaces[member.WorkspaceID]; !ok {
+			return ErrNotFound
+		}
+		if _, ok := d.users[member.UserID]; !ok {
+			return ErrNotFound
+		}
+
+		key := memberKey{workspaceID: member.WorkspaceID, userID: member.UserID}
+		if _, ok := d.members[key]; ok {
+			return ErrDuplicate
+		}
+
+		if member.CreatedAt.IsZero() {
+			member.CreatedAt = time.Now()
+		}
+
+		stored := *member
+		stored.Workspace = models.Workspace{}
+		stored.User = models.User{}
+		d.members[key] = &stored
+		return nil
+	})
+}
+
+func (r *memoryWorkspaceRepository) FindMember(workspaceID, userID uuid.UUID) (*models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	err := r.conn.read(func(d *memoryData) error {
+		stored, ok := d.members[memberKey{workspaceID: workspaceID, userID: userID}]
+		if !ok {
+			return ErrNotFound
+		}
+		member = *stored
+		if workspace, ok := d.workspaces[workspaceID]; ok {
+			member.Workspace = *workspace
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *memoryWorkspaceRepository) ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error) {
+	members := []models.WorkspaceMember{}
+	err := r.conn.read(func(d *memoryData) error {
+		for key, stored := range d.members {
+			if key.workspaceID != workspaceID {
+				continue
+			}
+			member := *stored
+			if user, ok := d.users[key.userID]; ok {
+				member.User = *user
+			}
+			members = append(members, member)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortMembers(members, func(m *models.WorkspaceMember) string { return m.UserID.String() })
+	return members, nil
+}
+
+func (r *memoryWorkspaceRepository) ListMemberships(userID uuid.UUID) ([]models.WorkspaceMember, error) {
+	members := []models.WorkspaceMember{}
+	err := r.conn.read(func(d *memoryData) error {
+		for key, stored := range d.members {
+			if key.userID != userID {
+				continue
+			}
+			member := *stored
+			if workspace, ok := d.workspaces[key.workspaceID]; ok {
+				member.Workspace = *workspace
+			}
+			members = append(members, member)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortMembers(members, func(m *models.WorkspaceMember) string { return m.WorkspaceID.String() })
+	return members, nil
+}
+
+func (r *memoryWorkspaceRepository) UpdateMemberRole(workspaceID, userID uuid.UUID, role string) error {
+	return r.conn.write(func(d *memoryData) error {
+		key := memberKey{workspaceID: workspaceID, userID: userID}
+		stored, ok := d.members[key]
+		if !ok {
+			return ErrNotFound
+		}
+
+		updated := *stored
+		updated.Role = role
+		d.members[key] = &updated
+		return nil
+	})
+}
+
+func (r *memoryWorkspaceRepository) RemoveMember(workspaceID, userID uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		key := memberKey{workspaceID: workspaceID, userID: userID}
+		if _, ok := d.members[key]; !ok {
+			return ErrNotFound
+		}
+		delete(d.members, key)
+		return nil
+	})
+}
+
+// sortMembers orders memberships oldest first, breaking ties like the SQL
+// backends by the other key column
+func sortMembers(members []models.WorkspaceMember, tieBreaker func(m *models.WorkspaceMember) string) {
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(members[j].CreatedAt)
+		}
+		return tieBreaker(&members[i]) < tieBreaker(&members[j])
+	})
+}
+
+type memoryWorkspaceInvitationRepository struct {
+	conn *memoryConn
+}
+
+func (r *memoryWorkspaceInvitationRepository) Create(invitation *models.WorkspaceInvitation) error {
+	return r.conn.write(func(d *memoryData) error {
+		if _, ok := d.workspaces[invitation.WorkspaceID]; !ok {
+			return ErrNotFound
+		}
+		for _, existing := range d.invitations {
+			if existing.TokenHash == invitation.TokenHash {
+				return ErrDuplicate
+			}
+		}
+
+		if invitation.ID == uuid.Nil {
+			invitation.ID = uuid.New()
+		}
+		if invitation.CreatedAt.IsZero() {
+			invitation.CreatedAt = time.Now()
+		}
+
+		stored := *invitation
+		stored.Workspace = models.Workspace{}
+		d.invitations[invitation.ID] = &stored
+		return nil
+	})
+}
+
+func (r *memoryWorkspaceInvitationRepository) FindByHash(tokenHash string) (*models.WorkspaceInvitation, error) {
+	var invitation models.WorkspaceInvitation
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.invitations {
+			if stored.TokenHash == tokenHash {
+				invitation = *stored
+				if workspace, ok := d.workspaces[stored.WorkspaceID]; ok {
+					invitation.Workspace = *workspace
+				}
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *memoryWorkspaceInvitationRepository) ListPending(workspaceID uuid.UUID, now time.Time) ([]models.WorkspaceInvitation, error) {
+	invitations := []models.WorkspaceInvitation{}
+	err := r.conn.read(func(d *memoryData) error {
+		for _, stored := range d.invitations {
+			if stored.WorkspaceID == workspaceID && stored.AcceptedAt == nil && stored.ExpiresAt.After(now) {
+				invitations = append(invitations, *stored)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+	})
+
+	return invitations, nil
+}
+
+func (r *memoryWorkspaceInvitationRepository) Accept(id uuid.UUID, at time.Time) (bool, error) {
+	var accepted bool
+	err := r.conn.write(func(d *memoryData) error {
+		stored, ok := d.invitations[id]
+		if !ok || stored.AcceptedAt != nil {
+			return nil
+		}
+
+		updated := *stored
+		updated.AcceptedAt = &at
+		d.invitations[id] = &updated
+		accepted = true
+		return nil
+	})
+	return accepted, err
+}
+
+func (r *memoryWorkspaceInvitationRepository) Delete(workspaceID, id uuid.UUID) error {
+	return r.conn.write(func(d *memoryData) error {
+		stored, ok := d.invitations[id]
+		if !ok || stored.WorkspaceID != workspaceID {
+			return ErrNotFound
+		}
+		delete(d.invitations, id)
+		return nil
+	})
+}
+
+func (r *memoryWorkspaceInvitationRepository) DeleteExpired(before time.Time) error {
+	return r.conn.write(func(d *memoryData) error {
+		for id, stored := range d.invitations {
+			if stored.ExpiresAt.Before(before) {
+				delete(d.invitations, id)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,379 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+type UserRepository interface {
+	Create(user *models.User) error
+	FindByID(id uuid.UUID) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	// Update writes the given columns of user
+	Update(user *models.User, columns ...string) error
+	// UseTOTPStep records a TOTP time step as used and reports whether it was
+	// newer than every step used before
+	UseTOTPStep(id uuid.UUID, step int64) (bool, error)
+	// Delete removes a user together with their links, tokens and API keys
+	Delete(id uuid.UUID) error
+	// List returns users matching the filter, newest first
+	List(filter UserFilter) ([]models.User, int64, error)
+	Stats() (*models.UserStats, error)
+}
+
+type RecoveryCodeRepository interface {
+	// ReplaceForUser deletes the recovery codes of a user and stores new ones
+	ReplaceForUser(userID uuid.UUID, codeHashes []string) error
+	// Use marks an unused recovery code of the user as used and reports
+	// whether it found one
+	Use(userID uuid.UUID, codeHash string, at time.Time) (bool, error)
+	CountUnused(userID uuid.UUID) (int64, error)
+	DeleteByUser(userID uuid.UUID) error
+}
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	// FindByHash returns the token with its User loaded
+	FindByHash(tokenHash string) (*models.RefreshToken, error)
+	RevokeByHash(tokenHash string) error
+	// RevokeIfActive revokes a token unless it is already revoked and reports
+	// whether this call revoked it
+	RevokeIfActive(id uuid.UUID) (bool, error)
+	// RevokeFamily revokes the unrevoked tokens of a family and returns how
+	// many it revoked
+	RevokeFamily(familyID uuid.UUID) (int64, error)
+	RevokeAllForUser(userID uuid.UUID) error
+	// ListActiveByUser returns the unrevoked, unexpired tokens of a user, most
+	// recently used first
+	ListActiveByUser(userID uuid.UUID, now time.Time) ([]models.RefreshToken, error)
+	DeleteExpired(before time.Time) error
+}
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	// FindByHash returns the key with its User loaded
+	FindByHash(keyHash string) (*models.APIKey, error)
+	FindByID(id uuid.UUID) (*models.APIKey, error)
+	// ListByUser returns the keys of a user, newest first
+	ListByUser(userID uuid.UUID) ([]models.APIKey, error)
+	Revoke(id uuid.UUID, at time.Time) error
+	Touch(id uuid.UUID, at time.Time) error
+}
+
+type EmailTokenRepository interface {
+	Create(token *models.EmailToken) error
+	// FindByHash returns the token with its User loaded
+	FindByHash(tokenHash string) (*models.EmailToken, error)
+	// Use marks a token as used unless it already is and reports whether this
+	// call used it
+	Use(id uuid.UUID, at time.Time) (bool, error)
+	// UseAllForUser marks the unused tokens of a user for one purpose as used
+	UseAllForUser(userID uuid.UUID, purpose string, at time.Time) error
+	DeleteExpired(before time.Time) error
+}
+
+type LinkRepository interface {
+	Create(link *models.Link) error
+	// FindByID returns the link with its Tags loaded
+	FindByID(id uuid.UUID) (*models.Link, error)
+	FindByShortCode(shortCode string) (*models.Link, error)
+	ShortCodeExists(shortCode string) (bool, error)
+	// Update writes the given columns of link
+	Update(link *models.Link, columns ...string) error
+	Delete(id uuid.UUID) error
+	// SetTags replaces the tags of a link
+	SetTags(linkID uuid.UUID, tagIDs []uuid.UUID) error
+	// List returns the links matching filter with their Tags loaded
+	List(filter LinkFilter) ([]models.Link, int64, error)
+	// Each calls fn with every link matching filter, in the order of the
+	// filter, without loading all of them at once. Limit, Offset and
+	// SkipTotal are ignored. Iteration stops at the first error returned by fn.
+	Each(filter LinkFilter, fn func(link *models.Link) error) error
+	CountByUser(userID uuid.UUID) (total int64, active int64, err error)
+	CountByWorkspace(workspaceID uuid.UUID) (int64, error)
+	ShortCodesByWorkspace(workspaceID uuid.UUID) ([]string, error)
+	// ReassignCreator makes toUserID the creator of the links that fromUserID
+	// created in a workspace
+	ReassignCreator(workspaceID, fromUserID, toUserID uuid.UUID) error
+	// ClaimClick counts one click if the link is neither expired nor out of
+	// click budget at the given time. The check and the increment are atomic.
+	ClaimClick(shortCode string, at time.Time) (bool, error)
+	// AddClicks counts clicks without checking expiry or click budget
+	AddClicks(shortCode string, clicks int64, lastClickedAt time.Time) error
+	Stats() (*models.LinkStats, error)
+}
+
+type TagRepository interface {
+	Create(tag *models.Tag) error
+	FindByID(id uuid.UUID) (*models.Tag, error)
+	// FindByNames returns the tags of a workspace with one of the names
+	FindByNames(workspaceID uuid.UUID, names []string) ([]models.Tag, error)
+	// List returns the tags of a workspace ordered by name
+	List(workspaceID uuid.UUID) ([]models.Tag, error)
+	// Update writes the given columns of tag
+	Update(tag *models.Tag, columns ...string) error
+	// Delete removes a tag from the links that have it and deletes it
+	Delete(id uuid.UUID) error
+}
+
+type FolderRepository interface {
+	Create(folder *models.Folder) error
+	FindByID(id uuid.UUID) (*models.Folder, error)
+	// List returns the folders of a workspace ordered by name
+	List(workspaceID uuid.UUID) ([]models.Folder, error)
+	// Update writes the given columns of folder
+	Update(folder *models.Folder, columns ...string) error
+	// Delete removes a folder together with its subfolders. Their links are
+	// kept, outside of any folder.
+	Delete(id uuid.UUID) error
+}
+
+type WorkspaceRepository interface {
+	Create(workspace *models.Workspace) error
+	FindByID(id uuid.UUID) (*models.Workspace, error)
+	// Update writes the given columns of workspace
+	Update(workspace *models.Workspace, columns ...string) error
+	// Delete removes a workspace together with its links, tags, folders,
+	// members and invitations
+	Delete(id uuid.UUID) error
+	AddMember(member *models.WorkspaceMember) error
+	// FindMember returns a membership with its Workspace loaded
+	FindMember(workspaceID, userID uuid.UUID) (*models.WorkspaceMember, error)
+	// ListMembers returns the members of a workspace with their User loaded,
+	// oldest first
+	ListMembers(workspaceID uuid.UUID) ([]models.WorkspaceMember, error)
+	// ListMemberships returns the memberships of a user with their Workspace
+	// loaded, oldest first
+	ListMemberships(userID uuid.UUID) ([]models.WorkspaceMember, error)
+	UpdateMemberRole(workspaceID, userID uuid.UUID, role string) error
+	RemoveMember(workspaceID, userID uuid.UUID) error
+}
+
+type WorkspaceInvitationRepository interface {
+	Create(invitation *models.WorkspaceInvitation) error
+	// FindByHash returns the invitation with its Workspace loaded
+	FindByHash(tokenHash string) (*models.WorkspaceInvitation, error)
+	// ListPending returns the unaccepted, unexpired invitations of a
+	// workspace, newest first
+	ListPending(workspaceID uuid.UUID, now time.Time) ([]models.WorkspaceInvitation, error)
+	// Accept marks an invitation as accepted unless it already is and reports
+	// whether this call accepted it
+	Accept(id uuid.UUID, at time.Time) (bool, error)
+	// Delete removes an invitation of the workspace
+	Delete(workspaceID, id uuid.UUID) error
+	DeleteExpired(before time.Time) error
+}
+
+type ClickEventRepository interface {
+	Create(events ...*models.ClickEvent) error
+	List(filter ClickEventFilter) ([]models.ClickEvent, int64, error)
+	// Totals counts the clicks and distinct visitors of a link in [from, to)
+	Totals(linkID uuid.UUID, from, to time.Time) (clicks int64, uniqueVisitors int64, err error)
+	// Buckets groups the clicks of a link in [from, to) by UTC hour, day or
+	// week. Only non-empty buckets are returned.
+	Buckets(linkID uuid.UUID, from, to time.Time, interval string) ([]models.StatsBucket, error)
+	// TopValues returns the most frequent values of a ClickEventField
+	TopValues(linkID uuid.UUID, from, to time.Time, field string, limit int) ([]models.StatsCount, error)
+}
+
+type LinkFilter struct {
+	// UserID limits the links to one creator; uuid.Nil matches every user
+	UserID uuid.UUID
+	// WorkspaceIDs limits the links to some workspaces; nil matches every
+	// workspace
+	WorkspaceIDs []uuid.UUID
+	Query        string
+	// Search limits the links to those matching a parsed search query
+	Search *LinkSearch
+	Active *bool
+	// Tags limits the links to those with any of these tag names, or with
+	// all of them when MatchAllTags is set
+	Tags         []string
+	MatchAllTags bool
+	// FolderIDs limits the links to some folders; Unfiled limits them to
+	// links outside of any folder instead
+	FolderIDs []uuid.UUID
+	Unfiled   bool
+	// SortBy is a column of linkSortColumns, or LinkSortRelevance to rank
+	// the links by how well they match Search. Relevance ignores OrderBy and
+	// After, and ranks the best matches first.
+	SortBy  string
+	OrderBy string
+	Limit   int
+	Offset  int
+	// After continues the listing after the link at this position; nil
+	// starts at the first link
+	After *LinkCursor
+	// SkipTotal skips counting the matching links, and List returns 0
+	SkipTotal bool
+}
+
+// LinkSortRelevance sorts links by how well they match LinkFilter.Search,
+// then newest first
+const LinkSortRelevance = "relevance"
+
+// LinkSearch is a parsed search query. Links match when they contain every
+// term and match every qualifier that is set.
+type LinkSearch struct {
+	// Terms are words or phrases, in lowercase, to find in the short code,
+	// title or target URL
+	Terms []string
+	// Domains limits the links to target URLs on any of these hosts or their
+	// subdomains
+	Domains []string
+	// Tags limits the links to those with all of these tag names
+	Tags      []string
+	MinClicks *int64
+	MaxClicks *int64
+	// Created and Clicked limit the creation and last click times
+	Created TimeRange
+	Clicked TimeRange
+}
+
+// TimeRange holds the times from From, inclusive, to To, exclusive. A nil
+// bound leaves that side open.
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// IsSet reports whether the range has a bound
+func (r TimeRange) IsSet() bool {
+	return r.From != nil || r.To != nil
+}
+
+// Contains reports whether t is in the range
+func (r TimeRange) Contains(t time.Time) bool {
+	return (r.From == nil || !t.Before(*r.From)) && (r.To == nil || t.Before(*r.To))
+}
+
+// LinkCursor is the position of a link in a listing: the value of the sort
+// column and the ID that breaks ties. Value is a time.Time, string or int64
+// depending on the column, and nil for NULL.
+type LinkCursor struct {
+	Value interface{}
+	ID    uuid.UUID
+}
+
+// LinkCursorAt returns the position of link in a listing sorted by column
+func LinkCursorAt(link *models.Link, column string) LinkCursor {
+	cursor := LinkCursor{ID: link.ID}
+	switch column {
+	case "created_at":
+		cursor.Value = link.CreatedAt.UTC()
+	case "updated_at":
+		cursor.Value = link.UpdatedAt.UTC()
+	case "short_code":
+		cursor.Value = link.ShortCode
+	case "click_count":
+		cursor.Value = link.ClickCount
+	case "title":
+		if link.Title != nil {
+			cursor.Value = *link.Title
+		}
+	case "last_clicked_at":
+		if link.LastClickedAt != nil {
+			cursor.Value = link.LastClickedAt.UTC()
+		}
+	}
+	return cursor
+}
+
+type UserFilter struct {
+	// Query matches part of the email, case-insensitively
+	Query     string
+	Role      string
+	Suspended *bool
+	Limit     int
+	Offset    int
+}
+
+type ClickEventFilter struct {
+	LinkID uuid.UUID
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// Click event fields that TopValues can group by
+const (
+	ClickEventReferrer = "referrer"
+	ClickEventBrowser  = "browser"
+	ClickEventCountry  = "country"
+)
+
+var clickEventFields = map[string]bool{
+	ClickEventReferrer: true,
+	ClickEventBrowser:  true,
+	ClickEventCountry:  true,
+}
+
+// TruncateToInterval returns the start of the UTC hour, day or week (starting
+// on Monday) that t falls in, matching the buckets of ClickEventRepository
+func TruncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Link columns that LinkFilter.SortBy accepts
+// linkNullableSortColumns are sorted with NULL values last in both directions
+var linkNullableSortColumns = map[string]bool{
+	"title":           true,
+	"last_clicked_at": true,
+}
+
+var linkSortColumns = map[string]bool{
+	"created_at":      true,
+	"updated_at":      true,
+	"title":           true,
+	"short_code":      true,
+	"click_count":     true,
+	"last_clicked_at": true,
+}
+
+// Store groups the repositories of one storage backend
+type Store struct {
+	Users                UserRepository
+	Links                LinkRepository
+	RefreshTokens        RefreshTokenRepository
+	APIKeys              APIKeyRepository
+	EmailTokens          EmailTokenRepository
+	RecoveryCodes        RecoveryCodeRepository
+	ClickEvents          ClickEventRepository
+	Workspaces           WorkspaceRepository
+	WorkspaceInvitations WorkspaceInvitationRepository
+	Tags                 TagRepository
+	Folders              FolderRepository
+
+	transaction func(fn func(tx *Store) error) error
+	ping        func() error
+}
+
+// Transaction runs fn with repositories that share one transaction. The
+// transaction is committed when fn returns nil and rolled back otherwise.
+func (s *Store) Transaction(fn func(tx *Store) error) error {
+	return s.transaction(fn)
+}
+
+// Ping checks that the backend is reachable
+func (s *Store) Ping() error {
+	return s.ping()
+}
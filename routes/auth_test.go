@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+type refreshResult struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Error        struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// loginUser returns the access and refresh tokens of a new login
+func loginUser(t *testing.T, app *fiber.App, email string) refreshResult {
+	t.Helper()
+
+	var tokens refreshResult
+	credentials := map[string]string{"email": email, "password": "password123"}
+	if resp := request(t, app, http.MethodPost, "/api/v1/auth/login", "", credentials, &tokens); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login: status %d", resp.StatusCode)
+	}
+	return tokens
+}
+
+func refresh(t *testing.T, app *fiber.App, refreshToken string) (int, refreshResult) {
+	t.Helper()
+
+	var result refreshResult
+	body := map[string]string{"refresh_token": refreshToken}
+	resp := request(t, app, http.MethodPost, "/api/v1/auth/refresh", "", body, &result)
+	return resp.StatusCode, result
+}
+
+func TestRefreshToken(t *testing.T) {
+	stores := map[string]func(t *testing.T) *repositories.Store{
+		"memory": func(*testing.T) *repositories.Store { return repositories.NewMemoryStore() },
+		"sqlite": newSQLiteStore,
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			app := newTestApp(t, newStore(t))
+			registerUser(t, app, "refresh@example.com")
+
+			t.Run("rotate", func(t *testing.T) {
+				first := loginUser(t, app, "refresh@example.com")
+
+				status, second := refresh(t, app, first.RefreshToken)
+				if status != http.StatusOK {
+					t.Fatalf("refresh: status %d", status)
+				}
+				if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+					t.Fatalf("refresh did not rotate the refresh token")
+				}
+				if second.AccessToken == "" {
+					t.Fatal("refresh returned no access token")
+				}
+
+				if status, _ := refresh(t, app, second.RefreshToken); status != http.StatusOK {
+					t.Fatalf("refresh with the rotated token: status %d", status)
+				}
+			})
+
+			t.Run("reuse revokes the family", func(t *testing.T) {
+				first := loginUser(t, app, "refresh@example.com")
+				_, second := refresh(t, app, first.RefreshToken)
+
+				status, reused := refresh(t, app, first.RefreshToken)
+				if status != http.StatusUnauthorized {
+					t.Fatalf("reused refresh token: status %d", status)
+				}
+				if reused.Error.Message != "Refresh token was already used, please log in again" {
+					t.Fatalf("reused refresh token: message %q", reused.Error.Message)
+				}
+
+				// The token rotated from the reused one is revoked with its family
+				if status, _ := refresh(t, app, second.RefreshToken); status != http.StatusUnauthorized {
+					t.Fatalf("refresh in a revoked family: status %d", status)
+				}
+
+				// Other logins are not affected
+				other := loginUser(t, app, "refresh@example.com")
+				if status, _ := refresh(t, app, other.RefreshToken); status != http.StatusOK {
+					t.Fatalf("refresh of another login: status %d", status)
+				}
+			})
+
+			t.Run("revoked family is rejected", func(t *testing.T) {
+				tokens := loginUser(t, app, "refresh@example.com")
+				if resp := request(t, app, http.MethodPost, "/api/v1/auth/logout-all", tokens.AccessToken, nil, nil); resp.StatusCode >= http.StatusBadRequest {
+					t.Fatalf("logout-all: status %d", resp.StatusCode)
+				}
+
+				status, result := refresh(t, app, tokens.RefreshToken)
+				if status != http.StatusUnauthorized {
+					t.Fatalf("refresh after logout: status %d", status)
+				}
+				// A logged out family is not reported as stolen
+				if result.Error.Message != "Invalid or expired refresh token" {
+					t.Fatalf("refresh after logout: message %q", result.Error.Message)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package routes
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+func TestRedirectKeepsClickBudget(t *testing.T) {
+	store := repositories.NewMemoryStore()
+	app := newTestApp(t, store)
+	token := registerUser(t, app, "budget@example.com")
+
+	const maxClicks = 5
+	body := map[string]interface{}{"target_url": "https://example.com/", "short_code": "budget01", "max_clicks": maxClicks}
+	if resp := request(t, app, http.MethodPost, "/api/v1/links", token, body, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create link: status %d", resp.StatusCode)
+	}
+
+	const redirects = 50
+	statuses := make(chan int, redirects)
+	var wg sync.WaitGroup
+	for i := 0; i < redirects; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/budget01", nil), -1)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	followed := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusFound:
+			followed++
+		case http.StatusGone:
+		default:
+			t.Fatalf("redirect: status %d", status)
+		}
+	}
+	if followed != maxClicks {
+		t.Fatalf("followed %d redirects, want %d", followed, maxClicks)
+	}
+
+	link, err := store.Links.FindByShortCode("budget01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.ClickCount != maxClicks {
+		t.Fatalf("click count %d, want %d", link.ClickCount, maxClicks)
+	}
+}
+
+func TestUpdateLinkClearsExpiry(t *testing.T) {
+	app := newTestApp(t, repositories.NewMemoryStore())
+	token := registerUser(t, app, "expiry@example.com")
+
+	var link struct {
+		ID        string     `json:"id"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	body := map[string]interface{}{"target_url": "https://example.com/", "expires_at": expiresAt}
+	if resp := request(t, app, http.MethodPost, "/api/v1/links", token, body, &link); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create link: status %d", resp.StatusCode)
+	}
+	if link.ExpiresAt == nil {
+		t.Fatal("created link has no expires_at")
+	}
+
+	both := map[string]interface{}{"expires_at": expiresAt, "clear_expires_at": true}
+	if resp := request(t, app, http.MethodPatch, "/api/v1/links/"+link.ID, token, both, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("update with expires_at and clear_expires_at: status %d", resp.StatusCode)
+	}
+
+	var updated map[string]interface{}
+	clear := map[string]interface{}{"clear_expires_at": true}
+	if resp := request(t, app, http.MethodPatch, "/api/v1/links/"+link.ID, token, clear, &updated); resp.StatusCode != http.StatusOK {
+		t.Fatalf("clear expires_at: status %d", resp.StatusCode)
+	}
+	if value, ok := updated["expires_at"]; ok {
+		t.Fatalf("expires_at is %v after clearing", value)
+	}
+}
+
+func TestExportLinksNeutralisesFormulas(t *testing.T) {
+	app := newTestApp(t, repositories.NewMemoryStore())
+	token := registerUser(t, app, "export@example.com")
+
+	body := map[string]interface{}{
+		"target_url": "https://example.com/",
+		"short_code": "export01",
+		"title":      `=HYPERLINK("https://evil.test","click")`,
+		"tags":       []string{"@team", "-draft"},
+	}
+	if resp := request(t, app, http.MethodPost, "/api/v1/links", token, body, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create link: status %d", resp.StatusCode)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/links/export?format=csv", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("export has %d rows, want 2", len(records))
+	}
+
+	row := map[string]string{}
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	if title := row["title"]; title != `'=HYPERLINK("https://evil.test","click")` {
+		t.Fatalf("title cell %q", title)
+	}
+	if tags := row["tags"]; !strings.HasPrefix(tags, "'") {
+		t.Fatalf("tags cell %q", tags)
+	}
+	if shortCode := row["short_code"]; shortCode != "export01" {
+		t.Fatalf("short_code cell %q", shortCode)
+	}
+}
+
+func TestListLinksCursorPaging(t *testing.T) {
+	stores := map[string]func(t *testing.T) *repositories.Store{
+		"memory": func(*testing.T) *repositories.Store { return repositories.NewMemoryStore() },
+		"sqlite": newSQLiteStore,
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			app := newTestApp(t, store)
+			token := registerUser(t, app, "paging@example.com")
+
+			// Titles, click counts and last clicks repeat and are partly
+			// missing, so most sort keys tie or are NULL
+			const count = 13
+			clickedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+			for i := 0; i < count; i++ {
+				shortCode := fmt.Sprintf("page%04d", i)
+				body := map[string]interface{}{"target_url": "https://example.com/" + shortCode, "short_code": shortCode}
+				if i%3 != 0 {
+					body["title"] = fmt.Sprintf("title %d", i%2)
+				}
+				if resp := request(t, app, http.MethodPost, "/api/v1/links", token, body, nil); resp.StatusCode != http.StatusCreated {
+					t.Fatalf("create link: status %d", resp.StatusCode)
+				}
+
+				if i%4 == 0 {
+					link, err := store.Links.FindByShortCode(shortCode)
+					if err != nil {
+						t.Fatal(err)
+					}
+					link.ClickCount = 7
+					link.LastClickedAt = &clickedAt
+					if err := store.Links.Update(link, "click_count", "last_clicked_at"); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			for _, sortBy := range []string{"created_at", "title", "click_count", "last_clicked_at"} {
+				for _, orderBy := range []string{"asc", "desc"} {
+					seen := map[string]bool{}
+					cursor := ""
+					for pages := 0; ; pages++ {
+						if pages > count {
+							t.Fatalf("%s %s: paging does not end", sortBy, orderBy)
+						}
+
+						query := url.Values{"sort_by": {sortBy}, "order_by": {orderBy}, "limit": {"4"}}
+						if cursor != "" {
+							query.Set("cursor", cursor)
+						}
+						var page struct {
+							Links []struct {
+								ID string `json:"id"`
+							} `json:"links"`
+							NextCursor string `json:"next_cursor"`
+						}
+						if resp := request(t, app, http.MethodGet, "/api/v1/links?"+query.Encode(), token, nil, &page); resp.StatusCode != http.StatusOK {
+							t.Fatalf("%s %s: status %d", sortBy, orderBy, resp.StatusCode)
+						}
+
+						for _, link := range page.Links {
+							if seen[link.ID] {
+								t.Fatalf("%s %s: link %s listed twice", sortBy, orderBy, link.ID)
+							}
+							seen[link.ID] = true
+						}
+						if page.NextCursor == "" {
+							break
+						}
+						cursor = page.NextCursor
+					}
+					if len(seen) != count {
+						t.Fatalf("%s %s: listed %d links, want %d", sortBy, orderBy, len(seen), count)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestListLinksRejectsCursorForRelevance(t *testing.T) {
+	app := newTestApp(t, repositories.NewMemoryStore())
+	token := registerUser(t, app, "relevance@example.com")
+
+	for i := 0; i < 3; i++ {
+		body := map[string]interface{}{"target_url": "https://example.com/", "title": fmt.Sprintf("promo %d", i)}
+		if resp := request(t, app, http.MethodPost, "/api/v1/links", token, body, nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create link: status %d", resp.StatusCode)
+		}
+	}
+
+	var page struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	if resp := request(t, app, http.MethodGet, "/api/v1/links?limit=1", token, nil, &page); resp.StatusCode != http.StatusOK {
+		t.Fatalf("list links: status %d", resp.StatusCode)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("list links: no next cursor")
+	}
+
+	var ranked struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	if resp := request(t, app, http.MethodGet, "/api/v1/links?limit=1&search=promo", token, nil, &ranked); resp.StatusCode != http.StatusOK {
+		t.Fatalf("search links: status %d", resp.StatusCode)
+	}
+	if ranked.NextCursor != "" {
+		t.Fatal("search links: relevance results returned a cursor")
+	}
+
+	for _, query := range []string{"search=promo", "search=promo&sort_by=relevance"} {
+		var result struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		path := "/api/v1/links?" + query + "&cursor=" + url.QueryEscape(page.NextCursor)
+		if resp := request(t, app, http.MethodGet, path, token, nil, &result); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s with cursor: status %d", query, resp.StatusCode)
+		}
+		if result.Error.Message != "Results sorted by relevance are paged with offset, not cursor" {
+			t.Fatalf("%s with cursor: message %q", query, result.Error.Message)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package routes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zhakazx/cleanshort/oidc"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+const testOIDCClientID = "cleanshort-test"
+
+// mockIssuer is an OpenID provider serving discovery, JWKS and token
+// endpoints. Codes are handed out by authorize instead of a login page.
+type mockIssuer struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	mutex  sync.Mutex
+	grants map[string]mockGrant
+}
+
+// mockGrant is what the token endpoint returns for a code: an ID token with
+// claims, when the code verifier matches challenge
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &mockIssuer{key: key, grants: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 issuer.server.URL,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		coordinate := func(value interface{ FillBytes([]byte) []byte }) string {
+			return base64.RawURLEncoding.EncodeToString(value.FillBytes(make([]byte, 32)))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "EC",
+				"kid": "test",
+				"use": "sig",
+				"crv": "P-256",
+				"x":   coordinate(key.PublicKey.X),
+				"y":   coordinate(key.PublicKey.Y),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", issuer.token)
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// claims returns valid ID token claims for a login with nonce
+func (m *mockIssuer) claims(nonce, email string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            testOIDCClientID,
+		"sub":            "subject-" + email,
+		"email":          email,
+		"email_verified": true,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+// authorize returns a code for the grant, as the provider would after the
+// user signed in
+func (m *mockIssuer) authorize(grant mockGrant) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	code, _ := oidc.RandomToken()
+	m.grants[code] = grant
+	return code
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mutex.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mutex.Unlock()
+
+	if !ok || r.PostForm.Get("client_id") != testOIDCClientID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, grant.claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newOIDCTestApp returns an app that logs in with issuer
+func newOIDCTestApp(t *testing.T, store *repositories.Store, issuer *mockIssuer) *fiber.App {
+	t.Helper()
+
+	cfg := testConfig()
+	cfg.OIDCIssuerURL = issuer.server.URL
+	cfg.OIDCClientID = testOIDCClientID
+	cfg.OIDCRedirectURL = cfg.BaseURL + "/api/v1/auth/oidc/callback"
+	cfg.OIDCScopes = []string{"openid", "email"}
+	return newTestAppWithConfig(t, store, cfg, &testMailer{})
+}
+
+// oidcLogin is a login started at the app, as seen by the provider
+type oidcLogin struct {
+	state, nonce, challenge string
+	cookie                  *http.Cookie
+}
+
+func startOIDCLogin(t *testing.T, app *fiber.App) *oidcLogin {
+	t.Helper()
+
+	resp := request(t, app, http.MethodGet, "/api/v1/auth/oidc/login", "", nil, nil)
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("oidc login: status %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := location.Query()
+	if params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+		t.Fatalf("oidc login: no S256 code challenge in %s", location)
+	}
+
+	login := &oidcLogin{
+		state:     params.Get("state"),
+		nonce:     params.Get("nonce"),
+		challenge: params.Get("code_challenge"),
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "cleanshort_oidc" {
+			login.cookie = cookie
+		}
+	}
+	if login.cookie == nil {
+		t.Fatal("oidc login: no login state cookie")
+	}
+	return login
+}
+
+// finish sends the provider callback with code and decodes the response
+// into out, when given
+func (l *oidcLogin) finish(t *testing.T, app *fiber.App, code string, out interface{}) int {
+	t.Helper()
+
+	query := url.Values{"code": {code}, "state": {l.state}}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?"+query.Encode(), nil)
+	req.AddCookie(l.cookie)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("oidc callback: decode response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(grant *mockGrant)
+		status int
+	}{
+		{"valid", func(*mockGrant) {}, http.StatusOK},
+		{"code verifier mismatch", func(grant *mockGrant) { grant.challenge = oidc.CodeChallenge("another-verifier") }, http.StatusUnauthorized},
+		{"nonce mismatch", func(grant *mockGrant) { grant.claims["nonce"] = "another-nonce" }, http.StatusUnauthorized},
+		{"wrong audience", func(grant *mockGrant) { grant.claims["aud"] = "another-client" }, http.StatusUnauthorized},
+		{"wrong issuer", func(grant *mockGrant) { grant.claims["iss"] = "https://issuer.invalid" }, http.StatusUnauthorized},
+		{"expired", func(grant *mockGrant) { grant.claims["exp"] = time.Now().Add(-time.Hour).Unix() }, http.StatusUnauthorized},
+		{"unverified email", func(grant *mockGrant) { grant.claims["email_verified"] = false }, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newMockIssuer(t)
+			app := newOIDCTestApp(t, repositories.NewMemoryStore(), issuer)
+
+			login := startOIDCLogin(t, app)
+			grant := mockGrant{challenge: login.challenge, claims: issuer.claims(login.nonce, "sso@example.com")}
+			tt.change(&grant)
+
+			var tokens struct {
+				AccessToken string `json:"access_token"`
+			}
+			if status := login.finish(t, app, issuer.authorize(grant), &tokens); status != tt.status {
+				t.Fatalf("oidc callback: status %d, want %d", status, tt.status)
+			}
+			if tt.status == http.StatusOK && tokens.AccessToken == "" {
+				t.Fatal("oidc callback: no access token")
+			}
+		})
+	}
+}
+
+func TestOIDCLoginRejectsReusedState(t *testing.T) {
+	issuer := newMockIssuer(t)
+	app := newOIDCTestApp(t, repositories.NewMemoryStore(), issuer)
+
+	login := startOIDCLogin(t, app)
+	other := startOIDCLogin(t, app)
+	// The login state of one login does not finish another
+	login.cookie = other.cookie
+
+	grant := mockGrant{challenge: login.challenge, claims: issuer.claims(login.nonce, "sso@example.com")}
+	if status := login.finish(t, app, issuer.authorize(grant), nil); status != http.StatusBadRequest {
+		t.Fatalf("oidc callback: status %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCLoginLinksVerifiedAccount(t *testing.T) {
+	store := repositories.NewMemoryStore()
+	issuer := newMockIssuer(t)
+	app := newOIDCTestApp(t, store, issuer)
+
+	const email = "owner@example.com"
+	token := registerUser(t, app, email)
+	var owner struct {
+		ID string `json:"id"`
+	}
+	if resp := request(t, app, http.MethodGet, "/api/v1/me/", token, nil, &owner); resp.StatusCode != http.StatusOK {
+		t.Fatalf("get me: status %d", resp.StatusCode)
+	}
+
+	// Until the owner verified the email, a provider login is not linked
+	login := startOIDCLogin(t, app)
+	grant := mockGrant{challenge: login.challenge, claims: issuer.claims(login.nonce, email)}
+	if status := login.finish(t, app, issuer.authorize(grant), nil); status != http.StatusConflict {
+		t.Fatalf("oidc callback for unverified account: status %d, want %d", status, http.StatusConflict)
+	}
+
+	user, err := store.Users.FindByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := store.Users.Update(user, "email_verified_at"); err != nil {
+		t.Fatal(err)
+	}
+
+	login = startOIDCLogin(t, app)
+	grant = mockGrant{challenge: login.challenge, claims: issuer.claims(login.nonce, "Owner@Example.com")}
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	if status := login.finish(t, app, issuer.authorize(grant), &tokens); status != http.StatusOK {
+		t.Fatalf("oidc callback for verified account: status %d", status)
+	}
+
+	var linked struct {
+		ID string `json:"id"`
+	}
+	if resp := request(t, app, http.MethodGet, "/api/v1/me/", tokens.AccessToken, nil, &linked); resp.StatusCode != http.StatusOK {
+		t.Fatalf("get me: status %d", resp.StatusCode)
+	}
+	if linked.ID != owner.ID {
+		t.Fatalf("oidc login signed in as %s, want the existing account %s", linked.ID, owner.ID)
+	}
+}
@@ -1,52 +1,163 @@
 package routes
 
 import (
+	"expvar"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/zhakazx/cleanshort/config"
 	"github.com/zhakazx/cleanshort/controllers"
+	"github.com/zhakazx/cleanshort/jwtkeys"
+	"github.com/zhakazx/cleanshort/mailer"
 	"github.com/zhakazx/cleanshort/middleware"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
 	"github.com/zhakazx/cleanshort/services"
-	"gorm.io/gorm"
 )
 
-func Setup(app *fiber.App, db *gorm.DB, cfg *config.Config) {
-	authService := services.NewAuthService(db, cfg)
-	linkService := services.NewLinkService(db, cfg)
+func Setup(app *fiber.App, store *repositories.Store, cfg *config.Config, mail mailer.Mailer, keys *jwtkeys.KeySet, clickRecorder *services.ClickRecorder) {
+	authService := services.NewAuthService(store, cfg, mail, keys)
+	linkService := services.NewLinkService(store, cfg)
+	analyticsService := services.NewAnalyticsService(store, cfg)
+	apiKeyService := services.NewAPIKeyService(store, cfg)
+	userService := services.NewUserService(store, cfg, authService, linkService)
+	twoFactorService := services.NewTwoFactorService(store, cfg)
+	adminService := services.NewAdminService(store, cfg, linkService)
+	workspaceService := services.NewWorkspaceService(store, cfg, authService)
+	tagService := services.NewTagService(store)
+	folderService := services.NewFolderService(store)
 
 	authController := controllers.NewAuthController(authService)
-	linkController := controllers.NewLinkController(linkService)
+	oidcController := controllers.NewOIDCController(authService, cfg)
+	linkController := controllers.NewLinkController(linkService, clickRecorder, cfg, middleware.NewRateLimiter(cfg.RateLimitAuth, time.Minute))
+	analyticsController := controllers.NewAnalyticsController(analyticsService)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	userController := controllers.NewUserController(userService)
+	twoFactorController := controllers.NewTwoFactorController(twoFactorService)
+	jwksController := controllers.NewJWKSController(keys)
+	adminController := controllers.NewAdminController(adminService)
+	workspaceController := controllers.NewWorkspaceController(workspaceService)
+	tagController := controllers.NewTagController(tagService)
+	folderController := controllers.NewFolderController(folderService)
 
 	app.Static("/docs", "./docs")
 	app.Get("/docs", func(c *fiber.Ctx) error {
 		return c.Redirect("/docs/api-docs.html")
 	})
 
-	app.Get("/:shortCode",
-		middleware.RedirectRateLimitMiddleware(cfg.RateLimitRedirect),
-		linkController.RedirectLink,
-	)
+	app.Get("/.well-known/jwks.json", jwksController.GetJWKS)
+
+	redirectRateLimit := middleware.RedirectRateLimitMiddleware(cfg.RateLimitRedirect)
+	app.Get("/:shortCode", redirectRateLimit, linkController.RedirectLink)
+	app.Post("/:shortCode", redirectRateLimit, linkController.UnlockLink)
 
 	// API v1 routes
 	api := app.Group("/api/v1")
 
+	authMiddleware := middleware.AuthMiddleware(keys, authService, apiKeyService)
+
 	auth := api.Group("/auth")
-	auth.Use(middleware.AuthRateLimitMiddleware(cfg.RateLimitAuth))
 
-	auth.Post("/register", authController.Register)
-	auth.Post("/login", authController.Login)
-	auth.Post("/refresh", authController.RefreshToken)
-	auth.Post("/logout", authController.Logout)
+	// Endpoints that take credentials share the strict rate limit
+	authRateLimit := middleware.AuthRateLimitMiddleware(cfg.RateLimitAuth)
+	auth.Post("/register", authRateLimit, authController.Register)
+	auth.Post("/login", authRateLimit, authController.Login)
+	auth.Post("/login/2fa", authRateLimit, authController.LoginTwoFactor)
+	auth.Post("/refresh", authRateLimit, authController.RefreshToken)
+	auth.Post("/logout", authRateLimit, authController.Logout)
+	auth.Post("/verify", authRateLimit, authController.VerifyEmail)
+	auth.Post("/forgot-password", authRateLimit, authController.ForgotPassword)
+	auth.Post("/reset-password", authRateLimit, authController.ResetPassword)
+	auth.Get("/oidc/login", authRateLimit, oidcController.Login)
+	auth.Get("/oidc/callback", authRateLimit, oidcController.Callback)
+
+	requireAccount := middleware.RequireScope(models.ScopeAccountManage)
+	auth.Get("/sessions", authMiddleware, requireAccount, authController.ListSessions)
+	auth.Delete("/sessions/:id", authMiddleware, requireAccount, authController.RevokeSession)
+	auth.Post("/logout-all", authMiddleware, requireAccount, authController.LogoutAll)
+
+	me := api.Group("/me")
+	me.Use(authMiddleware)
+
+	me.Get("/", requireAccount, userController.GetMe)
+	me.Patch("/", requireAccount, userController.UpdateMe)
+	me.Post("/password", requireAccount, authRateLimit, userController.ChangePassword)
+	me.Post("/verify-email", requireAccount, authRateLimit, userController.ResendVerificationEmail)
+	me.Delete("/", requireAccount, authRateLimit, userController.DeleteMe)
+
+	me.Get("/2fa", requireAccount, twoFactorController.GetStatus)
+	me.Post("/2fa/enable", requireAccount, authRateLimit, twoFactorController.Enable)
+	me.Post("/2fa/confirm", requireAccount, authRateLimit, twoFactorController.Confirm)
+	me.Post("/2fa/disable", requireAccount, authRateLimit, twoFactorController.Disable)
 
 	links := api.Group("/links")
-	links.Use(middleware.AuthMiddleware(cfg))
+	links.Use(authMiddleware)
+
+	links.Post("/", middleware.RequireScope(models.ScopeLinksWrite), linkController.CreateLink)
+	links.Post("/bulk", middleware.RequireScope(models.ScopeLinksWrite), linkController.BulkCreateLinks)
+	links.Get("/", middleware.RequireScope(models.ScopeLinksRead), linkController.ListLinks)
+	links.Get("/export", middleware.RequireScope(models.ScopeLinksRead), linkController.ExportLinks)
+	links.Get("/:id", middleware.RequireScope(models.ScopeLinksRead), linkController.GetLink)
+	links.Get("/:id/clicks", middleware.RequireScope(models.ScopeStatsRead), linkController.ListClicks)
+	links.Get("/:id/stats", middleware.RequireScope(models.ScopeStatsRead), analyticsController.GetLinkStats)
+	links.Patch("/:id", middleware.RequireScope(models.ScopeLinksWrite), linkController.UpdateLink)
+	links.Delete("/:id", middleware.RequireScope(models.ScopeLinksDelete), linkController.DeleteLink)
+	links.Post("/:id/transfer", middleware.RequireScope(models.ScopeLinksWrite), linkController.TransferLink)
+
+	tags := api.Group("/tags")
+	tags.Use(authMiddleware)
+
+	tags.Get("/", middleware.RequireScope(models.ScopeLinksRead), tagController.ListTags)
+	tags.Post("/", middleware.RequireScope(models.ScopeLinksWrite), tagController.CreateTag)
+	tags.Patch("/:id", middleware.RequireScope(models.ScopeLinksWrite), tagController.UpdateTag)
+	tags.Delete("/:id", middleware.RequireScope(models.ScopeLinksDelete), tagController.DeleteTag)
+
+	folders := api.Group("/folders")
+	folders.Use(authMiddleware)
+
+	folders.Get("/", middleware.RequireScope(models.ScopeLinksRead), folderController.ListFolders)
+	folders.Post("/", middleware.RequireScope(models.ScopeLinksWrite), folderController.CreateFolder)
+	folders.Patch("/:id", middleware.RequireScope(models.ScopeLinksWrite), folderController.UpdateFolder)
+	folders.Delete("/:id", middleware.RequireScope(models.ScopeLinksDelete), folderController.DeleteFolder)
+
+	workspaces := api.Group("/workspaces")
+	workspaces.Use(authMiddleware)
+
+	requireWorkspaces := middleware.RequireScope(models.ScopeWorkspacesManage)
+	workspaces.Post("/invitations/accept", requireWorkspaces, workspaceController.AcceptInvitation)
+	workspaces.Get("/", middleware.RequireScope(models.ScopeLinksRead), workspaceController.ListWorkspaces)
+	workspaces.Post("/", requireWorkspaces, workspaceController.CreateWorkspace)
+	workspaces.Get("/:id", middleware.RequireScope(models.ScopeLinksRead), workspaceController.GetWorkspace)
+	workspaces.Patch("/:id", requireWorkspaces, workspaceController.UpdateWorkspace)
+	workspaces.Delete("/:id", requireWorkspaces, workspaceController.DeleteWorkspace)
+	workspaces.Get("/:id/members", middleware.RequireScope(models.ScopeLinksRead), workspaceController.ListMembers)
+	workspaces.Patch("/:id/members/:userId", requireWorkspaces, workspaceController.UpdateMember)
+	workspaces.Delete("/:id/members/:userId", requireWorkspaces, workspaceController.RemoveMember)
+	workspaces.Post("/:id/invitations", requireWorkspaces, workspaceController.CreateInvitation)
+	workspaces.Get("/:id/invitations", requireWorkspaces, workspaceController.ListInvitations)
+	workspaces.Delete("/:id/invitations/:invitationId", requireWorkspaces, workspaceController.RevokeInvitation)
+
+	apiKeys := api.Group("/api-keys")
+	apiKeys.Use(authMiddleware, middleware.RequireScope(models.ScopeAPIKeysManage))
+
+	apiKeys.Post("/", apiKeyController.CreateAPIKey)
+	apiKeys.Get("/", apiKeyController.ListAPIKeys)
+	apiKeys.Delete("/:id", apiKeyController.RevokeAPIKey)
+
+	admin := api.Group("/admin")
+	admin.Use(authMiddleware, middleware.RequireRole(models.RoleAdmin), middleware.RequireScope(models.ScopeAdmin))
 
-	links.Post("/", linkController.CreateLink)
-	links.Get("/", linkController.ListLinks)
-	links.Get("/:id", linkController.GetLink)
-	links.Patch("/:id", linkController.UpdateLink)
-	links.Delete("/:id", linkController.DeleteLink)
+	admin.Get("/users", adminController.ListUsers)
+	admin.Get("/users/:id", adminController.GetUser)
+	admin.Post("/users/:id/suspend", adminController.SuspendUser)
+	admin.Post("/users/:id/unsuspend", adminController.UnsuspendUser)
+	admin.Get("/links", adminController.ListLinks)
+	admin.Post("/links/:id/disable", adminController.DisableLink)
+	admin.Post("/links/:id/enable", adminController.EnableLink)
+	admin.Get("/stats", adminController.GetStats)
+	// Runtime counters, with the command line and memory stats of the process
+	admin.Get("/debug/vars", adaptor.HTTPHandler(expvar.Handler()))
 
 	// Start cleanup goroutine for expired tokens
 	go func() {
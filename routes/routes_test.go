@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/database"
+	"github.com/zhakazx/cleanshort/jwtkeys"
+	"github.com/zhakazx/cleanshort/mailer"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/services"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Environment:              "test",
+		BaseURL:                  "http://short.test",
+		JWTSecret:                "test-secret",
+		JWTAccessTTL:             15 * time.Minute,
+		JWTRefreshTTL:            24 * time.Hour,
+		EmailVerificationTTL:     time.Hour,
+		PasswordResetTTL:         time.Hour,
+		WorkspaceInviteTTL:       time.Hour,
+		LoginMaxFailures:         10,
+		LoginFailureWindow:       time.Minute,
+		LoginLockoutDuration:     time.Minute,
+		LinkUnlockTTL:            time.Hour,
+		BulkLinksMax:             100,
+		IPHashSalt:               "test-salt",
+		GeoCountryHeader:         "CF-IPCountry",
+		ClickQueueSize:           100,
+		ClickBatchSize:           10,
+		ClickFlushInterval:       10 * time.Millisecond,
+		RedirectCacheSize:        100,
+		RedirectCacheTTL:         time.Minute,
+		RedirectCacheNegativeTTL: time.Second,
+		RateLimitAuth:            1000,
+		RateLimitRedirect:        1000,
+	}
+}
+
+// testMailer keeps the messages it is asked to send
+type testMailer struct {
+	mutex    sync.Mutex
+	messages []*mailer.Message
+}
+
+func (m *testMailer) Send(msg *mailer.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// waitForToken waits for an email to the address, which are sent in the
+// background, and returns the token in it
+func (m *testMailer) waitForToken(t *testing.T, to string) string {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		m.mutex.Lock()
+		for i := len(m.messages) - 1; i >= 0; i-- {
+			if m.messages[i].To != to {
+				continue
+			}
+			m.mutex.Unlock()
+			// Without link URLs the token is the paragraph after the endpoint
+			paragraphs := strings.Split(m.messages[i].Body, "\n\n")
+			if len(paragraphs) < 3 {
+				t.Fatalf("email to %s has no token: %q", to, m.messages[i].Body)
+			}
+			return paragraphs[2]
+		}
+		m.mutex.Unlock()
+	}
+
+	t.Fatalf("no email sent to %s", to)
+	return ""
+}
+
+// newTestApp returns the routes of the service on store, like main sets them up
+func newTestApp(t *testing.T, store *repositories.Store) *fiber.App {
+	t.Helper()
+	return newTestAppWithConfig(t, store, testConfig(), &testMailer{})
+}
+
+// newTestAppWithConfig is newTestApp with a changed configuration and a
+// mailer to read the sent emails from
+func newTestAppWithConfig(t *testing.T, store *repositories.Store, cfg *config.Config, mail mailer.Mailer) *fiber.App {
+	t.Helper()
+
+	clickRecorder := services.NewClickRecorder(store, cfg)
+	t.Cleanup(func() {
+		clickRecorder.Close(context.Background())
+	})
+
+	app := fiber.New()
+	app.Use(requestid.New())
+	Setup(app, store, cfg, mail, jwtkeys.NewHMAC(cfg.BaseURL, []byte(cfg.JWTSecret)), clickRecorder)
+	return app
+}
+
+// newSQLiteStore returns a migrated store on a fresh SQLite database
+func newSQLiteStore(t *testing.T) *repositories.Store {
+	t.Helper()
+
+	db, err := database.Connect("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return repositories.NewGormStore(db)
+}
+
+// request sends a request with an optional JSON body and bearer token and
+// decodes a JSON response into out, when given
+func request(t *testing.T, app *fiber.App, method, path, token string, body, out interface{}) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+// registerUser registers an account and returns its access token
+func registerUser(t *testing.T, app *fiber.App, email string) string {
+	t.Helper()
+
+	credentials := map[string]string{"email": email, "password": "password123"}
+	if resp := request(t, app, http.MethodPost, "/api/v1/auth/register", "", credentials, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("register: status %d", resp.StatusCode)
+	}
+
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	if resp := request(t, app, http.MethodPost, "/api/v1/auth/login", "", credentials, &tokens); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login: status %d", resp.StatusCode)
+	}
+	return tokens.AccessToken
+}
+
+func TestRegisterCreateLinkRedirect(t *testing.T) {
+	stores := map[string]func(t *testing.T) *repositories.Store{
+		"memory": func(*testing.T) *repositories.Store { return repositories.NewMemoryStore() },
+		"sqlite": newSQLiteStore,
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			app := newTestApp(t, newStore(t))
+			token := registerUser(t, app, "smoke@example.com")
+
+			var link struct {
+				ID        string `json:"id"`
+				ShortCode string `json:"short_code"`
+			}
+			body := map[string]string{"target_url": "https://example.com/landing", "short_code": "smoke001"}
+			if resp := request(t, app, http.MethodPost, "/api/v1/links", token, body, &link); resp.StatusCode != http.StatusCreated {
+				t.Fatalf("create link: status %d", resp.StatusCode)
+			}
+
+			resp := request(t, app, http.MethodGet, "/"+link.ShortCode, "", nil, nil)
+			if resp.StatusCode != http.StatusFound {
+				t.Fatalf("redirect: status %d", resp.StatusCode)
+			}
+			if location := resp.Header.Get("Location"); location != "https://example.com/landing" {
+				t.Fatalf("redirect: location %q", location)
+			}
+
+			if resp := request(t, app, http.MethodGet, "/missing1", "", nil, nil); resp.StatusCode != http.StatusNotFound {
+				t.Fatalf("unknown short code: status %d", resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+const unlockLimit = 5
+
+// newUnlockTestApp returns an app with a password-protected link "locked01"
+// whose password is "open-sesame"
+func newUnlockTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	cfg := testConfig()
+	cfg.RateLimitAuth = unlockLimit
+	app := newTestAppWithConfig(t, repositories.NewMemoryStore(), cfg, &testMailer{})
+	token := registerUser(t, app, "unlock@example.com")
+
+	body := map[string]interface{}{"target_url": "https://example.com/secret", "short_code": "locked01", "password": "open-sesame"}
+	if resp := request(t, app, http.MethodPost, "/api/v1/links", token, body, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create link: status %d", resp.StatusCode)
+	}
+	return app
+}
+
+// unlock submits the unlock form and returns the response status
+func unlock(app *fiber.App, password string) (int, error) {
+	form := url.Values{"password": {password}}
+	req := httptest.NewRequest(http.MethodPost, "/locked01", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestUnlockLink(t *testing.T) {
+	t.Run("correct password under the limit", func(t *testing.T) {
+		app := newUnlockTestApp(t)
+		for i := 0; i < unlockLimit-1; i++ {
+			if status, err := unlock(app, "wrong"); err != nil || status != http.StatusUnauthorized {
+				t.Fatalf("wrong password %d: status %d, %v", i+1, status, err)
+			}
+		}
+
+		// Correct passwords do not use up the attempts
+		for i := 0; i < 3; i++ {
+			if status, err := unlock(app, "open-sesame"); err != nil || status != http.StatusFound {
+				t.Fatalf("correct password: status %d, %v", status, err)
+			}
+		}
+	})
+
+	t.Run("lockout after wrong passwords", func(t *testing.T) {
+		app := newUnlockTestApp(t)
+		for i := 0; i < unlockLimit; i++ {
+			if status, err := unlock(app, "wrong"); err != nil || status != http.StatusUnauthorized {
+				t.Fatalf("wrong password %d: status %d, %v", i+1, status, err)
+			}
+		}
+
+		for _, password := range []string{"wrong", "open-sesame"} {
+			if status, err := unlock(app, password); err != nil || status != http.StatusTooManyRequests {
+				t.Fatalf("%s password after the limit: status %d, %v", password, status, err)
+			}
+		}
+	})
+
+	t.Run("parallel wrong passwords", func(t *testing.T) {
+		app := newUnlockTestApp(t)
+
+		const attempts = 30
+		statuses := make(chan int, attempts)
+		var wg sync.WaitGroup
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				status, err := unlock(app, "wrong")
+				if err != nil {
+					status = 0
+				}
+				statuses <- status
+			}()
+		}
+		wg.Wait()
+		close(statuses)
+
+		checked := 0
+		for status := range statuses {
+			switch status {
+			case http.StatusUnauthorized:
+				checked++
+			case http.StatusTooManyRequests:
+			default:
+				t.Fatalf("unlock: status %d", status)
+			}
+		}
+		if checked != unlockLimit {
+			t.Fatalf("%d parallel wrong passwords were checked, want %d", checked, unlockLimit)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+func TestUpdateEmailWaitsForVerification(t *testing.T) {
+	stores := map[string]func(t *testing.T) *repositories.Store{
+		"memory": func(*testing.T) *repositories.Store { return repositories.NewMemoryStore() },
+		"sqlite": newSQLiteStore,
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			mail := &testMailer{}
+			app := newTestAppWithConfig(t, newStore(t), testConfig(), mail)
+			token := registerUser(t, app, "old@example.com")
+
+			type user struct {
+				Email         string  `json:"email"`
+				EmailVerified bool    `json:"email_verified"`
+				PendingEmail  *string `json:"pending_email"`
+			}
+			canLogin := func(email string) bool {
+				credentials := map[string]string{"email": email, "password": "password123"}
+				return request(t, app, http.MethodPost, "/api/v1/auth/login", "", credentials, nil).StatusCode == http.StatusOK
+			}
+
+			var updated user
+			change := map[string]string{"email": "New@Example.com", "current_password": "password123"}
+			if resp := request(t, app, http.MethodPatch, "/api/v1/me/", token, change, &updated); resp.StatusCode != http.StatusOK {
+				t.Fatalf("update email: status %d", resp.StatusCode)
+			}
+			if updated.Email != "old@example.com" || updated.PendingEmail == nil || *updated.PendingEmail != "new@example.com" {
+				t.Fatalf("update email: got email %q, pending %v", updated.Email, updated.PendingEmail)
+			}
+
+			// Until the new address is verified, the old one logs in
+			if !canLogin("old@example.com") || canLogin("new@example.com") {
+				t.Fatal("login email changed before verification")
+			}
+
+			verifyToken := mail.waitForToken(t, "new@example.com")
+			var verified user
+			if resp := request(t, app, http.MethodPost, "/api/v1/auth/verify", "", map[string]string{"token": verifyToken}, &verified); resp.StatusCode != http.StatusOK {
+				t.Fatalf("verify new email: status %d", resp.StatusCode)
+			}
+			if verified.Email != "new@example.com" || !verified.EmailVerified || verified.PendingEmail != nil {
+				t.Fatalf("verify new email: got email %q, verified %v, pending %v", verified.Email, verified.EmailVerified, verified.PendingEmail)
+			}
+
+			if canLogin("old@example.com") || !canLogin("new@example.com") {
+				t.Fatal("login email did not change after verification")
+			}
+			if resp := request(t, app, http.MethodPost, "/api/v1/auth/verify", "", map[string]string{"token": verifyToken}, nil); resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("reuse email change token: status %d", resp.StatusCode)
+			}
+
+			// Asking for the current email cancels a pending change
+			change["email"] = "other@example.com"
+			if resp := request(t, app, http.MethodPatch, "/api/v1/me/", token, change, nil); resp.StatusCode != http.StatusOK {
+				t.Fatalf("update email: status %d", resp.StatusCode)
+			}
+			cancelledToken := mail.waitForToken(t, "other@example.com")
+			change["email"] = "new@example.com"
+			var cancelled user
+			if resp := request(t, app, http.MethodPatch, "/api/v1/me/", token, change, &cancelled); resp.StatusCode != http.StatusOK {
+				t.Fatalf("cancel email change: status %d", resp.StatusCode)
+			}
+			if cancelled.PendingEmail != nil {
+				t.Fatalf("cancel email change: pending %q", *cancelled.PendingEmail)
+			}
+			if resp := request(t, app, http.MethodPost, "/api/v1/auth/verify", "", map[string]string{"token": cancelledToken}, nil); resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("verify cancelled email change: status %d", resp.StatusCode)
+			}
+
+			registerUser(t, app, "taken@example.com")
+			change["email"] = "taken@example.com"
+			if resp := request(t, app, http.MethodPatch, "/api/v1/me/", token, change, nil); resp.StatusCode != http.StatusConflict {
+				t.Fatalf("update to a used email: status %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestProfileNeedsAccountScope(t *testing.T) {
+	app := newTestApp(t, repositories.NewMemoryStore())
+	token := registerUser(t, app, "scoped@example.com")
+
+	var key struct {
+		Key string `json:"key"`
+	}
+	body := map[string]interface{}{"name": "Links only", "scopes": []string{"links:read"}}
+	if resp := request(t, app, http.MethodPost, "/api/v1/api-keys", token, body, &key); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create api key: status %d", resp.StatusCode)
+	}
+
+	for _, path := range []string{"/api/v1/me/", "/api/v1/me/2fa"} {
+		if resp := request(t, app, http.MethodGet, path, key.Key, nil, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("GET %s with a links:read key: status %d, want %d", path, resp.StatusCode, http.StatusForbidden)
+		}
+		if resp := request(t, app, http.MethodGet, path, token, nil, nil); resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s with an access token: status %d", path, resp.StatusCode)
+		}
+	}
+}
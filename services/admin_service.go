@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+type AdminService struct {
+	store       *repositories.Store
+	cfg         *config.Config
+	linkService *LinkService
+}
+
+func NewAdminService(store *repositories.Store, cfg *config.Config, linkService *LinkService) *AdminService {
+	return &AdminService{
+		store:       store,
+		cfg:         cfg,
+		linkService: linkService,
+	}
+}
+
+func (s *AdminService) ListUsers(filter repositories.UserFilter) (*models.AdminUserListResponse, error) {
+	users, total, err := s.store.Users.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.AdminUserResponse, len(users))
+	for i := range users {
+		response, err := s.adminUserResponse(&users[i])
+		if err != nil {
+			return nil, err
+		}
+		responses[i] = *response
+	}
+
+	return &models.AdminUserListResponse{
+		Users:  responses,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+func (s *AdminService) GetUser(userID uuid.UUID) (*models.AdminUserResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.adminUserResponse(user)
+}
+
+// SuspendUser blocks a user from logging in and from using existing tokens
+// and API keys. Their sessions are kept, so they work again after
+// UnsuspendUser. Admins cannot be suspended.
+func (s *AdminService) SuspendUser(adminID, userID uuid.UUID) (*models.AdminUserResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsAdmin() {
+		return nil, errors.New("cannot suspend an admin")
+	}
+
+	if !user.IsSuspended() {
+		now := time.Now()
+		user.SuspendedAt = &now
+		user.UpdatedAt = now
+		if err := s.store.Users.Update(user, "suspended_at", "updated_at"); err != nil {
+			return nil, err
+		}
+		log.Printf("Admin %s suspended user %s", adminID, user.ID)
+	}
+
+	return s.adminUserResponse(user)
+}
+
+func (s *AdminService) UnsuspendUser(adminID, userID uuid.UUID) (*models.AdminUserResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsSuspended() {
+		user.SuspendedAt = nil
+		user.UpdatedAt = time.Now()
+		if err := s.store.Users.Update(user, "suspended_at", "updated_at"); err != nil {
+			return nil, err
+		}
+		log.Printf("Admin %s unsuspended user %s", adminID, user.ID)
+	}
+
+	return s.adminUserResponse(user)
+}
+
+// ListLinks searches the links of every user, or of one user when
+// filter.UserID is set
+func (s *AdminService) ListLinks(filter repositories.LinkFilter) (*models.AdminLinkListResponse, error) {
+	links, total, err := s.store.Links.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.AdminLinkResponse, len(links))
+	for i := range links {
+		responses[i] = *s.adminLinkResponse(&links[i])
+	}
+
+	return &models.AdminLinkListResponse{
+		Links:  responses,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+// DisableLink deactivates a link of any user. The owner cannot reactivate it
+// until an admin enables it again.
+func (s *AdminService) DisableLink(adminID, linkID uuid.UUID) (*models.AdminLinkResponse, error) {
+	link, err := s.findLink(linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !link.IsDisabled() {
+		now := time.Now()
+		link.IsActive = false
+		link.DisabledAt = &now
+		link.UpdatedAt = now
+		if err := s.store.Links.Update(link, "is_active", "disabled_at", "updated_at"); err != nil {
+			return nil, err
+		}
+		s.linkService.ForgetShortCodes([]string{link.ShortCode})
+		log.Printf("Admin %s disabled link %s (%s) of user %s", adminID, link.ID, link.ShortCode, link.UserID)
+	}
+
+	return s.adminLinkResponse(link), nil
+}
+
+// EnableLink lifts DisableLink and reactivates the link
+func (s *AdminService) EnableLink(adminID, linkID uuid.UUID) (*models.AdminLinkResponse, error) {
+	link, err := s.findLink(linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if link.IsDisabled() {
+		link.IsActive = true
+		link.DisabledAt = nil
+		link.UpdatedAt = time.Now()
+		if err := s.store.Links.Update(link, "is_active", "disabled_at", "updated_at"); err != nil {
+			return nil, err
+		}
+		s.linkService.ForgetShortCodes([]string{link.ShortCode})
+		log.Printf("Admin %s enabled link %s (%s) of user %s", adminID, link.ID, link.ShortCode, link.UserID)
+	}
+
+	return s.adminLinkResponse(link), nil
+}
+
+func (s *AdminService) GetStats() (*models.AdminStatsResponse, error) {
+	users, err := s.store.Users.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := s.store.Links.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AdminStatsResponse{
+		Users: *users,
+		Links: *links,
+	}, nil
+}
+
+func (s *AdminService) findUser(userID uuid.UUID) (*models.User, error) {
+	user, err := s.store.Users.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AdminService) findLink(linkID uuid.UUID) (*models.Link, error) {
+	link, err := s.store.Links.FindByID(linkID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("link not found")
+		}
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *AdminService) adminUserResponse(user *models.User) (*models.AdminUserResponse, error) {
+	total, active, err := s.store.Links.CountByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AdminUserResponse{
+		UserResponse: *userToResponse(user),
+		SuspendedAt:  user.SuspendedAt,
+		Links: models.LinkCounts{
+			Total:  total,
+			Active: active,
+		},
+	}, nil
+}
+
+func (s *AdminService) adminLinkResponse(link *models.Link) *models.AdminLinkResponse {
+	return &models.AdminLinkResponse{
+		LinkResponse: *s.linkService.linkToResponse(link),
+		UserID:       link.UserID,
+	}
+}
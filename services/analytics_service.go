@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+const (
+	maxStatsBuckets = 1000
+	topStatsLimit   = 10
+)
+
+type AnalyticsService struct {
+	store *repositories.Store
+	cfg   *config.Config
+}
+
+func NewAnalyticsService(store *repositories.Store, cfg *config.Config) *AnalyticsService {
+	return &AnalyticsService{
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// GetLinkStats aggregates the click events of a link in [from, to) into
+// interval buckets together with its top referrers, browsers and countries
+func (s *AnalyticsService) GetLinkStats(userID, linkID uuid.UUID, from, to time.Time, interval string) (*models.LinkStatsResponse, error) {
+	step, ok := statsIntervals[interval]
+	if !ok {
+		return nil, errors.New("invalid interval")
+	}
+
+	if !from.Before(to) {
+		return nil, errors.New("invalid range: from must be before to")
+	}
+
+	from = repositories.TruncateToInterval(from, interval)
+	to = to.UTC()
+
+	if int(to.Sub(from)/step) > maxStatsBuckets {
+		return nil, errors.New("invalid range: too many buckets for interval")
+	}
+
+	if _, err := findWorkspaceLink(s.store, userID, linkID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	totalClicks, uniqueVisitors, err := s.store.ClickEvents.Totals(linkID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := s.store.ClickEvents.Buckets(linkID, from, to, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	topReferrers, err := s.topValues(linkID, from, to, repositories.ClickEventReferrer, "(direct)")
+	if err != nil {
+		return nil, err
+	}
+
+	topBrowsers, err := s.topValues(linkID, from, to, repositories.ClickEventBrowser, "Unknown")
+	if err != nil {
+		return nil, err
+	}
+
+	topCountries, err := s.topValues(linkID, from, to, repositories.ClickEventCountry, "Unknown")
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LinkStatsResponse{
+		LinkID:         linkID,
+		From:           from,
+		To:             to,
+		Interval:       interval,
+		TotalClicks:    totalClicks,
+		UniqueVisitors: uniqueVisitors,
+		Series:         fillBuckets(buckets, from, to, interval),
+		TopReferrers:   topReferrers,
+		TopBrowsers:    topBrowsers,
+		TopCountries:   topCountries,
+	}, nil
+}
+
+// topValues returns the most clicked values of a click event field, labelling
+// the empty value
+func (s *AnalyticsService) topValues(linkID uuid.UUID, from, to time.Time, field, emptyLabel string) ([]models.StatsCount, error) {
+	counts, err := s.store.ClickEvents.TopValues(linkID, from, to, field, topStatsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range counts {
+		if counts[i].Value == "" {
+			counts[i].Value = emptyLabel
+		}
+	}
+
+	return counts, nil
+}
+
+var statsIntervals = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// fillBuckets returns one bucket per interval in [from, to), using zero counts
+// for intervals without clicks
+func fillBuckets(buckets []models.StatsBucket, from, to time.Time, interval string) []models.StatsBucket {
+	byStart := make(map[int64]models.StatsBucket, len(buckets))
+	for _, bucket := range buckets {
+		byStart[bucket.Start.UTC().Unix()] = bucket
+	}
+
+	step := statsIntervals[interval]
+	series := []models.StatsBucket{}
+	for start := from; start.Before(to); start = start.Add(step) {
+		bucket, ok := byStart[start.Unix()]
+		if !ok {
+			bucket = models.StatsBucket{}
+		}
+		bucket.Start = start
+		series = append(series, bucket)
+	}
+
+	return series
+}
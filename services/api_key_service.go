@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+// apiKeyTouchInterval limits how often last_used_at is written for a busy key
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyService struct {
+	store *repositories.Store
+	cfg   *config.Config
+}
+
+func NewAPIKeyService(store *repositories.Store, cfg *config.Config) *APIKeyService {
+	return &APIKeyService{
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// CreateAPIKey creates a key with the requested scopes. A key can never get a
+// scope that the credential creating it (grantedScopes) does not have.
+func (s *APIKeyService) CreateAPIKey(userID uuid.UUID, grantedScopes []string, req *models.APIKeyCreateRequest) (*models.APIKeyCreateResponse, error) {
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = grantedScopes
+	}
+
+	// The admin scope set includes every scope
+	var keyScopes []string
+	for _, scope := range models.ScopesForRole(models.RoleAdmin) {
+		if !models.HasScope(scopes, scope) {
+			continue
+		}
+		if !models.HasScope(grantedScopes, scope) {
+			return nil, errors.New("insufficient scope: cannot grant " + scope)
+		}
+		keyScopes = append(keyScopes, scope)
+	}
+
+	plainKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := models.APIKey{
+		UserID:  userID,
+		Name:    strings.TrimSpace(req.Name),
+		Prefix:  plainKey[:len(utils.APIKeyPrefix)+8],
+		KeyHash: hashToken(plainKey),
+		Scopes:  models.JoinScopes(keyScopes),
+	}
+
+	if err := s.store.APIKeys.Create(&key); err != nil {
+		return nil, err
+	}
+
+	return &models.APIKeyCreateResponse{
+		APIKeyResponse: *apiKeyToResponse(&key),
+		Key:            plainKey,
+	}, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(userID uuid.UUID) (*models.APIKeyListResponse, error) {
+	keys, err := s.store.APIKeys.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.APIKeyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = *apiKeyToResponse(&key)
+	}
+
+	return &models.APIKeyListResponse{APIKeys: responses}, nil
+}
+
+// RevokeAPIKey revokes a key of the user. Keys of other users and keys that
+// are already revoked are reported as not found.
+func (s *APIKeyService) RevokeAPIKey(userID, keyID uuid.UUID) error {
+	key, err := s.store.APIKeys.FindByID(keyID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("api key not found")
+		}
+		return err
+	}
+
+	if key.UserID != userID {
+		return errors.New("api key not found")
+	}
+
+	if err := s.store.APIKeys.Revoke(keyID, time.Now()); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("api key not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey resolves a plain API key to its stored record and
+// records when it was last used
+func (s *APIKeyService) AuthenticateAPIKey(plainKey string) (*models.APIKey, error) {
+	key, err := s.store.APIKeys.FindByHash(hashToken(plainKey))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("invalid api key")
+		}
+		return nil, err
+	}
+
+	if key.IsRevoked() {
+		return nil, errors.New("api key revoked")
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.store.APIKeys.Touch(key.ID, now); err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
+
+func apiKeyToResponse(key *models.APIKey) *models.APIKeyResponse {
+	return &models.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zhakazx/cleanshort/mailer"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+// VerifyEmail marks the email of a user as verified. The token has to be
+// unused, unexpired and issued for the user's current email, or for their
+// pending email, which then becomes their email.
+func (s *AuthService) VerifyEmail(req *models.VerifyEmailRequest) (*models.UserResponse, error) {
+	var response *models.UserResponse
+
+	err := s.store.Transaction(func(tx *repositories.Store) error {
+		token, err := useEmailToken(tx, req.Token, models.EmailTokenVerifyEmail, models.EmailTokenChangeEmail)
+		if err != nil {
+			return err
+		}
+
+		user := token.User
+		if token.Purpose == models.EmailTokenChangeEmail {
+			now := time.Now()
+			user.Email = token.Email
+			user.PendingEmail = nil
+			user.EmailVerifiedAt = &now
+			user.UpdatedAt = now
+			if err := tx.Users.Update(&user, "email", "pending_email", "email_verified_at", "updated_at"); err != nil {
+				if errors.Is(err, repositories.ErrDuplicate) {
+					return errors.New("email already in use")
+				}
+				return err
+			}
+
+			response = userToResponse(&user)
+			return nil
+		}
+
+		if user.IsEmailVerified() {
+			response = userToResponse(&user)
+			return nil
+		}
+
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		user.UpdatedAt = now
+		if err := tx.Users.Update(&user, "email_verified_at", "updated_at"); err != nil {
+			return err
+		}
+
+		response = userToResponse(&user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ForgotPassword emails a password reset token if an account uses the email.
+// Unknown emails are ignored so the response does not reveal which accounts
+// exist.
+func (s *AuthService) ForgotPassword(req *models.ForgotPasswordRequest) error {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	user, err := s.store.Users.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, expiresAt, err := s.issueEmailToken(user, user.Email, models.EmailTokenResetPassword, s.cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your CleanShort password",
+		Body: emailBody(
+			"Someone asked to reset the password of your CleanShort account. If it was not you, ignore this email.",
+			s.cfg.PasswordResetURL, "POST /api/v1/auth/reset-password", token, expiresAt),
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. All
+// sessions of the user are ended and other reset tokens stop working.
+func (s *AuthService) ResetPassword(req *models.ResetPasswordRequest) error {
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Transaction(func(tx *repositories.Store) error {
+		token, err := useEmailToken(tx, req.Token, models.EmailTokenResetPassword)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		user := token.User
+		user.Password = hashedPassword
+		user.UpdatedAt = now
+		columns := []string{"password", "updated_at"}
+
+		// Receiving the token proves that the user owns the address
+		if !user.IsEmailVerified() {
+			user.EmailVerifiedAt = &now
+			columns = append(columns, "email_verified_at")
+		}
+
+		if err := tx.Users.Update(&user, columns...); err != nil {
+			return err
+		}
+
+		if err := tx.EmailTokens.UseAllForUser(user.ID, models.EmailTokenResetPassword, now); err != nil {
+			return err
+		}
+
+		return tx.RefreshTokens.RevokeAllForUser(user.ID)
+	})
+}
+
+// sendVerificationEmail emails the user a token to verify their current
+// email. Earlier verification tokens stop working.
+func (s *AuthService) sendVerificationEmail(user *models.User) error {
+	token, expiresAt, err := s.issueEmailToken(user, user.Email, models.EmailTokenVerifyEmail, s.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your CleanShort email address",
+		Body: emailBody(
+			"Please confirm that this is your email address.",
+			s.cfg.EmailVerifyURL, "POST /api/v1/auth/verify", token, expiresAt),
+	})
+
+	return nil
+}
+
+// sendEmailChangeEmail emails a token to the pending email of the user. The
+// email of the user changes when the token is used.
+func (s *AuthService) sendEmailChangeEmail(user *models.User) error {
+	if user.PendingEmail == nil {
+		return errors.New("no pending email")
+	}
+
+	token, expiresAt, err := s.issueEmailToken(user, *user.PendingEmail, models.EmailTokenChangeEmail, s.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(&mailer.Message{
+		To:      *user.PendingEmail,
+		Subject: "Confirm your new CleanShort email address",
+		Body: emailBody(
+			"Please confirm that this is your new email address. Until you do, you keep logging in with your current email.",
+			s.cfg.EmailVerifyURL, "POST /api/v1/auth/verify", token, expiresAt),
+	})
+
+	return nil
+}
+
+// issueEmailToken stores a new token for an email of the user, replacing
+// unused tokens with the same purpose, and returns its plain value
+func (s *AuthService) issueEmailToken(user *models.User, email, purpose string, ttl time.Duration) (string, time.Time, error) {
+	token, err := utils.GenerateEmailToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	err = s.store.Transaction(func(tx *repositories.Store) error {
+		if err := tx.EmailTokens.UseAllForUser(user.ID, purpose, now); err != nil {
+			return err
+		}
+
+		return tx.EmailTokens.Create(&models.EmailToken{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			Email:     email,
+			TokenHash: hashToken(token),
+			ExpiresAt: expiresAt,
+		})
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// sendMail delivers an email in the background so that slow mail servers do
+// not hold up the request, and so response times do not reveal whether an
+// email was sent
+func (s *AuthService) sendMail(msg *mailer.Message) {
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("Failed to send email %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// useEmailToken marks a token as used and returns it with its User loaded.
+// Unknown, used and expired tokens, tokens for other purposes and tokens sent
+// to an address the user no longer has or requests are all rejected the same
+// way.
+func useEmailToken(tx *repositories.Store, plainToken string, purposes ...string) (*models.EmailToken, error) {
+	token, err := tx.EmailTokens.FindByHash(hashToken(plainToken))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
+	}
+
+	if !slices.Contains(purposes, token.Purpose) || !token.IsUsable() || token.Email != tokenAddress(token) {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	// The conditional update makes the token single-use under concurrency
+	used, err := tx.EmailTokens.Use(token.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	return token, nil
+}
+
+// tokenAddress is the email a token has to be sent to: the pending email for
+// email changes and the current email otherwise
+func tokenAddress(token *models.EmailToken) string {
+	if token.Purpose == models.EmailTokenChangeEmail {
+		if token.User.PendingEmail == nil {
+			return ""
+		}
+		return *token.User.PendingEmail
+	}
+	return token.User.Email
+}
+
+// emailBody renders the text of a token email. With a link URL configured the
+// email contains a link; otherwise it explains which endpoint takes the token.
+func emailBody(intro, linkURL, endpoint, token string, expiresAt time.Time) string {
+	var b strings.Builder
+	b.WriteString(intro + "\n\n")
+
+	if link := tokenLink(linkURL, token); link != "" {
+		fmt.Fprintf(&b, "Open this link to continue:\n\n%s\n\n", link)
+	} else {
+		fmt.Fprintf(&b, "Send this token to %s:\n\n%s\n\n", endpoint, token)
+	}
+
+	fmt.Fprintf(&b, "It expires at %s.\n", expiresAt.UTC().Format(time.RFC1123))
+	return b.String()
+}
+
+// tokenLink adds the token as the token query parameter of linkURL
+func tokenLink(linkURL, token string) string {
+	if linkURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(linkURL)
+	if err != nil {
+		log.Printf("Invalid email link URL %q: %v", linkURL, err)
+		return ""
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/oidc"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+// oidcLoginTTL is how long a user has to sign in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+// StartOIDCLogin begins a login at the OpenID provider. It returns the
+// provider URL to redirect to and a signed login state holding the state,
+// nonce and PKCE verifier, which the client keeps (as a cookie) and
+// presents to CompleteOIDCLogin.
+func (s *AuthService) StartOIDCLogin(ctx context.Context) (authURL string, loginState string, err error) {
+	if s.oidcProvider == nil {
+		return "", "", errors.New("oidc login is not configured")
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		if values[i], err = oidc.RandomToken(); err != nil {
+			return "", "", err
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err = s.oidcProvider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", fmt.Errorf("oidc provider unavailable: %w", err)
+	}
+
+	payload := fmt.Sprintf("%s.%s.%s.%d", state, nonce, verifier, time.Now().Add(oidcLoginTTL).Unix())
+	return authURL, payload + "." + s.signOIDCLoginState(payload), nil
+}
+
+// CompleteOIDCLogin redeems the authorization code of a provider callback
+// and logs in the user with the verified email of the ID token, creating
+// the user on first login. Users with two-factor authentication get a
+// challenge as with a password login.
+func (s *AuthService) CompleteOIDCLogin(ctx context.Context, code, state, loginState string, client models.ClientInfo) (*models.AuthResponse, *models.TwoFactorChallengeResponse, error) {
+	if s.oidcProvider == nil {
+		return nil, nil, errors.New("oidc login is not configured")
+	}
+
+	nonce, verifier, err := s.verifyOIDCLoginState(loginState, state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := s.oidcProvider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		return nil, nil, errors.New("oidc login failed")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" || !claims.EmailVerified {
+		return nil, nil, errors.New("oidc email is not verified")
+	}
+
+	user, err := s.findOrCreateOIDCUser(email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.IsTwoFactorEnabled() {
+		return nil, s.issueLoginChallenge(user), nil
+	}
+
+	response, err := s.startSession(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return response, nil, nil
+}
+
+// findOrCreateOIDCUser links the login to the user with the email. New
+// users get a random password, which they can replace with a password reset.
+func (s *AuthService) findOrCreateOIDCUser(email string) (*models.User, error) {
+	user, err := s.store.Users.FindByEmail(email)
+	if err == nil {
+		// Anyone can register an address they do not own, so only accounts
+		// that proved ownership are linked. A password reset verifies the
+		// email of the real owner.
+		if user.EmailVerifiedAt == nil {
+			return nil, errors.New("email belongs to an unverified account")
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	password, err := oidc.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user = &models.User{
+		Email:           email,
+		Password:        hashedPassword,
+		EmailVerifiedAt: &now,
+	}
+	if err := createUser(s.store, user); err != nil {
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return nil, errors.New("email already in use")
+		}
+		return nil, err
+	}
+
+	log.Printf("Created user %s from OIDC login", user.ID)
+	return user, nil
+}
+
+// verifyOIDCLoginState checks a login state from StartOIDCLogin against the
+// state returned by the provider and returns its nonce and PKCE verifier
+func (s *AuthService) verifyOIDCLoginState(loginState, state string) (nonce, verifier string, err error) {
+	invalid := errors.New("invalid oidc state")
+
+	idx := strings.LastIndex(loginState, ".")
+	if idx < 0 {
+		return "", "", invalid
+	}
+	payload, signature := loginState[:idx], loginState[idx+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.signOIDCLoginState(payload))) {
+		return "", "", invalid
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 4 || !hmac.Equal([]byte(parts[0]), []byte(state)) {
+		return "", "", invalid
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || time.Now().Unix() >= expiresAt {
+		return "", "", invalid
+	}
+
+	return parts[1], parts[2], nil
+}
+
+func (s *AuthService) signOIDCLoginState(payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.JWTSecret))
+	// The prefix keeps these signatures apart from other HMACs under the same key
+	mac.Write([]byte("oidc-login:" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -4,36 +4,62 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"log"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/jwtkeys"
+	"github.com/zhakazx/cleanshort/mailer"
 	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/oidc"
+	"github.com/zhakazx/cleanshort/repositories"
 	"github.com/zhakazx/cleanshort/utils"
-	"gorm.io/gorm"
 )
 
+// maxSessionUserAgentLength caps the user agent stored with a session
+const maxSessionUserAgentLength = 512
+
 type AuthService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	store    *repositories.Store
+	cfg      *config.Config
+	mailer   mailer.Mailer
+	keys     *jwtkeys.KeySet
+	throttle *LoginThrottle
+	// oidcProvider is nil unless OIDC login is configured
+	oidcProvider *oidc.Provider
 }
 
-func NewAuthService(db *gorm.DB, cfg *config.Config) *AuthService {
-	return &AuthService{
-		db:  db,
-		cfg: cfg,
+func NewAuthService(store *repositories.Store, cfg *config.Config, mailer mailer.Mailer, keys *jwtkeys.KeySet) *AuthService {
+	s := &AuthService{
+		store:    store,
+		cfg:      cfg,
+		mailer:   mailer,
+		keys:     keys,
+		throttle: NewLoginThrottle(cfg.LoginMaxFailures, cfg.LoginFailureWindow, cfg.LoginLockoutDuration),
+	}
+
+	if cfg.OIDCIssuerURL != "" {
+		s.oidcProvider = oidc.NewProvider(oidc.Config{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		})
 	}
+
+	return s
 }
 
 func (s *AuthService) Register(req *models.UserRegisterRequest) (*models.UserResponse, error) {
 	// Normalize email
 	email := strings.ToLower(strings.TrimSpace(req.Email))
 
-	var existingUser models.User
-	if err := s.db.Where("email = ?", email).First(&existingUser).Error; err == nil {
+	if _, err := s.store.Users.FindByEmail(email); err == nil {
 		return nil, errors.New("email already in use")
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+	} else if !errors.Is(err, repositories.ErrNotFound) {
 		return nil, err
 	}
 
@@ -47,120 +73,325 @@ func (s *AuthService) Register(req *models.UserRegisterRequest) (*models.UserRes
 		Password: hashedPassword,
 	}
 
-	if err := s.db.Create(&user).Error; err != nil {
+	if err := createUser(s.store, &user); err != nil {
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return nil, errors.New("email already in use")
+		}
 		return nil, err
 	}
 
-	return &models.UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-	}, nil
+	if err := s.sendVerificationEmail(&user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
+	return userToResponse(&user), nil
 }
 
-func (s *AuthService) Login(req *models.UserLoginRequest) (*models.AuthResponse, error) {
+// Login checks the credentials and starts a session. For users with two-factor
+// authentication it returns a challenge instead, which CompleteTwoFactorLogin
+// exchanges for tokens.
+func (s *AuthService) Login(req *models.UserLoginRequest, client models.ClientInfo) (*models.AuthResponse, *models.TwoFactorChallengeResponse, error) {
 	// Normalize email
 	email := strings.ToLower(strings.TrimSpace(req.Email))
 
-	var user models.User
-	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid credentials")
+	failures, err := s.reserveLoginAttempt(email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Unknown emails count as failures too, so lockouts do not reveal which
+	// accounts exist
+	user, err := s.store.Users.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			s.loginFailed(email, failures, client)
+			return nil, nil, errors.New("invalid credentials")
 		}
-		return nil, err
+		s.throttle.Release(email)
+		return nil, nil, err
 	}
 
 	if !utils.CheckPassword(req.Password, user.Password) {
-		return nil, errors.New("invalid credentials")
+		s.loginFailed(email, failures, client)
+		return nil, nil, errors.New("invalid credentials")
 	}
 
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, s.cfg)
-	if err != nil {
-		return nil, err
+	// The earlier failures are only forgotten once the second factor is
+	// accepted too
+	if user.IsTwoFactorEnabled() {
+		s.throttle.Release(email)
+		return nil, s.issueLoginChallenge(user), nil
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken()
+	response, err := s.startSession(user, client)
 	if err != nil {
-		return nil, err
+		s.throttle.Release(email)
+		return nil, nil, err
 	}
 
-	hasher := sha256.New()
-	hasher.Write([]byte(refreshToken))
-	tokenHash := hex.EncodeToString(hasher.Sum(nil))
+	s.throttle.Reset(email)
+	return response, nil, nil
+}
 
-	// Store refresh token
-	refreshTokenModel := models.RefreshToken{
-		UserID:    user.ID,
-		TokenHash: tokenHash,
-		ExpiresAt: time.Now().Add(s.cfg.JWTRefreshTTL),
+// createUser stores a new user together with their personal workspace
+func createUser(store *repositories.Store, user *models.User) error {
+	return store.Transaction(func(tx *repositories.Store) error {
+		if err := tx.Users.Create(user); err != nil {
+			return err
+		}
+		return createPersonalWorkspace(tx, user.ID)
+	})
+}
+
+// reserveLoginAttempt counts a login attempt as failed before the credentials
+// are checked, and rejects it while the email is locked out or has to wait
+// after failed attempts. It returns the failures including this attempt.
+func (s *AuthService) reserveLoginAttempt(email string) (int, error) {
+	wait, failures := s.throttle.Attempt(email)
+	if wait > 0 {
+		loginMetrics.Add("throttled", 1)
+		return 0, &AccountLockedError{RetryAfter: wait}
+	}
+	return failures, nil
+}
+
+// loginFailed records the metrics of a failed login, whose failure was
+// counted by reserveLoginAttempt, and logs lockouts
+func (s *AuthService) loginFailed(email string, failures int, client models.ClientInfo) {
+	loginMetrics.Add("failed", 1)
+
+	if s.cfg.LoginMaxFailures > 0 && failures >= s.cfg.LoginMaxFailures {
+		loginMetrics.Add("lockouts", 1)
+		log.Printf("Account lockout: %d failed logins for %s, last from %s, locked for %v",
+			failures, email, client.IPAddress, s.cfg.LoginLockoutDuration)
 	}
+}
 
-	if err := s.db.Create(&refreshTokenModel).Error; err != nil {
+// startSession issues the tokens of a new login
+func (s *AuthService) startSession(user *models.User, client models.ClientInfo) (*models.AuthResponse, error) {
+	if user.IsSuspended() {
+		return nil, errors.New("account suspended")
+	}
+
+	// Each login starts a new token family
+	sessionID := uuid.New()
+
+	accessToken, err := utils.GenerateAccessToken(user, sessionID, s.keys, s.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.issueRefreshToken(s.store, user.ID, sessionID, client)
+	if err != nil {
 		return nil, err
 	}
 
 	return &models.AuthResponse{
-		AccessToken:        accessToken,
-		ExpiresIn:         int64(s.cfg.JWTAccessTTL.Seconds()),
-		RefreshToken:       refreshToken,
-		RefreshExpiresIn:  int64(s.cfg.JWTRefreshTTL.Seconds()),
+		AccessToken:      accessToken,
+		ExpiresIn:        int64(s.cfg.JWTAccessTTL.Seconds()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresIn: int64(s.cfg.JWTRefreshTTL.Seconds()),
 	}, nil
 }
 
-func (s *AuthService) RefreshToken(req *models.RefreshTokenRequest) (*models.TokenRefreshResponse, error) {
-	hasher := sha256.New()
-	hasher.Write([]byte(req.RefreshToken))
-	tokenHash := hex.EncodeToString(hasher.Sum(nil))
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The presented token is revoked. Presenting a rotated token
+// again while its family is still active means it was copied, so the whole
+// family is revoked and both the attacker and the legitimate client have to
+// log in again.
+func (s *AuthService) RefreshToken(req *models.RefreshTokenRequest, client models.ClientInfo) (*models.TokenRefreshResponse, error) {
+	var response *models.TokenRefreshResponse
+	var reused *models.RefreshToken
+
+	err := s.store.Transaction(func(tx *repositories.Store) error {
+		refreshToken, err := tx.RefreshTokens.FindByHash(hashToken(req.RefreshToken))
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return errors.New("invalid refresh token")
+			}
+			return err
+		}
+
+		if refreshToken.IsExpired() {
+			return errors.New("refresh token is expired or revoked")
+		}
+
+		if refreshToken.User.IsSuspended() {
+			return errors.New("account suspended")
+		}
+
+		// The conditional revoke also catches a concurrent refresh with the
+		// same token
+		rotated, err := tx.RefreshTokens.RevokeIfActive(refreshToken.ID)
+		if err != nil {
+			return err
+		}
+		if !rotated {
+			revoked, err := tx.RefreshTokens.RevokeFamily(refreshToken.FamilyID)
+			if err != nil {
+				return err
+			}
+			// A family without active tokens was logged out, not stolen
+			if revoked > 0 {
+				reused = refreshToken
+			}
+			return nil
+		}
+
+		accessToken, err := utils.GenerateAccessToken(&refreshToken.User, refreshToken.FamilyID, s.keys, s.cfg)
+		if err != nil {
+			return err
+		}
+
+		newRefreshToken, err := s.issueRefreshToken(tx, refreshToken.UserID, refreshToken.FamilyID, client)
+		if err != nil {
+			return err
+		}
 
-	var refreshToken models.RefreshToken
-	if err := s.db.Where("token_hash = ?", tokenHash).Preload("User").First(&refreshToken).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid refresh token")
+		response = &models.TokenRefreshResponse{
+			AccessToken:      accessToken,
+			ExpiresIn:        int64(s.cfg.JWTAccessTTL.Seconds()),
+			RefreshToken:     newRefreshToken,
+			RefreshExpiresIn: int64(s.cfg.JWTRefreshTTL.Seconds()),
 		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if !refreshToken.IsValid() {
+	if reused != nil {
+		log.Printf("Refresh token reuse detected for user %s, revoked token family %s", reused.UserID, reused.FamilyID)
+		return nil, errors.New("refresh token reuse detected, token family revoked")
+	}
+
+	if response == nil {
 		return nil, errors.New("refresh token is expired or revoked")
 	}
 
-	accessToken, err := utils.GenerateAccessToken(refreshToken.User.ID, refreshToken.User.Email, s.cfg)
+	return response, nil
+}
+
+// issueRefreshToken stores a new refresh token in the given family and returns
+// its plain value
+func (s *AuthService) issueRefreshToken(store *repositories.Store, userID, familyID uuid.UUID, client models.ClientInfo) (string, error) {
+	refreshToken, err := utils.GenerateRefreshToken()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return &models.TokenRefreshResponse{
-		AccessToken: accessToken,
-		ExpiresIn:   int64(s.cfg.JWTAccessTTL.Seconds()),
-	}, nil
+	userAgent := client.UserAgent
+	if len(userAgent) > maxSessionUserAgentLength {
+		userAgent = userAgent[:maxSessionUserAgentLength]
+	}
+
+	now := time.Now()
+	refreshTokenModel := models.RefreshToken{
+		UserID:     userID,
+		FamilyID:   familyID,
+		TokenHash:  hashToken(refreshToken),
+		ExpiresAt:  now.Add(s.cfg.JWTRefreshTTL),
+		UserAgent:  userAgent,
+		IPAddress:  client.IPAddress,
+		LastUsedAt: &now,
+	}
+
+	if err := store.RefreshTokens.Create(&refreshTokenModel); err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
 }
 
 func (s *AuthService) Logout(refreshTokenString string) error {
-	hasher := sha256.New()
-	hasher.Write([]byte(refreshTokenString))
-	tokenHash := hex.EncodeToString(hasher.Sum(nil))
+	if err := s.store.RefreshTokens.RevokeByHash(hashToken(refreshTokenString)); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("refresh token not found")
+		}
+		return err
+	}
+
+	return nil
+}
 
-	result := s.db.Model(&models.RefreshToken{}).
-		Where("token_hash = ?", tokenHash).
-		Update("revoked", true)
+func (s *AuthService) RevokeAllUserTokens(userID uuid.UUID) error {
+	return s.store.RefreshTokens.RevokeAllForUser(userID)
+}
 
-	if result.Error != nil {
-		return result.Error
+// ListSessions returns the active logins of a user. currentSessionID marks the
+// session of the access token making the request, if any.
+func (s *AuthService) ListSessions(userID uuid.UUID, currentSessionID *uuid.UUID) (*models.SessionListResponse, error) {
+	tokens, err := s.store.RefreshTokens.ListActiveByUser(userID, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
-	if result.RowsAffected == 0 {
-		return errors.New("refresh token not found")
+	sessions := make([]models.SessionResponse, len(tokens))
+	for i, token := range tokens {
+		sessions[i] = models.SessionResponse{
+			ID:         token.FamilyID,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IPAddress,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+			Current:    currentSessionID != nil && *currentSessionID == token.FamilyID,
+		}
 	}
 
-	return nil
+	return &models.SessionListResponse{Sessions: sessions}, nil
 }
 
-func (s *AuthService) RevokeAllUserTokens(userID uuid.UUID) error {
-	return s.db.Model(&models.RefreshToken{}).
-		Where("user_id = ?", userID).
-		Update("revoked", true).Error
+// RevokeSession ends one login of the user. Access tokens already issued for
+// it stay valid until they expire.
+func (s *AuthService) RevokeSession(userID, sessionID uuid.UUID) error {
+	tokens, err := s.store.RefreshTokens.ListActiveByUser(userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if token.FamilyID == sessionID {
+			_, err := s.store.RefreshTokens.RevokeFamily(sessionID)
+			return err
+		}
+	}
+
+	return errors.New("session not found")
+}
+
+// AuthenticateUser loads the user of an access token, rejecting users that
+// were deleted or suspended after the token was issued
+func (s *AuthService) AuthenticateUser(userID uuid.UUID) (*models.User, error) {
+	user, err := s.store.Users.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	if user.IsSuspended() {
+		return nil, errors.New("account suspended")
+	}
+
+	return user, nil
 }
 
 func (s *AuthService) CleanupExpiredTokens() error {
-	return s.db.Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{}).Error
-}
\ No newline at end of file
+	now := time.Now()
+	if err := s.store.RefreshTokens.DeleteExpired(now); err != nil {
+		return err
+	}
+	if err := s.store.EmailTokens.DeleteExpired(now); err != nil {
+		return err
+	}
+	return s.store.WorkspaceInvitations.DeleteExpired(now)
+}
+
+// hashToken returns the SHA-256 hex digest under which opaque tokens and API
+// keys are stored
+func hashToken(token string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(token))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/jwtkeys"
+	"github.com/zhakazx/cleanshort/mailer"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+const testPassword = "password123"
+
+// discardMailer drops every message
+type discardMailer struct{}
+
+func (discardMailer) Send(*mailer.Message) error { return nil }
+
+func testConfig() *config.Config {
+	return &config.Config{
+		BaseURL:              "http://short.test",
+		JWTSecret:            "test-secret",
+		JWTAccessTTL:         15 * time.Minute,
+		JWTRefreshTTL:        24 * time.Hour,
+		EmailVerificationTTL: time.Hour,
+		PasswordResetTTL:     time.Hour,
+		LoginMaxFailures:     5,
+		LoginFailureWindow:   time.Hour,
+		LoginLockoutDuration: time.Hour,
+	}
+}
+
+// newTestAuthService returns an AuthService on a memory store
+func newTestAuthService(t *testing.T, cfg *config.Config) (*AuthService, *repositories.Store) {
+	t.Helper()
+
+	store := repositories.NewMemoryStore()
+	return NewAuthService(store, cfg, discardMailer{}, jwtkeys.NewHMAC(cfg.BaseURL, []byte(cfg.JWTSecret))), store
+}
+
+// createTestUser stores a user with testPassword
+func createTestUser(t *testing.T, store *repositories.Store, email string) *models.User {
+	t.Helper()
+
+	hashedPassword, err := utils.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Email: email, Password: hashedPassword}
+	if err := createUser(store, user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zhakazx/cleanshort/database"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+// currentTOTP computes the TOTP code of secret for now, like an
+// authenticator app
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// enableTwoFactor turns on two-factor authentication for the user with the
+// given recovery codes and returns the TOTP secret
+func enableTwoFactor(t *testing.T, s *AuthService, user *models.User, recoveryCodes ...string) string {
+	t.Helper()
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user.TOTPSecret = &secret
+	user.TOTPEnabledAt = &now
+	if err := s.store.Users.Update(user, "totp_secret", "totp_enabled_at"); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashes[i] = hashToken(utils.NormalizeRecoveryCode(code))
+	}
+	if err := s.store.RecoveryCodes.ReplaceForUser(user.ID, hashes); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// passwordStep logs in with the password and returns the challenge token
+func passwordStep(t *testing.T, s *AuthService, email string) string {
+	t.Helper()
+
+	_, challenge, err := s.Login(&models.UserLoginRequest{Email: email, Password: testPassword}, models.ClientInfo{})
+	if err != nil || challenge == nil {
+		t.Fatalf("login: challenge %v, error %v", challenge, err)
+	}
+	return challenge.ChallengeToken
+}
+
+func secondStep(s *AuthService, challengeToken, code string) error {
+	_, err := s.CompleteTwoFactorLogin(&models.TwoFactorLoginRequest{ChallengeToken: challengeToken, Code: code}, models.ClientInfo{})
+	return err
+}
+
+func TestTwoFactorLoginCodesAreSingleUse(t *testing.T) {
+	s, store := newTestAuthService(t, testConfig())
+	user := createTestUser(t, store, "2fa@example.com")
+	secret := enableTwoFactor(t, s, user, "aaaaa-bbbbb", "ccccc-ddddd")
+
+	code := currentTOTP(t, secret)
+	if err := secondStep(s, passwordStep(t, s, user.Email), code); err != nil {
+		t.Fatalf("totp code: %v", err)
+	}
+	if err := secondStep(s, passwordStep(t, s, user.Email), code); err == nil || !strings.Contains(err.Error(), "invalid two-factor code") {
+		t.Fatalf("replayed totp code: %v", err)
+	}
+
+	// Recovery codes are accepted in any case and with or without separators
+	if err := secondStep(s, passwordStep(t, s, user.Email), "AAAAA BBBBB"); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := secondStep(s, passwordStep(t, s, user.Email), "aaaaa-bbbbb"); err == nil || !strings.Contains(err.Error(), "invalid two-factor code") {
+		t.Fatalf("used recovery code: %v", err)
+	}
+	if err := secondStep(s, passwordStep(t, s, user.Email), "ccccc-ddddd"); err != nil {
+		t.Fatalf("other recovery code: %v", err)
+	}
+}
+
+func TestTwoFactorLoginChallenge(t *testing.T) {
+	s, store := newTestAuthService(t, testConfig())
+	user := createTestUser(t, store, "challenge@example.com")
+	enableTwoFactor(t, s, user, "aaaaa-bbbbb", "ccccc-ddddd", "eeeee-fffff")
+
+	challengeToken := passwordStep(t, s, user.Email)
+
+	// expiredToken signs a challenge that expired a second ago
+	payload := fmt.Sprintf("%s.%d", user.ID, time.Now().Add(-time.Second).Unix())
+	expiredToken := payload + "." + s.signLoginChallenge(user, payload)
+
+	tampered := []byte(challengeToken)
+	tampered[len(tampered)-1] ^= 1
+
+	for name, token := range map[string]string{
+		"empty":        "",
+		"expired":      expiredToken,
+		"tampered":     string(tampered),
+		"other format": strings.Replace(challengeToken, ".", "-", 1),
+	} {
+		if err := secondStep(s, token, "aaaaa-bbbbb"); err == nil || !strings.Contains(err.Error(), "invalid challenge token") {
+			t.Errorf("%s challenge token: %v", name, err)
+		}
+	}
+
+	// A password change invalidates open challenges
+	hashedPassword, err := utils.HashPassword("new-password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Password = hashedPassword
+	if err := store.Users.Update(user, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := secondStep(s, challengeToken, "aaaaa-bbbbb"); err == nil || !strings.Contains(err.Error(), "invalid challenge token") {
+		t.Fatalf("challenge after a password change: %v", err)
+	}
+
+	// The recovery code was not used by the rejected attempts
+	user.Password, _ = utils.HashPassword(testPassword)
+	if err := store.Users.Update(user, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := secondStep(s, passwordStep(t, s, user.Email), "aaaaa-bbbbb"); err != nil {
+		t.Fatalf("recovery code after rejected challenges: %v", err)
+	}
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	stores := map[string]func(t *testing.T) *repositories.Store{
+		"memory": func(*testing.T) *repositories.Store { return repositories.NewMemoryStore() },
+		"sqlite": func(t *testing.T) *repositories.Store {
+			db, err := database.Connect("sqlite", filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := database.Migrate(db); err != nil {
+				t.Fatal(err)
+			}
+			return repositories.NewGormStore(db)
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			user := createTestUser(t, store, "steps@example.com")
+
+			for _, tt := range []struct {
+				step int64
+				want bool
+			}{{5, true}, {5, false}, {4, false}, {6, true}} {
+				if used, err := store.Users.UseTOTPStep(user.ID, tt.step); err != nil || used != tt.want {
+					t.Fatalf("UseTOTPStep(%d) = %v, %v, want %v", tt.step, used, err, tt.want)
+				}
+			}
+
+			// Of parallel uses of one step exactly one succeeds
+			var accepted atomic.Int32
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if used, err := store.Users.UseTOTPStep(user.ID, 7); err == nil && used {
+						accepted.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			if accepted.Load() != 1 {
+				t.Fatalf("%d parallel uses of one step accepted, want 1", accepted.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+var clickMetrics = expvar.NewMap("clicks")
+
+// ClickRecorder buffers redirect clicks in a bounded queue and writes them to
+// the store in batches. Clicks are merged per short code, so a flush runs one
+// UPDATE per clicked link plus batched inserts of the click events.
+type ClickRecorder struct {
+	store     *repositories.Store
+	queue     chan queuedClick
+	interval  time.Duration
+	batchSize int
+
+	mutex   sync.RWMutex
+	closed  bool
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+type queuedClick struct {
+	shortCode string
+	event     *models.ClickEvent
+}
+
+type pendingClicks struct {
+	clicks        int64
+	lastClickedAt time.Time
+	events        []*models.ClickEvent
+}
+
+func NewClickRecorder(store *repositories.Store, cfg *config.Config) *ClickRecorder {
+	cr := &ClickRecorder{
+		store:     store,
+		queue:     make(chan queuedClick, cfg.ClickQueueSize),
+		interval:  cfg.ClickFlushInterval,
+		batchSize: cfg.ClickBatchSize,
+		done:      make(chan struct{}),
+	}
+
+	// Start flush goroutine
+	go cr.run()
+
+	return cr
+}
+
+// Record queues a click for the next flush. It never blocks: when the queue is
+// full the click is dropped and counted in the clicks.dropped metric.
+func (cr *ClickRecorder) Record(shortCode string, event *models.ClickEvent) bool {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	if cr.closed {
+		cr.drop()
+		return false
+	}
+
+	select {
+	case cr.queue <- queuedClick{shortCode: shortCode, event: event}:
+		clickMetrics.Add("queued", 1)
+		return true
+	default:
+		cr.drop()
+		return false
+	}
+}
+
+func (cr *ClickRecorder) drop() {
+	clickMetrics.Add("dropped", 1)
+	cr.dropped.Add(1)
+}
+
+// Close stops accepting clicks and flushes everything still queued
+func (cr *ClickRecorder) Close(ctx context.Context) error {
+	cr.mutex.Lock()
+	if !cr.closed {
+		cr.closed = true
+		close(cr.queue)
+	}
+	cr.mutex.Unlock()
+
+	select {
+	case <-cr.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (cr *ClickRecorder) run() {
+	defer close(cr.done)
+
+	ticker := time.NewTicker(cr.interval)
+	defer ticker.Stop()
+
+	pending := make(map[string]*pendingClicks)
+	pendingEvents := 0
+
+	for {
+		select {
+		case click, ok := <-cr.queue:
+			if !ok {
+				cr.flush(pending)
+				return
+			}
+
+			p := pending[click.shortCode]
+			if p == nil {
+				p = &pendingClicks{}
+				pending[click.shortCode] = p
+			}
+			p.clicks++
+			if click.event.ClickedAt.After(p.lastClickedAt) {
+				p.lastClickedAt = click.event.ClickedAt
+			}
+			p.events = append(p.events, click.event)
+			pendingEvents++
+
+			if pendingEvents >= cr.batchSize {
+				cr.flush(pending)
+				pending = make(map[string]*pendingClicks)
+				pendingEvents = 0
+			}
+		case <-ticker.C:
+			if dropped := cr.dropped.Swap(0); dropped > 0 {
+				log.Printf("Click queue full, dropped %d clicks", dropped)
+			}
+
+			if pendingEvents > 0 {
+				cr.flush(pending)
+				pending = make(map[string]*pendingClicks)
+				pendingEvents = 0
+			}
+		}
+	}
+}
+
+// flush writes the pending clicks in a single transaction. If that fails (for
+// example because one of the links was deleted in the meantime), each link is
+// retried on its own so one bad link cannot discard the whole batch.
+func (cr *ClickRecorder) flush(pending map[string]*pendingClicks) {
+	if len(pending) == 0 {
+		return
+	}
+
+	err := cr.store.Transaction(func(tx *repositories.Store) error {
+		for shortCode, p := range pending {
+			if err := cr.write(tx, shortCode, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		cr.flushed(pending)
+		return
+	}
+
+	log.Printf("Failed to flush %d clicked links in one batch, retrying per link: %v", len(pending), err)
+
+	for shortCode, p := range pending {
+		err := cr.store.Transaction(func(tx *repositories.Store) error {
+			return cr.write(tx, shortCode, p)
+		})
+		if err != nil {
+			log.Printf("Failed to record %d clicks for %s: %v", p.clicks, shortCode, err)
+			clickMetrics.Add("failed", p.clicks)
+			continue
+		}
+		cr.flushed(map[string]*pendingClicks{shortCode: p})
+	}
+}
+
+func (cr *ClickRecorder) write(tx *repositories.Store, shortCode string, p *pendingClicks) error {
+	if err := tx.Links.AddClicks(shortCode, p.clicks, p.lastClickedAt); err != nil {
+		return err
+	}
+
+	return tx.ClickEvents.Create(p.events...)
+}
+
+func (cr *ClickRecorder) flushed(pending map[string]*pendingClicks) {
+	for _, p := range pending {
+		clickMetrics.Add("recorded", p.clicks)
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+// FolderService manages the folder hierarchies of workspaces. Viewers can
+// list the folders of a workspace and editors can change them.
+type FolderService struct {
+	store *repositories.Store
+}
+
+func NewFolderService(store *repositories.Store) *FolderService {
+	return &FolderService{store: store}
+}
+
+// ListFolders returns every folder of a workspace, which defaults to the
+// personal workspace of the user. Clients build the hierarchy from the
+// parent IDs.
+func (s *FolderService) ListFolders(userID uuid.UUID, workspaceID *uuid.UUID) (*models.FolderListResponse, error) {
+	id := userID
+	if workspaceID != nil {
+		id = *workspaceID
+	}
+	if _, err := requireWorkspaceRole(s.store, id, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	folders, err := s.store.Folders.List(id)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.FolderResponse, len(folders))
+	for i, folder := range folders {
+		responses[i] = *folderToResponse(&folder)
+	}
+
+	return &models.FolderListResponse{Folders: responses}, nil
+}
+
+func (s *FolderService) CreateFolder(userID uuid.UUID, req *models.FolderCreateRequest) (*models.FolderResponse, error) {
+	workspaceID := userID
+	if req.WorkspaceID != nil {
+		workspaceID = *req.WorkspaceID
+	}
+
+	if req.ParentID != nil {
+		parent, err := findWorkspaceFolder(s.store, userID, *req.ParentID, models.WorkspaceRoleEditor)
+		if err != nil {
+			return nil, err
+		}
+		if req.WorkspaceID == nil {
+			workspaceID = parent.WorkspaceID
+		} else if parent.WorkspaceID != workspaceID {
+			return nil, errors.New("folder not found")
+		}
+	}
+
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleEditor); err != nil {
+		return nil, err
+	}
+
+	folder := models.Folder{
+		WorkspaceID: workspaceID,
+		ParentID:    req.ParentID,
+		Name:        strings.TrimSpace(req.Name),
+	}
+	if folder.Name == "" {
+		return nil, errors.New("invalid folder name")
+	}
+
+	if err := s.checkSiblingName(&folder); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Folders.Create(&folder); err != nil {
+		return nil, err
+	}
+
+	return folderToResponse(&folder), nil
+}
+
+// UpdateFolder renames a folder or moves it to another parent in the same
+// workspace, together with its subfolders and links
+func (s *FolderService) UpdateFolder(userID, folderID uuid.UUID, req *models.FolderUpdateRequest) (*models.FolderResponse, error) {
+	folder, err := findWorkspaceFolder(s.store, userID, folderID, models.WorkspaceRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+
+	if req.Name != nil {
+		folder.Name = strings.TrimSpace(*req.Name)
+		if folder.Name == "" {
+			return nil, errors.New("invalid folder name")
+		}
+		columns = append(columns, "name")
+	}
+
+	if req.ParentID != nil {
+		folder.ParentID = nil
+		if *req.ParentID != "" {
+			parentID, err := uuid.Parse(*req.ParentID)
+			if err != nil {
+				return nil, errors.New("invalid folder ID")
+			}
+			if err := s.checkCanMove(folder, parentID); err != nil {
+				return nil, err
+			}
+			folder.ParentID = &parentID
+		}
+		columns = append(columns, "parent_id")
+	}
+
+	if len(columns) == 0 {
+		return folderToResponse(folder), nil
+	}
+
+	if err := s.checkSiblingName(folder); err != nil {
+		return nil, err
+	}
+
+	folder.UpdatedAt = time.Now()
+	columns = append(columns, "updated_at")
+	if err := s.store.Folders.Update(folder, columns...); err != nil {
+		return nil, err
+	}
+
+	return folderToResponse(folder), nil
+}
+
+// DeleteFolder deletes a folder with its subfolders. Their links are kept
+// and no longer in a folder.
+func (s *FolderService) DeleteFolder(userID, folderID uuid.UUID) error {
+	folder, err := findWorkspaceFolder(s.store, userID, folderID, models.WorkspaceRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Folders.Delete(folder.ID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("folder not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkCanMove checks that parentID is a folder of the same workspace that
+// is neither the folder itself nor one of its subfolders
+func (s *FolderService) checkCanMove(folder *models.Folder, parentID uuid.UUID) error {
+	folders, err := s.store.Folders.List(folder.WorkspaceID)
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[uuid.UUID]*uuid.UUID, len(folders))
+	for _, f := range folders {
+		parents[f.ID] = f.ParentID
+	}
+
+	if _, ok := parents[parentID]; !ok {
+		return errors.New("folder not found")
+	}
+
+	for id := &parentID; id != nil; id = parents[*id] {
+		if *id == folder.ID {
+			return errors.New("folder cannot be moved into itself")
+		}
+	}
+
+	return nil
+}
+
+// checkSiblingName checks that no other folder with the same parent has the
+// name of folder, ignoring case
+func (s *FolderService) checkSiblingName(folder *models.Folder) error {
+	folders, err := s.store.Folders.List(folder.WorkspaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, sibling := range folders {
+		if sibling.ID == folder.ID || !sameFolder(sibling.ParentID, folder.ParentID) {
+			continue
+		}
+		if strings.EqualFold(sibling.Name, folder.Name) {
+			return errors.New("folder already exists")
+		}
+	}
+
+	return nil
+}
+
+// findWorkspaceFolder loads a folder of a workspace where the user has at
+// least the given role. Folders of other workspaces are reported as not
+// found.
+func findWorkspaceFolder(store *repositories.Store, userID, folderID uuid.UUID, role string) (*models.Folder, error) {
+	folder, err := store.Folders.FindByID(folderID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("folder not found")
+		}
+		return nil, err
+	}
+
+	if _, err := requireWorkspaceRole(store, folder.WorkspaceID, userID, role); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, errors.New("folder not found")
+		}
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// subfolderIDs returns folderID and the IDs of all folders below it
+func subfolderIDs(folders []models.Folder, folderID uuid.UUID) []uuid.UUID {
+	children := make(map[uuid.UUID][]uuid.UUID)
+	for _, folder := range folders {
+		if folder.ParentID != nil {
+			children[*folder.ParentID] = append(children[*folder.ParentID], folder.ID)
+		}
+	}
+
+	ids := []uuid.UUID{folderID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+func sameFolder(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func folderToResponse(folder *models.Folder) *models.FolderResponse {
+	return &models.FolderResponse{
+		ID:          folder.ID,
+		WorkspaceID: folder.WorkspaceID,
+		ParentID:    folder.ParentID,
+		Name:        folder.Name,
+		CreatedAt:   folder.CreatedAt,
+		UpdatedAt:   folder.UpdatedAt,
+	}
+}
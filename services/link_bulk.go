@@ -0,0 +1,283 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+// BulkLinkRow is one row of a bulk create. Err is set when the row could not
+// be parsed.
+type BulkLinkRow struct {
+	Request models.LinkCreateRequest
+	Err     error
+}
+
+// BulkLinkResult is the outcome of one row. Rows are numbered from 1.
+type BulkLinkResult struct {
+	Row  int
+	Link *models.LinkResponse
+	Err  error
+}
+
+// bulkCSVColumns are the columns accepted in CSV imports
+var bulkCSVColumns = map[string]bool{
+	"target_url":   true,
+	"short_code":   true,
+	"title":        true,
+	"is_active":    true,
+	"expires_at":   true,
+	"max_clicks":   true,
+	"password":     true,
+	"workspace_id": true,
+	"folder_id":    true,
+	"tags":         true,
+}
+
+// ParseLinkCSV reads links from CSV with a header row naming the columns.
+// Only target_url is required; empty cells are treated as missing values.
+// Values that cannot be parsed fail their row, not the whole file.
+func ParseLinkCSV(r io.Reader) ([]BulkLinkRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("invalid CSV: missing header row")
+		}
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !bulkCSVColumns[name] {
+			return nil, fmt.Errorf("invalid CSV: unknown column %q", name)
+		}
+		columns[i] = name
+	}
+
+	var rows []BulkLinkRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+
+		if len(record) != len(columns) {
+			rows = append(rows, BulkLinkRow{
+				Err: fmt.Errorf("invalid row: has %d fields, the header has %d", len(record), len(columns)),
+			})
+			continue
+		}
+
+		var row BulkLinkRow
+		for i, value := range record {
+			if err := setCSVField(&row.Request, columns[i], strings.TrimSpace(value)); err != nil {
+				row.Err = err
+				break
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func setCSVField(req *models.LinkCreateRequest, column, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch column {
+	case "target_url":
+		req.TargetURL = value
+	case "short_code":
+		req.ShortCode = &value
+	case "title":
+		req.Title = &value
+	case "password":
+		req.Password = &value
+	case "is_active":
+		isActive, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("invalid row: is_active must be true or false")
+		}
+		req.IsActive = &isActive
+	case "expires_at":
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("invalid row: expires_at must be an RFC 3339 timestamp")
+		}
+		req.ExpiresAt = &expiresAt
+	case "max_clicks":
+		maxClicks, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("invalid row: max_clicks must be a number")
+		}
+		req.MaxClicks = &maxClicks
+	case "workspace_id":
+		workspaceID, err := uuid.Parse(value)
+		if err != nil {
+			return errors.New("invalid row: workspace_id must be a UUID")
+		}
+		req.WorkspaceID = &workspaceID
+	case "folder_id":
+		folderID, err := uuid.Parse(value)
+		if err != nil {
+			return errors.New("invalid row: folder_id must be a UUID")
+		}
+		req.FolderID = &folderID
+	case "tags":
+		// Tags are separated by commas within the cell
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				req.Tags = append(req.Tags, tag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// BulkCreateLinks creates a link for every row. In atomic mode every row is
+// checked first and the links are created in one transaction, so either all
+// of them are created or none. Otherwise each valid row is created on its own
+// and failures do not affect the other rows.
+func (s *LinkService) BulkCreateLinks(userID uuid.UUID, rows []BulkLinkRow, atomic bool) ([]BulkLinkResult, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no links to create")
+	}
+	if len(rows) > s.cfg.BulkLinksMax {
+		return nil, fmt.Errorf("too many links: at most %d per request", s.cfg.BulkLinksMax)
+	}
+
+	if err := s.checkCanCreateLinks(userID); err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkLinkResult, len(rows))
+	links := make([]*models.Link, len(rows))
+	tags := make([][]string, len(rows))
+	failed := false
+
+	// Custom short codes are reserved for their first row up front, so codes
+	// generated for earlier rows cannot take them
+	owners := make(map[string]int, len(rows))
+	shortCodes := make(map[string]bool, len(rows))
+	for i := range rows {
+		if code := bulkShortCode(&rows[i]); code != "" {
+			if _, ok := owners[code]; !ok {
+				owners[code] = i
+				shortCodes[code] = true
+			}
+		}
+	}
+
+	for i := range rows {
+		results[i].Row = i + 1
+
+		if code := bulkShortCode(&rows[i]); code != "" && owners[code] != i {
+			results[i].Err = errors.New("short code already exists")
+			failed = true
+			continue
+		}
+
+		link, linkTags, err := s.newBulkLink(userID, &rows[i], shortCodes)
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		shortCodes[link.ShortCode] = true
+		tags[i] = linkTags
+
+		if atomic {
+			links[i] = link
+			continue
+		}
+
+		err = s.store.Transaction(func(tx *repositories.Store) error {
+			return createLink(tx, link, linkTags)
+		})
+		if err != nil {
+			if errors.Is(err, repositories.ErrDuplicate) {
+				err = errors.New("short code already exists")
+			}
+			results[i].Err = err
+			continue
+		}
+		links[i] = link
+	}
+
+	if atomic {
+		if failed {
+			return results, nil
+		}
+
+		err := s.store.Transaction(func(tx *repositories.Store) error {
+			for i, link := range links {
+				if err := createLink(tx, link, tags[i]); err != nil {
+					if errors.Is(err, repositories.ErrDuplicate) {
+						// Another request took the short code since it was checked
+						results[i].Err = errors.New("short code already exists")
+					}
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, repositories.ErrDuplicate) {
+				return results, nil
+			}
+			return nil, err
+		}
+	}
+
+	for i, link := range links {
+		if link == nil {
+			continue
+		}
+		s.cache.Invalidate(link.ShortCode)
+		results[i].Link = s.linkToResponse(link)
+	}
+
+	return results, nil
+}
+
+// bulkShortCode returns the custom short code of a row, if it has one
+func bulkShortCode(row *BulkLinkRow) string {
+	if row.Err != nil || row.Request.ShortCode == nil {
+		return ""
+	}
+	return strings.TrimSpace(*row.Request.ShortCode)
+}
+
+// newBulkLink runs the checks of CreateLink on one row. Generated short codes
+// avoid shortCodes, the short codes used or reserved by the same request.
+func (s *LinkService) newBulkLink(userID uuid.UUID, row *BulkLinkRow, shortCodes map[string]bool) (*models.Link, []string, error) {
+	if row.Err != nil {
+		return nil, nil, row.Err
+	}
+
+	if err := utils.ValidateStruct(&row.Request); err != nil {
+		return nil, nil, errors.New("invalid row: " + utils.ValidationMessage(err))
+	}
+
+	return s.newLink(userID, &row.Request, shortCodes)
+}
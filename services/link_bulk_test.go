@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+func TestBulkCreateLinksReservesCustomShortCodes(t *testing.T) {
+	for _, atomic := range []bool{false, true} {
+		cfg := testConfig()
+		cfg.BulkLinksMax = 10
+		store := repositories.NewMemoryStore()
+		s := NewLinkService(store, cfg)
+		user := createTestUser(t, store, "bulk@example.com")
+
+		// The first generated code is the custom code of a later row
+		generated := []string{"custom01", "random01"}
+		generateShortCode = func(int) (string, error) {
+			code := generated[0]
+			generated = generated[1:]
+			return code, nil
+		}
+		t.Cleanup(func() { generateShortCode = utils.GenerateShortCode })
+
+		custom := "custom01"
+		rows := []BulkLinkRow{
+			{Request: models.LinkCreateRequest{TargetURL: "https://example.com/generated"}},
+			{Request: models.LinkCreateRequest{TargetURL: "https://example.com/custom", ShortCode: &custom}},
+		}
+		results, err := s.BulkCreateLinks(user.ID, rows, atomic)
+		if err != nil {
+			t.Fatalf("atomic %v: %v", atomic, err)
+		}
+
+		if results[0].Err != nil || results[0].Link.ShortCode != "random01" {
+			t.Fatalf("atomic %v: generated row: %+v", atomic, results[0])
+		}
+		if results[1].Err != nil || results[1].Link.ShortCode != "custom01" {
+			t.Fatalf("atomic %v: custom row: %+v", atomic, results[1])
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"container/list"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhakazx/cleanshort/models"
+)
+
+var redirectCacheMetrics = expvar.NewMap("redirect_cache")
+
+// LinkCache is a bounded LRU cache of short code lookups used by the redirect
+// path. Unknown short codes are cached too (negative caching) with their own,
+// usually shorter, TTL.
+type LinkCache struct {
+	mutex       sync.Mutex
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	entries     map[string]*list.Element
+	order       *list.List
+}
+
+type linkCacheEntry struct {
+	shortCode string
+	link      *models.Link // nil for unknown short codes
+	expiresAt time.Time
+}
+
+// NewLinkCache creates a cache holding at most capacity short codes. A
+// capacity of 0 disables caching.
+func NewLinkCache(capacity int, ttl, negativeTTL time.Duration) *LinkCache {
+	return &LinkCache{
+		capacity:    capacity,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// Get returns the cached link for a short code. ok is false on a cache miss;
+// a nil link with ok set means the short code is known not to exist.
+func (lc *LinkCache) Get(shortCode string) (link *models.Link, ok bool) {
+	if lc.capacity <= 0 {
+		return nil, false
+	}
+
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+
+	element, found := lc.entries[shortCode]
+	if !found {
+		redirectCacheMetrics.Add("misses", 1)
+		return nil, false
+	}
+
+	entry := element.Value.(*linkCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		lc.remove(element)
+		redirectCacheMetrics.Add("misses", 1)
+		return nil, false
+	}
+
+	lc.order.MoveToFront(element)
+	redirectCacheMetrics.Add("hits", 1)
+
+	if entry.link == nil {
+		return nil, true
+	}
+
+	linkCopy := *entry.link
+	return &linkCopy, true
+}
+
+// Set caches the lookup result for a short code. Pass a nil link to cache an
+// unknown short code.
+func (lc *LinkCache) Set(shortCode string, link *models.Link) {
+	if lc.capacity <= 0 {
+		return
+	}
+
+	// The short code may come from a request buffer that is reused once the
+	// request is done, so keep a copy as the map key
+	shortCode = strings.Clone(shortCode)
+
+	entry := &linkCacheEntry{
+		shortCode: shortCode,
+		expiresAt: time.Now().Add(lc.negativeTTL),
+	}
+	if link != nil {
+		entry.link = redirectFields(link)
+		entry.expiresAt = time.Now().Add(lc.ttl)
+	}
+
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+
+	if element, found := lc.entries[shortCode]; found {
+		element.Value = entry
+		lc.order.MoveToFront(element)
+		return
+	}
+
+	lc.entries[shortCode] = lc.order.PushFront(entry)
+
+	for lc.order.Len() > lc.capacity {
+		lc.remove(lc.order.Back())
+		redirectCacheMetrics.Add("evictions", 1)
+	}
+}
+
+// Invalidate drops a short code from the cache
+func (lc *LinkCache) Invalidate(shortCode string) {
+	if lc.capacity <= 0 {
+		return
+	}
+
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+
+	if element, found := lc.entries[shortCode]; found {
+		lc.remove(element)
+		redirectCacheMetrics.Add("invalidations", 1)
+	}
+}
+
+func (lc *LinkCache) remove(element *list.Element) {
+	lc.order.Remove(element)
+	delete(lc.entries, element.Value.(*linkCacheEntry).shortCode)
+}
+
+// redirectFields copies only the fields the redirect path needs, so the cache
+// does not hold on to associations or stale counters it never uses
+func redirectFields(link *models.Link) *models.Link {
+	return &models.Link{
+		ID:           link.ID,
+		UserID:       link.UserID,
+		ShortCode:    link.ShortCode,
+		TargetURL:    link.TargetURL,
+		IsActive:     link.IsActive,
+		ExpiresAt:    link.ExpiresAt,
+		MaxClicks:    link.MaxClicks,
+		PasswordHash: link.PasswordHash,
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+// linkCursor is the content of the opaque cursors of ListLinks. The sort is
+// included so a cursor cannot be used with another sort.
+type linkCursor struct {
+	SortBy  string          `json:"s"`
+	OrderBy string          `json:"o"`
+	Value   json.RawMessage `json:"v"`
+	ID      uuid.UUID       `json:"i"`
+}
+
+func encodeLinkCursor(sortBy, orderBy string, position repositories.LinkCursor) string {
+	value := position.Value
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC().Format(time.RFC3339Nano)
+	}
+
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(linkCursor{SortBy: sortBy, OrderBy: orderBy, Value: raw, ID: position.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLinkCursor(encoded, sortBy, orderBy string) (*repositories.LinkCursor, error) {
+	invalid := errors.New("invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor linkCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, invalid
+	}
+	if cursor.SortBy != sortBy || cursor.OrderBy != orderBy || cursor.ID == uuid.Nil {
+		return nil, invalid
+	}
+
+	position := &repositories.LinkCursor{ID: cursor.ID}
+	if string(cursor.Value) == "null" {
+		if sortBy != "title" && sortBy != "last_clicked_at" {
+			return nil, invalid
+		}
+		return position, nil
+	}
+
+	switch sortBy {
+	case "created_at", "updated_at", "last_clicked_at":
+		var value string
+		if err := json.Unmarshal(cursor.Value, &value); err != nil {
+			return nil, invalid
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, invalid
+		}
+		position.Value = t
+	case "click_count":
+		var value int64
+		if err := json.Unmarshal(cursor.Value, &value); err != nil {
+			return nil, invalid
+		}
+		position.Value = value
+	default:
+		var value string
+		if err := json.Unmarshal(cursor.Value, &value); err != nil {
+			return nil, invalid
+		}
+		position.Value = value
+	}
+
+	return position, nil
+}
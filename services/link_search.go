@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+// maxSearchLength bounds search queries, since every term adds conditions
+const maxSearchLength = 500
+
+const searchDateLayout = "2006-01-02"
+
+// searchToken is a word or a "quoted phrase" of a search query
+type searchToken struct {
+	text   string
+	quoted bool
+}
+
+// parseLinkSearch parses a search query of words and "quoted phrases" to find
+// in the short code, title or target URL, and field qualifiers:
+//
+//	domain:example.com   target URL on example.com or one of its subdomains
+//	tag:promo            tagged promo; quote names with spaces, tag:"summer sale"
+//	clicks:>100          also >=, <, <=, an exact count, or a range 10..20
+//	created:2024-01-31   also >, >=, <, <=, or a range 2024-01-01..2024-01-31
+//	clicked:>2024-06-01  the last click, in the same forms as created
+//
+// Ranges may leave out either side, and dates are days in UTC. Unknown
+// qualifiers, like the scheme of a pasted URL, are searched as text.
+func parseLinkSearch(query string) (*repositories.LinkSearch, error) {
+	if len(query) > maxSearchLength {
+		return nil, fmt.Errorf("invalid search: must be at most %d characters", maxSearchLength)
+	}
+
+	search := &repositories.LinkSearch{}
+	for _, token := range splitSearchQuery(query) {
+		key, value, found := strings.Cut(token.text, ":")
+		if token.quoted || !found {
+			addSearchTerm(search, token.text)
+			continue
+		}
+
+		var err error
+		switch key = strings.ToLower(key); key {
+		case "domain":
+			var domain string
+			domain, err = parseSearchDomain(value)
+			search.Domains = append(search.Domains, domain)
+		case "tag":
+			var tag string
+			tag, err = normalizeTagName(value)
+			if err != nil {
+				err = errors.New("invalid search: tag names must be 1 to 50 characters without commas")
+			}
+			search.Tags = append(search.Tags, tag)
+		case "clicks":
+			search.MinClicks, search.MaxClicks, err = parseSearchClicks(value)
+		case "created":
+			search.Created, err = parseSearchDates(key, value)
+		case "clicked":
+			search.Clicked, err = parseSearchDates(key, value)
+		default:
+			addSearchTerm(search, token.text)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return search, nil
+}
+
+// splitSearchQuery splits a query at spaces outside of double quotes. A token
+// is quoted when it starts with a quote, so "tag:promo" is a phrase while
+// tag:"summer sale" is a qualifier.
+func splitSearchQuery(query string) []searchToken {
+	var tokens []searchToken
+	var text strings.Builder
+	inQuotes, started, quoted := false, false, false
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			if !started {
+				quoted = true
+			}
+			inQuotes = !inQuotes
+			started = true
+		case unicode.IsSpace(r) && !inQuotes:
+			if started {
+				tokens = append(tokens, searchToken{text: text.String(), quoted: quoted})
+				text.Reset()
+				started, quoted = false, false
+			}
+		default:
+			text.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		tokens = append(tokens, searchToken{text: text.String(), quoted: quoted})
+	}
+
+	return tokens
+}
+
+// addSearchTerm adds a word or phrase in lowercase, with its spaces collapsed
+func addSearchTerm(search *repositories.LinkSearch, text string) {
+	if term := strings.Join(strings.Fields(strings.ToLower(text)), " "); term != "" {
+		search.Terms = append(search.Terms, term)
+	}
+}
+
+// parseSearchDomain accepts a host name, or a URL to take the host name of
+func parseSearchDomain(value string) (string, error) {
+	domain := strings.ToLower(value)
+	if strings.Contains(domain, "://") {
+		domain = models.TargetHost(domain)
+	}
+	domain = strings.TrimSuffix(domain, ".")
+
+	if domain == "" || strings.ContainsAny(domain, "/?#@:") {
+		return "", fmt.Errorf("invalid search: domain:%s is not a domain name", value)
+	}
+	return domain, nil
+}
+
+// searchBounds splits a range of a qualifier into its lower and upper bound:
+// ">x" and ">=x" have only a lower bound, "<x" and "<=x" only an upper bound,
+// "x..y" may leave out either, and "x" is both. The flags mark bounds that
+// are not part of the range.
+func searchBounds(value string) (low, high string, lowExcluded, highExcluded bool) {
+	switch {
+	case strings.HasPrefix(value, ">="):
+		return value[2:], "", false, false
+	case strings.HasPrefix(value, ">"):
+		return value[1:], "", true, false
+	case strings.HasPrefix(value, "<="):
+		return "", value[2:], false, false
+	case strings.HasPrefix(value, "<"):
+		return "", value[1:], false, true
+	}
+
+	if low, high, found := strings.Cut(value, ".."); found {
+		return low, high, false, false
+	}
+	return value, value, false, false
+}
+
+func parseSearchClicks(value string) (minClicks, maxClicks *int64, err error) {
+	invalid := fmt.Errorf("invalid search: clicks:%s is not a click count or range", value)
+
+	low, high, lowExcluded, highExcluded := searchBounds(value)
+	if low == "" && high == "" {
+		return nil, nil, invalid
+	}
+
+	if low != "" {
+		n, err := strconv.ParseInt(low, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil, invalid
+		}
+		if lowExcluded {
+			n++
+		}
+		minClicks = &n
+	}
+
+	if high != "" {
+		n, err := strconv.ParseInt(high, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil, invalid
+		}
+		if highExcluded {
+			n--
+		}
+		maxClicks = &n
+	}
+
+	return minClicks, maxClicks, nil
+}
+
+// parseSearchDates turns a range of days into a range of times. A day as
+// upper bound includes the whole day.
+func parseSearchDates(key, value string) (repositories.TimeRange, error) {
+	var dates repositories.TimeRange
+	invalid := fmt.Errorf("invalid search: %s:%s is not a date (YYYY-MM-DD) or date range", key, value)
+
+	low, high, lowExcluded, highExcluded := searchBounds(value)
+	if low == "" && high == "" {
+		return dates, invalid
+	}
+
+	if low != "" {
+		day, err := time.Parse(searchDateLayout, low)
+		if err != nil {
+			return dates, invalid
+		}
+		if lowExcluded {
+			day = day.AddDate(0, 0, 1)
+		}
+		dates.From = &day
+	}
+
+	if high != "" {
+		day, err := time.Parse(searchDateLayout, high)
+		if err != nil {
+			return dates, invalid
+		}
+		if !highExcluded {
+			day = day.AddDate(0, 0, 1)
+		}
+		dates.To = &day
+	}
+
+	return dates, nil
+}
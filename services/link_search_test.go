@@ -0,0 +1,87 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+func TestParseLinkSearch(t *testing.T) {
+	count := func(n int64) *int64 { return &n }
+	day := func(value string) *time.Time {
+		d, err := time.Parse(searchDateLayout, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+
+	tests := []struct {
+		query string
+		want  repositories.LinkSearch
+		err   string
+	}{
+		{query: "", want: repositories.LinkSearch{}},
+		{query: "Summer  SALE", want: repositories.LinkSearch{Terms: []string{"summer", "sale"}}},
+		{query: `"Summer   Sale" promo`, want: repositories.LinkSearch{Terms: []string{"summer sale", "promo"}}},
+		{query: `"tag:promo"`, want: repositories.LinkSearch{Terms: []string{"tag:promo"}}},
+		{query: "https://example.com/path", want: repositories.LinkSearch{Terms: []string{"https://example.com/path"}}},
+		{query: `tag:"summer sale"`, want: repositories.LinkSearch{Tags: []string{"summer sale"}}},
+		{query: "TAG:Promo tag:news", want: repositories.LinkSearch{Tags: []string{"promo", "news"}}},
+		{query: "domain:Example.COM.", want: repositories.LinkSearch{Domains: []string{"example.com"}}},
+		{query: "domain:https://x.com/a", want: repositories.LinkSearch{Domains: []string{"x.com"}}},
+		{query: "domain:https://shop.x.com:8443/a?b=c", want: repositories.LinkSearch{Domains: []string{"shop.x.com"}}},
+		{query: "clicks:>5", want: repositories.LinkSearch{MinClicks: count(6)}},
+		{query: "clicks:>=5", want: repositories.LinkSearch{MinClicks: count(5)}},
+		{query: "clicks:<5", want: repositories.LinkSearch{MaxClicks: count(4)}},
+		{query: "clicks:<=5", want: repositories.LinkSearch{MaxClicks: count(5)}},
+		{query: "clicks:5", want: repositories.LinkSearch{MinClicks: count(5), MaxClicks: count(5)}},
+		{query: "clicks:10..20", want: repositories.LinkSearch{MinClicks: count(10), MaxClicks: count(20)}},
+		{query: "clicks:10..", want: repositories.LinkSearch{MinClicks: count(10)}},
+		{query: "created:..2024-01-31", want: repositories.LinkSearch{Created: repositories.TimeRange{To: day("2024-02-01")}}},
+		{query: "created:2024-01-01..2024-01-31", want: repositories.LinkSearch{Created: repositories.TimeRange{From: day("2024-01-01"), To: day("2024-02-01")}}},
+		{query: "created:2024-01-31", want: repositories.LinkSearch{Created: repositories.TimeRange{From: day("2024-01-31"), To: day("2024-02-01")}}},
+		{query: "created:>2024-01-31", want: repositories.LinkSearch{Created: repositories.TimeRange{From: day("2024-02-01")}}},
+		{query: "created:<2024-01-31", want: repositories.LinkSearch{Created: repositories.TimeRange{To: day("2024-01-31")}}},
+		{query: "clicked:>=2024-06-01", want: repositories.LinkSearch{Clicked: repositories.TimeRange{From: day("2024-06-01")}}},
+		{
+			query: `promo domain:x.com tag:"summer sale" clicks:>5 created:..2024-01-31`,
+			want: repositories.LinkSearch{
+				Terms:     []string{"promo"},
+				Domains:   []string{"x.com"},
+				Tags:      []string{"summer sale"},
+				MinClicks: count(6),
+				Created:   repositories.TimeRange{To: day("2024-02-01")},
+			},
+		},
+		{query: "clicks:many", err: "clicks:many is not a click count or range"},
+		{query: "clicks:-1", err: "clicks:-1 is not a click count or range"},
+		{query: "clicks:..", err: "clicks:.. is not a click count or range"},
+		{query: "created:2024-13-01", err: "created:2024-13-01 is not a date"},
+		{query: "clicked:yesterday", err: "clicked:yesterday is not a date"},
+		{query: "domain:", err: "domain: is not a domain name"},
+		{query: "domain:x.com/a", err: "domain:x.com/a is not a domain name"},
+		{query: "tag:a,b", err: "tag names must be 1 to 50 characters"},
+		{query: strings.Repeat("a", maxSearchLength+1), err: "must be at most"},
+	}
+
+	for _, tt := range tests {
+		search, err := parseLinkSearch(tt.query)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), "invalid search: ") || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseLinkSearch(%q) error = %v, want %q", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseLinkSearch(%q) error = %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(*search, tt.want) {
+			t.Errorf("parseLinkSearch(%q) = %+v, want %+v", tt.query, *search, tt.want)
+		}
+	}
+}
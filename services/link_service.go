@@ -1,56 +1,143 @@
 package services
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/zhakazx/cleanshort/config"
 	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
 	"github.com/zhakazx/cleanshort/utils"
-	"gorm.io/gorm"
 )
 
 type LinkService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	store *repositories.Store
+	cfg   *config.Config
+	cache *LinkCache
 }
 
-func NewLinkService(db *gorm.DB, cfg *config.Config) *LinkService {
+func NewLinkService(store *repositories.Store, cfg *config.Config) *LinkService {
 	return &LinkService{
-		db:  db,
-		cfg: cfg,
+		store: store,
+		cfg:   cfg,
+		cache: NewLinkCache(cfg.RedirectCacheSize, cfg.RedirectCacheTTL, cfg.RedirectCacheNegativeTTL),
 	}
 }
 
+// CreateLink creates a link in the given workspace, which defaults to the
+// personal workspace of the user. Creating links needs the editor role.
 func (s *LinkService) CreateLink(userID uuid.UUID, req *models.LinkCreateRequest) (*models.LinkResponse, error) {
+	if err := s.checkCanCreateLinks(userID); err != nil {
+		return nil, err
+	}
+
+	link, tags, err := s.newLink(userID, req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.Transaction(func(tx *repositories.Store) error {
+		return createLink(tx, link, tags)
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return nil, errors.New("short code already exists")
+		}
+		return nil, err
+	}
+
+	// Drop a cached "not found" for this short code
+	s.cache.Invalidate(link.ShortCode)
+
+	return s.linkToResponse(link), nil
+}
+
+// createLink stores a link built by newLink and gives it the named tags
+func createLink(tx *repositories.Store, link *models.Link, tags []string) error {
+	if err := tx.Links.Create(link); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+	return setLinkTags(tx, link, tags)
+}
+
+// newLink checks a create request and builds the link without storing it,
+// returning the normalized tag names to give it. Custom short codes are
+// checked against the existing links; missing ones are generated, avoiding
+// the existing links and the short codes in taken.
+func (s *LinkService) newLink(userID uuid.UUID, req *models.LinkCreateRequest, taken map[string]bool) (*models.Link, []string, error) {
+	workspaceID := userID
+	if req.WorkspaceID != nil {
+		workspaceID = *req.WorkspaceID
+	}
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleEditor); err != nil {
+		return nil, nil, err
+	}
+
+	if req.FolderID != nil {
+		if err := s.checkFolderInWorkspace(*req.FolderID, workspaceID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tags, err := normalizeTagNames(req.Tags)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var shortCode string
-	var err error
 
 	if req.ShortCode != nil && *req.ShortCode != "" {
 		shortCode = strings.TrimSpace(*req.ShortCode)
 
 		if !utils.IsValidShortCode(shortCode) {
-			return nil, errors.New("invalid short code format")
+			return nil, nil, errors.New("invalid short code format")
 		}
 
 		if utils.IsReservedShortCode(shortCode) {
-			return nil, errors.New("short code is reserved")
+			return nil, nil, errors.New("short code is reserved")
 		}
 
-		var existingLink models.Link
-		if err := s.db.Where("short_code = ?", shortCode).First(&existingLink).Error; err == nil {
-			return nil, errors.New("short code already exists")
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
+		exists, err := s.store.Links.ShortCodeExists(shortCode)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exists {
+			return nil, nil, errors.New("short code already exists")
 		}
 	} else {
-		shortCode, err = s.generateUniqueShortCode()
+		shortCode, err = s.generateUniqueShortCode(taken)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, nil, errors.New("invalid expiration: expires_at must be in the future")
 		}
+		utc := req.ExpiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	var passwordHash *string
+	if req.Password != nil && *req.Password != "" {
+		hashed, err := utils.HashPassword(*req.Password)
+		if err != nil {
+			return nil, nil, err
+		}
+		passwordHash = &hashed
 	}
 
 	isActive := true
@@ -58,173 +145,590 @@ func (s *LinkService) CreateLink(userID uuid.UUID, req *models.LinkCreateRequest
 		isActive = *req.IsActive
 	}
 
-	link := models.Link{
-		UserID:    userID,
-		ShortCode: shortCode,
-		TargetURL: req.TargetURL,
-		Title:     req.Title,
-		IsActive:  isActive,
-	}
+	return &models.Link{
+		UserID:       userID,
+		WorkspaceID:  workspaceID,
+		FolderID:     req.FolderID,
+		ShortCode:    shortCode,
+		TargetURL:    req.TargetURL,
+		TargetHost:   models.TargetHost(req.TargetURL),
+		Title:        req.Title,
+		IsActive:     isActive,
+		ExpiresAt:    expiresAt,
+		MaxClicks:    req.MaxClicks,
+		PasswordHash: passwordHash,
+	}, tags, nil
+}
 
-	if err := s.db.Create(&link).Error; err != nil {
+func (s *LinkService) GetLink(userID, linkID uuid.UUID) (*models.LinkResponse, error) {
+	link, err := findWorkspaceLink(s.store, userID, linkID, models.WorkspaceRoleViewer)
+	if err != nil {
 		return nil, err
 	}
 
-	return s.linkToResponse(&link), nil
+	return s.linkToResponse(link), nil
 }
 
-func (s *LinkService) GetLink(userID, linkID uuid.UUID) (*models.LinkResponse, error) {
-	var link models.Link
-	if err := s.db.Where("id = ? AND user_id = ?", linkID, userID).First(&link).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+// checkCanCreateLinks enforces REQUIRE_VERIFIED_EMAIL
+func (s *LinkService) checkCanCreateLinks(userID uuid.UUID) error {
+	if !s.cfg.RequireVerifiedEmail {
+		return nil
+	}
+
+	user, err := s.store.Users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsEmailVerified() {
+		return errors.New("email not verified")
+	}
+
+	return nil
+}
+
+// checkFolderInWorkspace checks that a folder exists in the workspace
+func (s *LinkService) checkFolderInWorkspace(folderID, workspaceID uuid.UUID) error {
+	folder, err := s.store.Folders.FindByID(folderID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("folder not found")
+		}
+		return err
+	}
+
+	if folder.WorkspaceID != workspaceID {
+		return errors.New("folder not found")
+	}
+
+	return nil
+}
+
+// findWorkspaceLink loads a link in a workspace where the user has at least
+// the given role. Links in other workspaces are reported as not found.
+func findWorkspaceLink(store *repositories.Store, userID, linkID uuid.UUID, role string) (*models.Link, error) {
+	link, err := store.Links.FindByID(linkID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("link not found")
+		}
+		return nil, err
+	}
+
+	if _, err := requireWorkspaceRole(store, link.WorkspaceID, userID, role); err != nil {
+		if strings.Contains(err.Error(), "not found") {
 			return nil, errors.New("link not found")
 		}
 		return nil, err
 	}
 
-	return s.linkToResponse(&link), nil
+	return link, nil
 }
 
+// GetLinkByShortCode looks up a link for the redirect path. Results, including
+// unknown short codes, are served from the redirect cache when possible.
 func (s *LinkService) GetLinkByShortCode(shortCode string) (*models.Link, error) {
-	var link models.Link
-	if err := s.db.Where("short_code = ?", shortCode).First(&link).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	if link, ok := s.cache.Get(shortCode); ok {
+		if link == nil {
+			return nil, errors.New("link not found")
+		}
+		return link, nil
+	}
+
+	link, err := s.store.Links.FindByShortCode(shortCode)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			s.cache.Set(shortCode, nil)
 			return nil, errors.New("link not found")
 		}
 		return nil, err
 	}
 
-	return &link, nil
+	s.cache.Set(shortCode, link)
+
+	return link, nil
 }
 
 func (s *LinkService) UpdateLink(userID, linkID uuid.UUID, req *models.LinkUpdateRequest) (*models.LinkResponse, error) {
-	var link models.Link
-	if err := s.db.Where("id = ? AND user_id = ?", linkID, userID).First(&link).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("link not found")
-		}
+	link, err := findWorkspaceLink(s.store, userID, linkID, models.WorkspaceRoleEditor)
+	if err != nil {
 		return nil, err
 	}
 
-	updates := make(map[string]interface{})
+	var columns []string
 
 	if req.TargetURL != nil {
-		updates["target_url"] = *req.TargetURL
+		link.TargetURL = *req.TargetURL
+		link.TargetHost = models.TargetHost(link.TargetURL)
+		columns = append(columns, "target_url", "target_host")
 	}
 
 	if req.Title != nil {
-		updates["title"] = *req.Title
+		link.Title = req.Title
+		columns = append(columns, "title")
 	}
 
 	if req.IsActive != nil {
-		updates["is_active"] = *req.IsActive
+		if *req.IsActive && link.IsDisabled() {
+			return nil, errors.New("link disabled by an admin")
+		}
+		link.IsActive = *req.IsActive
+		columns = append(columns, "is_active")
 	}
 
-	if len(updates) > 0 {
-		updates["updated_at"] = time.Now()
-		if err := s.db.Model(&link).Updates(updates).Error; err != nil {
+	if req.ClearExpiresAt {
+		if req.ExpiresAt != nil {
+			return nil, errors.New("conflicting expiration: expires_at and clear_expires_at are both set")
+		}
+		link.ExpiresAt = nil
+		columns = append(columns, "expires_at")
+	}
+
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, errors.New("invalid expiration: expires_at must be in the future")
+		}
+		expiresAt := req.ExpiresAt.UTC()
+		link.ExpiresAt = &expiresAt
+		columns = append(columns, "expires_at")
+	}
+
+	if req.MaxClicks != nil {
+		if *req.MaxClicks == 0 {
+			link.MaxClicks = nil
+		} else {
+			link.MaxClicks = req.MaxClicks
+		}
+		columns = append(columns, "max_clicks")
+	}
+
+	if req.Password != nil {
+		if *req.Password == "" {
+			link.PasswordHash = nil
+		} else {
+			if len(*req.Password) < 4 {
+				return nil, errors.New("invalid password: must be at least 4 characters")
+			}
+			hashed, err := utils.HashPassword(*req.Password)
+			if err != nil {
+				return nil, err
+			}
+			link.PasswordHash = &hashed
+		}
+		columns = append(columns, "password_hash")
+	}
+
+	if req.FolderID != nil {
+		link.FolderID = nil
+		if *req.FolderID != "" {
+			folderID, err := uuid.Parse(*req.FolderID)
+			if err != nil {
+				return nil, errors.New("invalid folder ID")
+			}
+			if err := s.checkFolderInWorkspace(folderID, link.WorkspaceID); err != nil {
+				return nil, err
+			}
+			link.FolderID = &folderID
+		}
+		columns = append(columns, "folder_id")
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		tags, err = normalizeTagNames(*req.Tags)
+		if err != nil {
 			return nil, err
 		}
 	}
 
-	if err := s.db.Where("id = ?", linkID).First(&link).Error; err != nil {
+	if len(columns) > 0 || req.Tags != nil {
+		link.UpdatedAt = time.Now()
+		columns = append(columns, "updated_at")
+		err := s.store.Transaction(func(tx *repositories.Store) error {
+			if err := tx.Links.Update(link, columns...); err != nil {
+				return err
+			}
+			if req.Tags == nil {
+				return nil
+			}
+			return setLinkTags(tx, link, tags)
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.cache.Invalidate(link.ShortCode)
+	}
+
+	link, err = s.store.Links.FindByID(linkID)
+	if err != nil {
 		return nil, err
 	}
 
-	return s.linkToResponse(&link), nil
+	return s.linkToResponse(link), nil
 }
 
 func (s *LinkService) DeleteLink(userID, linkID uuid.UUID) error {
-	result := s.db.Where("id = ? AND user_id = ?", linkID, userID).Delete(&models.Link{})
-	if result.Error != nil {
-		return result.Error
+	link, err := findWorkspaceLink(s.store, userID, linkID, models.WorkspaceRoleEditor)
+	if err != nil {
+		return err
 	}
 
-	if result.RowsAffected == 0 {
-		return errors.New("link not found")
+	if err := s.store.Links.Delete(link.ID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("link not found")
+		}
+		return err
 	}
 
+	s.cache.Invalidate(link.ShortCode)
+
 	return nil
 }
 
-func (s *LinkService) ListLinks(userID uuid.UUID, limit, offset int, query string, active *bool, sortBy, orderBy string) (*models.LinkListResponse, error) {
-	var links []models.Link
-	var total int64
+// ForgetShortCodes drops short codes from the redirect cache, for links that
+// were deleted without going through DeleteLink
+func (s *LinkService) ForgetShortCodes(shortCodes []string) {
+	for _, shortCode := range shortCodes {
+		s.cache.Invalidate(shortCode)
+	}
+}
 
-	db := s.db.Model(&models.Link{}).Where("user_id = ?", userID)
+// TransferLink moves a link to another workspace. The user needs the editor
+// role in both workspaces. The link leaves its folder and keeps its tags,
+// which are created in the other workspace where missing.
+func (s *LinkService) TransferLink(userID, linkID uuid.UUID, req *models.LinkTransferRequest) (*models.LinkResponse, error) {
+	link, err := findWorkspaceLink(s.store, userID, linkID, models.WorkspaceRoleEditor)
+	if err != nil {
+		return nil, err
+	}
 
-	if active != nil {
-		db = db.Where("is_active = ?", *active)
+	if _, err := requireWorkspaceRole(s.store, req.WorkspaceID, userID, models.WorkspaceRoleEditor); err != nil {
+		return nil, err
 	}
 
-	if query != "" {
-		searchPattern := "%" + strings.ToLower(query) + "%"
-		db = db.Where("LOWER(short_code) LIKE ? OR LOWER(title) LIKE ?", searchPattern, searchPattern)
+	if link.WorkspaceID != req.WorkspaceID {
+		tags := tagNames(link.Tags)
+		link.WorkspaceID = req.WorkspaceID
+		link.FolderID = nil
+		link.UpdatedAt = time.Now()
+		err := s.store.Transaction(func(tx *repositories.Store) error {
+			if err := tx.Links.Update(link, "workspace_id", "folder_id", "updated_at"); err != nil {
+				return err
+			}
+			return setLinkTags(tx, link, tags)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if err := db.Count(&total).Error; err != nil {
+	return s.linkToResponse(link), nil
+}
+
+// LinkListOptions are the filters and paging of ListLinks and ExportLinks
+type LinkListOptions struct {
+	// WorkspaceID limits the links to one workspace of the user; nil lists
+	// the links of all of them
+	WorkspaceID *uuid.UUID
+	Query       string
+	// Search is a query in the syntax of parseLinkSearch
+	Search string
+	Active *bool
+	// Tags limits the links to those with any of these tags, or with all of
+	// them when MatchAllTags is set
+	Tags         []string
+	MatchAllTags bool
+	// FolderID limits the links to one folder, and to its subfolders when
+	// IncludeSubfolders is set. Unfiled lists the links outside of any
+	// folder instead.
+	FolderID          *uuid.UUID
+	IncludeSubfolders bool
+	Unfiled           bool
+	// SortBy is a link column, or relevance to rank the links by how well
+	// they match Search. Relevance ignores OrderBy and has no cursors.
+	SortBy  string
+	OrderBy string
+	Limit   int
+	Offset  int
+	// Cursor continues a listing after the page that returned it
+	Cursor string
+	// IncludeTotal counts all matching links, which costs an extra query
+	IncludeTotal bool
+}
+
+// ListLinks returns one page of links. Every page that is followed by more
+// links has a next_cursor to continue after it, whether it was requested with
+// an offset or a cursor, unless the links are sorted by relevance.
+func (s *LinkService) ListLinks(userID uuid.UUID, opts LinkListOptions) (*models.LinkListResponse, error) {
+	filter, err := s.linkFilter(userID, &opts)
+	if err != nil {
 		return nil, err
 	}
 
-	// Build the order clause
-	orderClause := fmt.Sprintf("%s %s", sortBy, strings.ToUpper(orderBy))
-	
-	// Handle special case for last_clicked_at to handle NULL values properly
-	if sortBy == "last_clicked_at" {
-		if orderBy == "desc" {
-			orderClause = "last_clicked_at DESC NULLS LAST"
-		} else {
-			orderClause = "last_clicked_at ASC NULLS LAST"
+	relevance := opts.SortBy == repositories.LinkSortRelevance
+	if relevance && opts.Cursor != "" {
+		return nil, errors.New("cursor paging is not available when sorting by relevance")
+	}
+
+	if opts.Cursor != "" {
+		filter.After, err = decodeLinkCursor(opts.Cursor, opts.SortBy, opts.OrderBy)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	if err := db.Order(orderClause).Limit(limit).Offset(offset).Find(&links).Error; err != nil {
+	// One more link than requested tells whether there is a next page
+	filter.Limit = opts.Limit + 1
+	filter.Offset = opts.Offset
+	filter.SkipTotal = !opts.IncludeTotal
+
+	links, total, err := s.store.Links.List(*filter)
+	if err != nil {
 		return nil, err
 	}
 
-	linkResponses := make([]models.LinkResponse, len(links))
+	response := &models.LinkListResponse{
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	}
+
+	// Relevance has no position to continue from; later pages use offsets
+	if len(links) > opts.Limit {
+		links = links[:opts.Limit]
+		if !relevance {
+			response.NextCursor = encodeLinkCursor(opts.SortBy, opts.OrderBy, repositories.LinkCursorAt(&links[len(links)-1], opts.SortBy))
+		}
+	}
+
+	if opts.IncludeTotal {
+		response.Total = &total
+	}
+
+	response.Links = make([]models.LinkResponse, len(links))
 	for i, link := range links {
-		linkResponses[i] = *s.linkToResponse(&link)
+		response.Links[i] = *s.linkToResponse(&link)
+	}
+
+	return response, nil
+}
+
+// ExportLinks checks access like ListLinks and returns a function that passes
+// every link matching the filters of opts to fn; the paging is ignored. The
+// links are read in batches while fn runs, so exports of any size use little
+// memory.
+func (s *LinkService) ExportLinks(userID uuid.UUID, opts LinkListOptions) (func(fn func(link *models.LinkResponse) error) error, error) {
+	filter, err := s.linkFilter(userID, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(fn func(link *models.LinkResponse) error) error {
+		return s.store.Links.Each(*filter, func(link *models.Link) error {
+			return fn(s.linkToResponse(link))
+		})
+	}, nil
+}
+
+// linkFilter checks access and builds the filter of a listing, without its
+// paging
+func (s *LinkService) linkFilter(userID uuid.UUID, opts *LinkListOptions) (*repositories.LinkFilter, error) {
+	workspaceIDs, err := s.readableWorkspaces(userID, opts.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &repositories.LinkFilter{
+		WorkspaceIDs: workspaceIDs,
+		Query:        opts.Query,
+		Active:       opts.Active,
+		MatchAllTags: opts.MatchAllTags,
+		Unfiled:      opts.Unfiled,
+		SortBy:       opts.SortBy,
+		OrderBy:      opts.OrderBy,
+	}
+
+	if len(opts.Tags) > 0 {
+		filter.Tags, err = normalizeTagNames(opts.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.FolderID != nil {
+		folder, err := findWorkspaceFolder(s.store, userID, *opts.FolderID, models.WorkspaceRoleViewer)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.FolderIDs = []uuid.UUID{folder.ID}
+		if opts.IncludeSubfolders {
+			folders, err := s.store.Folders.List(folder.WorkspaceID)
+			if err != nil {
+				return nil, err
+			}
+			filter.FolderIDs = subfolderIDs(folders, folder.ID)
+		}
+	}
+
+	if opts.Search != "" {
+		filter.Search, err = parseLinkSearch(opts.Search)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+// readableWorkspaces returns workspaceID if the user may read its links, or
+// every workspace of the user when workspaceID is nil
+func (s *LinkService) readableWorkspaces(userID uuid.UUID, workspaceID *uuid.UUID) ([]uuid.UUID, error) {
+	if workspaceID != nil {
+		if _, err := requireWorkspaceRole(s.store, *workspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{*workspaceID}, nil
+	}
+
+	memberships, err := s.store.Workspaces.ListMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceIDs := make([]uuid.UUID, len(memberships))
+	for i, membership := range memberships {
+		workspaceIDs[i] = membership.WorkspaceID
+	}
+	return workspaceIDs, nil
+}
+
+// RecordClick counts a click for the link and stores its click event. The expiry
+// and click budget are checked atomically with the increment, so concurrent
+// redirects cannot go past max_clicks.
+func (s *LinkService) RecordClick(shortCode string, event *models.ClickEvent) error {
+	return s.store.Transaction(func(tx *repositories.Store) error {
+		claimed, err := tx.Links.ClaimClick(shortCode, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if !claimed {
+			return errors.New("link expired")
+		}
+
+		if event != nil {
+			return tx.ClickEvents.Create(event)
+		}
+
+		return nil
+	})
+}
+
+// HashIP returns a salted hash of a visitor's IP address so click events never
+// store the raw address
+func (s *LinkService) HashIP(ip string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.IPHashSalt))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LinkService) ListClickEvents(userID, linkID uuid.UUID, from, to *time.Time, limit, offset int) (*models.ClickEventListResponse, error) {
+	if _, err := findWorkspaceLink(s.store, userID, linkID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
 	}
 
-	return &models.LinkListResponse{
-		Links:  linkResponses,
+	events, total, err := s.store.ClickEvents.List(repositories.ClickEventFilter{
+		LinkID: linkID,
+		From:   from,
+		To:     to,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ClickEventListResponse{
+		Events: events,
 		Total:  total,
 		Limit:  limit,
 		Offset: offset,
 	}, nil
 }
 
-func (s *LinkService) RecordClick(shortCode string) error {
-	now := time.Now()
-	return s.db.Model(&models.Link{}).
-		Where("short_code = ?", shortCode).
-		Updates(map[string]interface{}{
-			"click_count":     gorm.Expr("click_count + 1"),
-			"last_clicked_at": now,
-		}).Error
+// CheckLinkPassword verifies the password of a password-protected link
+func (s *LinkService) CheckLinkPassword(link *models.Link, password string) bool {
+	if link.PasswordHash == nil {
+		return true
+	}
+	return utils.CheckPassword(password, *link.PasswordHash)
+}
+
+// IssueUnlockToken creates a signed token proving that the visitor entered the
+// link's password. It stops being valid when it expires or the password changes.
+func (s *LinkService) IssueUnlockToken(link *models.Link) (string, time.Time) {
+	expiresAt := time.Now().Add(s.cfg.LinkUnlockTTL)
+	payload := fmt.Sprintf("%s.%d", link.ID, expiresAt.Unix())
+	return payload + "." + s.signUnlockPayload(link, payload), expiresAt
+}
+
+// VerifyUnlockToken checks a token issued by IssueUnlockToken
+func (s *LinkService) VerifyUnlockToken(link *models.Link, token string) bool {
+	idx := strings.LastIndex(token, ".")
+	if idx < 0 {
+		return false
+	}
+	payload, signature := token[:idx], token[idx+1:]
+
+	if !hmac.Equal([]byte(signature), []byte(s.signUnlockPayload(link, payload))) {
+		return false
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 2 || parts[0] != link.ID.String() {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Unix() < expiresAt
+}
+
+func (s *LinkService) signUnlockPayload(link *models.Link, payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.JWTSecret))
+	mac.Write([]byte("unlock:" + payload))
+	if link.PasswordHash != nil {
+		mac.Write([]byte(*link.PasswordHash))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-func (s *LinkService) generateUniqueShortCode() (string, error) {
+// generateShortCode is replaced in tests to force collisions
+var generateShortCode = utils.GenerateShortCode
+
+func (s *LinkService) generateUniqueShortCode(taken map[string]bool) (string, error) {
 	maxAttempts := 10
 
 	for i := 0; i < maxAttempts; i++ {
-		shortCode, err := utils.GenerateShortCode(8)
+		shortCode, err := generateShortCode(8)
 		if err != nil {
 			return "", err
 		}
 
-		if utils.IsReservedShortCode(shortCode) {
+		if utils.IsReservedShortCode(shortCode) || taken[shortCode] {
 			continue
 		}
 
-		var existingLink models.Link
-		if err := s.db.Where("short_code = ?", shortCode).First(&existingLink).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return shortCode, nil
-			}
+		exists, err := s.store.Links.ShortCodeExists(shortCode)
+		if err != nil {
 			return "", err
 		}
+		if !exists {
+			return shortCode, nil
+		}
 	}
 
 	return "", errors.New("failed to generate unique short code")
@@ -232,15 +736,22 @@ func (s *LinkService) generateUniqueShortCode() (string, error) {
 
 func (s *LinkService) linkToResponse(link *models.Link) *models.LinkResponse {
 	return &models.LinkResponse{
-		ID:            link.ID,
-		ShortCode:     link.ShortCode,
-		ShortURL:      fmt.Sprintf("%s/%s", s.cfg.BaseURL, link.ShortCode),
-		TargetURL:     link.TargetURL,
-		Title:         link.Title,
-		IsActive:      link.IsActive,
-		ClickCount:    link.ClickCount,
-		LastClickedAt: link.LastClickedAt,
-		CreatedAt:     link.CreatedAt,
-		UpdatedAt:     link.UpdatedAt,
+		ID:                link.ID,
+		WorkspaceID:       link.WorkspaceID,
+		FolderID:          link.FolderID,
+		Tags:              tagNames(link.Tags),
+		ShortCode:         link.ShortCode,
+		ShortURL:          fmt.Sprintf("%s/%s", s.cfg.BaseURL, link.ShortCode),
+		TargetURL:         link.TargetURL,
+		Title:             link.Title,
+		IsActive:          link.IsActive,
+		ClickCount:        link.ClickCount,
+		LastClickedAt:     link.LastClickedAt,
+		ExpiresAt:         link.ExpiresAt,
+		MaxClicks:         link.MaxClicks,
+		PasswordProtected: link.IsPasswordProtected(),
+		DisabledAt:        link.DisabledAt,
+		CreatedAt:         link.CreatedAt,
+		UpdatedAt:         link.UpdatedAt,
 	}
 }
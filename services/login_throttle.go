@@ -0,0 +1,175 @@
+package services
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+var loginMetrics = expvar.NewMap("logins")
+
+const (
+	// loginDelayAfter is the number of failed logins after which every further
+	// attempt has to wait, starting at one second and doubling each time
+	loginDelayAfter = 3
+	maxLoginDelay   = 30 * time.Second
+)
+
+// AccountLockedError is returned for a login while its email is locked out or
+// has to wait after failed attempts
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return "account locked"
+}
+
+// LoginThrottle counts failed logins per normalized email, independent of the
+// client IP. After a few failures attempts are delayed progressively, and after
+// maxFailures failures within the window the email is locked out. Counters are
+// kept in memory per instance.
+type LoginThrottle struct {
+	mutex       sync.Mutex
+	entries     map[string]*loginFailures
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+}
+
+type loginFailures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewLoginThrottle creates a throttle. A maxFailures of 0 disables it.
+func NewLoginThrottle(maxFailures int, window, lockout time.Duration) *LoginThrottle {
+	lt := &LoginThrottle{
+		entries:     make(map[string]*loginFailures),
+		maxFailures: maxFailures,
+		window:      window,
+		lockout:     lockout,
+	}
+
+	if maxFailures > 0 {
+		// Start cleanup goroutine
+		go lt.cleanup()
+	}
+
+	return lt
+}
+
+// Attempt reserves a login attempt for the email. The attempt counts as a
+// failure right away, under the same lock as the check, so parallel attempts
+// cannot pass the throttle before their failures are recorded. It returns how
+// long the email has to wait when it is throttled, and otherwise the number of
+// failures including this attempt. Attempts that turn out to be successful
+// are taken back with Reset or Release.
+func (lt *LoginThrottle) Attempt(email string) (wait time.Duration, failures int) {
+	if lt.maxFailures <= 0 {
+		return 0, 0
+	}
+
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+
+	now := time.Now()
+	entry := lt.entries[email]
+	if entry == nil || lt.expired(entry, now) {
+		entry = &loginFailures{}
+		lt.entries[email] = entry
+	}
+
+	if now.Before(entry.lockedUntil) {
+		return entry.lockedUntil.Sub(now), 0
+	}
+
+	if next := entry.lastFailure.Add(loginDelay(entry.count)); now.Before(next) {
+		return next.Sub(now), 0
+	}
+
+	entry.count++
+	entry.lastFailure = now
+
+	if entry.count >= lt.maxFailures {
+		entry.lockedUntil = now.Add(lt.lockout)
+	}
+
+	return 0, entry.count
+}
+
+// Release takes back an attempt that did not fail, like a right password that
+// still waits for the second factor
+func (lt *LoginThrottle) Release(email string) {
+	if lt.maxFailures <= 0 {
+		return
+	}
+
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+
+	entry := lt.entries[email]
+	if entry == nil {
+		return
+	}
+
+	entry.count--
+	if entry.count <= 0 {
+		delete(lt.entries, email)
+		return
+	}
+	if entry.count < lt.maxFailures {
+		entry.lockedUntil = time.Time{}
+	}
+}
+
+// Reset forgets the failures of an email after a successful login
+func (lt *LoginThrottle) Reset(email string) {
+	if lt.maxFailures <= 0 {
+		return
+	}
+
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+
+	delete(lt.entries, email)
+}
+
+// expired reports whether an entry no longer affects logins: its lockout is
+// over, or its last failure is older than the window
+func (lt *LoginThrottle) expired(entry *loginFailures, now time.Time) bool {
+	if !entry.lockedUntil.IsZero() {
+		return !now.Before(entry.lockedUntil)
+	}
+	return now.Sub(entry.lastFailure) > lt.window
+}
+
+func (lt *LoginThrottle) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		lt.mutex.Lock()
+		now := time.Now()
+		for email, entry := range lt.entries {
+			if lt.expired(entry, now) {
+				delete(lt.entries, email)
+			}
+		}
+		lt.mutex.Unlock()
+	}
+}
+
+// loginDelay is the wait after the given number of failures
+func loginDelay(failures int) time.Duration {
+	if failures < loginDelayAfter {
+		return 0
+	}
+
+	delay := time.Second << (failures - loginDelayAfter)
+	if delay > maxLoginDelay || delay <= 0 {
+		return maxLoginDelay
+	}
+	return delay
+}
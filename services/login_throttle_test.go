@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{loginDelayAfter - 1, 0},
+		{loginDelayAfter, time.Second},
+		{loginDelayAfter + 1, 2 * time.Second},
+		{loginDelayAfter + 4, 16 * time.Second},
+		{loginDelayAfter + 5, maxLoginDelay},
+		{loginDelayAfter + 100, maxLoginDelay},
+	}
+
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	const email = "user@example.com"
+
+	// age moves the last failure, and the lockout, of the email into the past
+	age := func(lt *LoginThrottle, by time.Duration) {
+		entry := lt.entries[email]
+		entry.lastFailure = entry.lastFailure.Add(-by)
+		if !entry.lockedUntil.IsZero() {
+			entry.lockedUntil = entry.lockedUntil.Add(-by)
+		}
+	}
+	attempt := func(t *testing.T, lt *LoginThrottle) (time.Duration, int) {
+		t.Helper()
+		return lt.Attempt(email)
+	}
+
+	t.Run("delays after a few failures", func(t *testing.T) {
+		lt := NewLoginThrottle(10, time.Hour, time.Hour)
+		for i := 1; i <= loginDelayAfter; i++ {
+			if wait, failures := attempt(t, lt); wait != 0 || failures != i {
+				t.Fatalf("attempt %d: wait %v, failures %d", i, wait, failures)
+			}
+		}
+		if wait, _ := attempt(t, lt); wait <= 0 || wait > time.Second {
+			t.Fatalf("attempt after %d failures: wait %v, want up to 1s", loginDelayAfter, wait)
+		}
+
+		age(lt, time.Second)
+		if wait, failures := attempt(t, lt); wait != 0 || failures != loginDelayAfter+1 {
+			t.Fatalf("attempt after the delay: wait %v, failures %d", wait, failures)
+		}
+		if wait, _ := attempt(t, lt); wait <= time.Second || wait > 2*time.Second {
+			t.Fatalf("next attempt: wait %v, want up to 2s", wait)
+		}
+	})
+
+	t.Run("locks out at maxFailures", func(t *testing.T) {
+		lt := NewLoginThrottle(2, time.Hour, 10*time.Minute)
+		attempt(t, lt)
+		if wait, failures := attempt(t, lt); wait != 0 || failures != 2 {
+			t.Fatalf("second attempt: wait %v, failures %d", wait, failures)
+		}
+		if wait, _ := attempt(t, lt); wait <= 9*time.Minute || wait > 10*time.Minute {
+			t.Fatalf("attempt while locked out: wait %v, want the lockout", wait)
+		}
+	})
+
+	t.Run("lockout expires", func(t *testing.T) {
+		lt := NewLoginThrottle(2, time.Hour, 10*time.Minute)
+		attempt(t, lt)
+		attempt(t, lt)
+		age(lt, 10*time.Minute)
+		if wait, failures := attempt(t, lt); wait != 0 || failures != 1 {
+			t.Fatalf("attempt after the lockout: wait %v, failures %d", wait, failures)
+		}
+	})
+
+	t.Run("failures expire after the window", func(t *testing.T) {
+		lt := NewLoginThrottle(5, time.Minute, time.Hour)
+		attempt(t, lt)
+		attempt(t, lt)
+		age(lt, time.Minute+time.Second)
+		if wait, failures := attempt(t, lt); wait != 0 || failures != 1 {
+			t.Fatalf("attempt after the window: wait %v, failures %d", wait, failures)
+		}
+	})
+
+	t.Run("reset forgets failures", func(t *testing.T) {
+		lt := NewLoginThrottle(2, time.Hour, time.Hour)
+		attempt(t, lt)
+		attempt(t, lt)
+		lt.Reset(email)
+		if wait, failures := attempt(t, lt); wait != 0 || failures != 1 {
+			t.Fatalf("attempt after reset: wait %v, failures %d", wait, failures)
+		}
+	})
+
+	t.Run("release takes back an attempt", func(t *testing.T) {
+		lt := NewLoginThrottle(2, time.Hour, time.Hour)
+		attempt(t, lt)
+		attempt(t, lt)
+		lt.Release(email)
+		if wait, failures := attempt(t, lt); wait != 0 || failures != 2 {
+			t.Fatalf("attempt after release: wait %v, failures %d", wait, failures)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		lt := NewLoginThrottle(0, time.Hour, time.Hour)
+		for i := 0; i < 10; i++ {
+			if wait, _ := attempt(t, lt); wait != 0 {
+				t.Fatalf("attempt %d: wait %v", i, wait)
+			}
+		}
+	})
+}
+
+func TestLoginThrottleConcurrentWrongPasswords(t *testing.T) {
+	cfg := testConfig()
+	s, store := newTestAuthService(t, cfg)
+	createTestUser(t, store, "target@example.com")
+
+	const attempts = 30
+	var checked, throttled int
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := s.Login(&models.UserLoginRequest{Email: "target@example.com", Password: "wrong-password"}, models.ClientInfo{})
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			var locked *AccountLockedError
+			switch {
+			case errors.As(err, &locked):
+				throttled++
+			case err != nil && err.Error() == "invalid credentials":
+				// Only attempts that passed the throttle check the password
+				checked++
+			default:
+				t.Errorf("login: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if checked > cfg.LoginMaxFailures {
+		t.Fatalf("%d wrong passwords were checked, want at most %d", checked, cfg.LoginMaxFailures)
+	}
+	if checked+throttled != attempts {
+		t.Fatalf("%d checked and %d throttled of %d attempts", checked, throttled, attempts)
+	}
+}
+
+func TestLoginResetsFailuresAfterSecondFactor(t *testing.T) {
+	cfg := testConfig()
+	s, store := newTestAuthService(t, cfg)
+	user := createTestUser(t, store, "2fa@example.com")
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user.TOTPSecret = &secret
+	user.TOTPEnabledAt = &now
+	if err := store.Users.Update(user, "totp_secret", "totp_enabled_at"); err != nil {
+		t.Fatal(err)
+	}
+	const recoveryCode = "abcde-fghij"
+	if err := store.RecoveryCodes.ReplaceForUser(user.ID, []string{hashToken(utils.NormalizeRecoveryCode(recoveryCode))}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.Login(&models.UserLoginRequest{Email: user.Email, Password: "wrong-password"}, models.ClientInfo{}); err == nil {
+		t.Fatal("login with a wrong password succeeded")
+	}
+
+	// The right password alone neither counts as a failure nor forgets the
+	// earlier one
+	_, challenge, err := s.Login(&models.UserLoginRequest{Email: user.Email, Password: testPassword}, models.ClientInfo{})
+	if err != nil || challenge == nil {
+		t.Fatalf("login: challenge %v, error %v", challenge, err)
+	}
+	if entry := s.throttle.entries[user.Email]; entry == nil || entry.count != 1 {
+		t.Fatalf("failures after the password step: %+v, want 1", entry)
+	}
+
+	if _, err := s.CompleteTwoFactorLogin(&models.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: recoveryCode}, models.ClientInfo{}); err != nil {
+		t.Fatalf("second factor: %v", err)
+	}
+	if entry := s.throttle.entries[user.Email]; entry != nil {
+		t.Fatalf("failures after the second factor: %+v, want none", entry)
+	}
+}
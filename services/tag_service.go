@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+)
+
+// maxTagNameLength matches the tags.name column
+const maxTagNameLength = 50
+
+// TagService manages the tags of workspaces. Viewers can list the tags of a
+// workspace and editors can change them.
+type TagService struct {
+	store *repositories.Store
+}
+
+func NewTagService(store *repositories.Store) *TagService {
+	return &TagService{store: store}
+}
+
+// ListTags returns the tags of a workspace, which defaults to the personal
+// workspace of the user
+func (s *TagService) ListTags(userID uuid.UUID, workspaceID *uuid.UUID) (*models.TagListResponse, error) {
+	id := userID
+	if workspaceID != nil {
+		id = *workspaceID
+	}
+	if _, err := requireWorkspaceRole(s.store, id, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	tags, err := s.store.Tags.List(id)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.TagResponse, len(tags))
+	for i, tag := range tags {
+		responses[i] = *tagToResponse(&tag)
+	}
+
+	return &models.TagListResponse{Tags: responses}, nil
+}
+
+func (s *TagService) CreateTag(userID uuid.UUID, req *models.TagCreateRequest) (*models.TagResponse, error) {
+	workspaceID := userID
+	if req.WorkspaceID != nil {
+		workspaceID = *req.WorkspaceID
+	}
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleEditor); err != nil {
+		return nil, err
+	}
+
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := models.Tag{WorkspaceID: workspaceID, Name: name}
+	if err := s.store.Tags.Create(&tag); err != nil {
+		if errors.Is(err, repositories.ErrDuplicate) {
+			return nil, errors.New("tag already exists")
+		}
+		return nil, err
+	}
+
+	return tagToResponse(&tag), nil
+}
+
+// UpdateTag renames a tag, which renames it on all of its links
+func (s *TagService) UpdateTag(userID, tagID uuid.UUID, req *models.TagUpdateRequest) (*models.TagResponse, error) {
+	tag, err := s.findTag(userID, tagID, models.WorkspaceRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != tag.Name {
+		tag.Name = name
+		if err := s.store.Tags.Update(tag, "name"); err != nil {
+			if errors.Is(err, repositories.ErrDuplicate) {
+				return nil, errors.New("tag already exists")
+			}
+			return nil, err
+		}
+	}
+
+	return tagToResponse(tag), nil
+}
+
+// DeleteTag deletes a tag and removes it from its links
+func (s *TagService) DeleteTag(userID, tagID uuid.UUID) error {
+	tag, err := s.findTag(userID, tagID, models.WorkspaceRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Tags.Delete(tag.ID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("tag not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// findTag loads a tag of a workspace where the user has at least the given
+// role. Tags of other workspaces are reported as not found.
+func (s *TagService) findTag(userID, tagID uuid.UUID, role string) (*models.Tag, error) {
+	tag, err := s.store.Tags.FindByID(tagID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("tag not found")
+		}
+		return nil, err
+	}
+
+	if _, err := requireWorkspaceRole(s.store, tag.WorkspaceID, userID, role); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, errors.New("tag not found")
+		}
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// normalizeTagName trims and lowercases a tag name. Commas are rejected
+// because CSV imports and exports separate tags with them.
+func normalizeTagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > maxTagNameLength || strings.Contains(name, ",") {
+		return "", errors.New("invalid tag name: must be 1 to 50 characters without commas")
+	}
+	return name, nil
+}
+
+// normalizeTagNames normalizes tag names and drops duplicates
+func normalizeTagNames(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name, err := normalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// findOrCreateTags returns the tags of a workspace with the given names,
+// creating the missing ones
+func findOrCreateTags(tx *repositories.Store, workspaceID uuid.UUID, names []string) ([]models.Tag, error) {
+	existing, err := tx.Tags.FindByNames(workspaceID, names)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]models.Tag, len(existing))
+	for _, tag := range existing {
+		byName[tag.Name] = tag
+	}
+
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tag, ok := byName[name]
+		if !ok {
+			tag = models.Tag{WorkspaceID: workspaceID, Name: name}
+			if err := tx.Tags.Create(&tag); err != nil {
+				if errors.Is(err, repositories.ErrDuplicate) {
+					// Another request created the tag since it was looked up
+					return nil, errors.New("tag already exists")
+				}
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// setLinkTags replaces the tags of a link with the named tags of its
+// workspace and stores them in link.Tags
+func setLinkTags(tx *repositories.Store, link *models.Link, names []string) error {
+	tags, err := findOrCreateTags(tx, link.WorkspaceID, names)
+	if err != nil {
+		return err
+	}
+
+	tagIDs := make([]uuid.UUID, len(tags))
+	for i, tag := range tags {
+		tagIDs[i] = tag.ID
+	}
+	if err := tx.Links.SetTags(link.ID, tagIDs); err != nil {
+		return err
+	}
+
+	link.Tags = tags
+	return nil
+}
+
+// tagNames returns the sorted names of tags
+func tagNames(tags []models.Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func tagToResponse(tag *models.Tag) *models.TagResponse {
+	return &models.TagResponse{
+		ID:          tag.ID,
+		WorkspaceID: tag.WorkspaceID,
+		Name:        tag.Name,
+		CreatedAt:   tag.CreatedAt,
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+const (
+	// totpIssuer names the account in authenticator apps
+	totpIssuer = "CleanShort"
+	// recoveryCodeCount is how many recovery codes a user gets on enrolment
+	recoveryCodeCount = 10
+)
+
+// TwoFactorService manages TOTP enrolment of the current user
+type TwoFactorService struct {
+	store *repositories.Store
+	cfg   *config.Config
+}
+
+func NewTwoFactorService(store *repositories.Store, cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// GetStatus reports whether two-factor authentication is on and how many
+// recovery codes are left
+func (s *TwoFactorService) GetStatus(userID uuid.UUID) (*models.TwoFactorStatusResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := s.store.RecoveryCodes.CountUnused(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorStatusResponse{
+		Enabled:                user.IsTwoFactorEnabled(),
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+// Enable starts enrolment by generating a new secret. Two-factor
+// authentication is only turned on once Confirm accepts a code for it.
+func (s *TwoFactorService) Enable(userID uuid.UUID, req *models.TwoFactorEnableRequest) (*models.TwoFactorSetupResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.CheckPassword(req.Password, user.Password) {
+		return nil, errors.New("invalid password")
+	}
+
+	if user.IsTwoFactorEnabled() {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPSecret = &secret
+	user.TOTPLastStep = nil
+	user.UpdatedAt = time.Now()
+	if err := s.store.Users.Update(user, "totp_secret", "totp_last_step", "updated_at"); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: utils.TOTPURL(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm turns two-factor authentication on once the user proves their
+// authenticator works, and returns the recovery codes
+func (s *TwoFactorService) Confirm(userID uuid.UUID, req *models.TwoFactorConfirmRequest) (*models.RecoveryCodesResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsTwoFactorEnabled() {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	if user.TOTPSecret == nil {
+		return nil, errors.New("two-factor authentication not set up")
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	codeHashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		codeHashes[i] = hashToken(utils.NormalizeRecoveryCode(code))
+	}
+
+	err = s.store.Transaction(func(tx *repositories.Store) error {
+		valid, err := verifyTOTP(tx, user, req.Code)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return errors.New("invalid two-factor code")
+		}
+
+		now := time.Now()
+		user.TOTPEnabledAt = &now
+		user.UpdatedAt = now
+		if err := tx.Users.Update(user, "totp_enabled_at", "updated_at"); err != nil {
+			return err
+		}
+
+		return tx.RecoveryCodes.ReplaceForUser(userID, codeHashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable turns two-factor authentication off. It takes the password and a
+// current code, so a stolen access token alone cannot remove the second factor.
+func (s *TwoFactorService) Disable(userID uuid.UUID, req *models.TwoFactorDisableRequest) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckPassword(req.Password, user.Password) {
+		return errors.New("invalid password")
+	}
+
+	if !user.IsTwoFactorEnabled() {
+		return errors.New("two-factor authentication not enabled")
+	}
+
+	return s.store.Transaction(func(tx *repositories.Store) error {
+		valid, err := verifySecondFactor(tx, user, req.Code)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return errors.New("invalid two-factor code")
+		}
+
+		user.TOTPSecret = nil
+		user.TOTPEnabledAt = nil
+		user.TOTPLastStep = nil
+		user.UpdatedAt = time.Now()
+		if err := tx.Users.Update(user, "totp_secret", "totp_enabled_at", "totp_last_step", "updated_at"); err != nil {
+			return err
+		}
+
+		return tx.RecoveryCodes.DeleteByUser(userID)
+	})
+}
+
+func (s *TwoFactorService) findUser(userID uuid.UUID) (*models.User, error) {
+	user, err := s.store.Users.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// verifySecondFactor accepts a TOTP code or an unused recovery code of the
+// user. Either can only be used once.
+func verifySecondFactor(tx *repositories.Store, user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if isTOTPCode(code) {
+		return verifyTOTP(tx, user, code)
+	}
+
+	return tx.RecoveryCodes.Use(user.ID, hashToken(utils.NormalizeRecoveryCode(code)), time.Now())
+}
+
+// verifyTOTP checks a TOTP code and records its time step so that the same
+// code cannot be used again
+func verifyTOTP(tx *repositories.Store, user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == nil {
+		return false, nil
+	}
+
+	step, ok := utils.ValidateTOTP(*user.TOTPSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return tx.Users.UseTOTPStep(user.ID, step)
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
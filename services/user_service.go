@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+// UserService manages the account of the current user
+type UserService struct {
+	store       *repositories.Store
+	cfg         *config.Config
+	authService *AuthService
+	linkService *LinkService
+}
+
+func NewUserService(store *repositories.Store, cfg *config.Config, authService *AuthService, linkService *LinkService) *UserService {
+	return &UserService{
+		store:       store,
+		cfg:         cfg,
+		authService: authService,
+		linkService: linkService,
+	}
+}
+
+func (s *UserService) GetMe(userID uuid.UUID) (*models.MeResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	total, active, err := s.store.Links.CountByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MeResponse{
+		UserResponse: *userToResponse(user),
+		Links: models.LinkCounts{
+			Total:  total,
+			Active: active,
+		},
+	}, nil
+}
+
+// UpdateEmail requests a new email for the user after checking their
+// password. The new email is kept as pending and sent a verification email;
+// the user logs in with their current email until it is verified. Requesting
+// the current email cancels a pending change.
+func (s *UserService) UpdateEmail(userID uuid.UUID, req *models.MeUpdateRequest) (*models.UserResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.CheckPassword(req.CurrentPassword, user.Password) {
+		return nil, errors.New("invalid password")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == user.Email {
+		if user.PendingEmail != nil {
+			user.PendingEmail = nil
+			user.UpdatedAt = time.Now()
+			if err := s.store.Users.Update(user, "pending_email", "updated_at"); err != nil {
+				return nil, err
+			}
+		}
+		return userToResponse(user), nil
+	}
+
+	if _, err := s.store.Users.FindByEmail(email); err == nil {
+		return nil, errors.New("email already in use")
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	user.PendingEmail = &email
+	user.UpdatedAt = time.Now()
+
+	if err := s.store.Users.Update(user, "pending_email", "updated_at"); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.sendEmailChangeEmail(user); err != nil {
+		log.Printf("Failed to send email change email to user %s: %v", user.ID, err)
+	}
+
+	return userToResponse(user), nil
+}
+
+// ResendVerificationEmail sends a new verification email for the pending
+// email of the user, or else for their current email
+func (s *UserService) ResendVerificationEmail(userID uuid.UUID) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.PendingEmail != nil {
+		return s.authService.sendEmailChangeEmail(user)
+	}
+
+	if user.IsEmailVerified() {
+		return errors.New("email already verified")
+	}
+
+	return s.authService.sendVerificationEmail(user)
+}
+
+// ChangePassword sets a new password and ends every other session of the
+// user. currentSessionID is the session to keep, if the request came from one.
+func (s *UserService) ChangePassword(userID uuid.UUID, currentSessionID *uuid.UUID, req *models.PasswordChangeRequest) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckPassword(req.CurrentPassword, user.Password) {
+		return errors.New("invalid password")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Transaction(func(tx *repositories.Store) error {
+		user.Password = hashedPassword
+		user.UpdatedAt = time.Now()
+		if err := tx.Users.Update(user, "password", "updated_at"); err != nil {
+			return err
+		}
+
+		return revokeOtherSessions(tx, userID, currentSessionID)
+	})
+}
+
+// DeleteAccount deletes the user after checking their password. The personal
+// workspace and shared workspaces without other members are deleted with
+// their links; links in the other shared workspaces stay there. Tokens and
+// API keys are removed by the cascading foreign keys. Sole owners of shared
+// workspaces have to hand over ownership first.
+func (s *UserService) DeleteAccount(userID uuid.UUID, req *models.AccountDeleteRequest) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckPassword(req.Password, user.Password) {
+		return errors.New("invalid password")
+	}
+
+	var shortCodes []string
+	err = s.store.Transaction(func(tx *repositories.Store) error {
+		shortCodes, err = leaveWorkspaces(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Users.Delete(userID); err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return errors.New("user not found")
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.linkService.ForgetShortCodes(shortCodes)
+
+	return nil
+}
+
+func (s *UserService) findUser(userID uuid.UUID) (*models.User, error) {
+	user, err := s.store.Users.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// revokeOtherSessions revokes every session of the user except keepSessionID
+func revokeOtherSessions(store *repositories.Store, userID uuid.UUID, keepSessionID *uuid.UUID) error {
+	if keepSessionID == nil {
+		return store.RefreshTokens.RevokeAllForUser(userID)
+	}
+
+	tokens, err := store.RefreshTokens.ListActiveByUser(userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if token.FamilyID == *keepSessionID {
+			continue
+		}
+		if _, err := store.RefreshTokens.RevokeFamily(token.FamilyID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func userToResponse(user *models.User) *models.UserResponse {
+	return &models.UserResponse{
+		ID:               user.ID,
+		Email:            user.Email,
+		EmailVerified:    user.IsEmailVerified(),
+		PendingEmail:     user.PendingEmail,
+		TwoFactorEnabled: user.IsTwoFactorEnabled(),
+		Role:             user.Role,
+		CreatedAt:        user.CreatedAt,
+	}
+}
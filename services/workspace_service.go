@@ -0,0 +1,480 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/mailer"
+	"github.com/zhakazx/cleanshort/models"
+	"github.com/zhakazx/cleanshort/repositories"
+	"github.com/zhakazx/cleanshort/utils"
+)
+
+// personalWorkspaceName is the initial name of every personal workspace
+const personalWorkspaceName = "Personal"
+
+// WorkspaceService manages workspaces, their members and invitations
+type WorkspaceService struct {
+	store       *repositories.Store
+	cfg         *config.Config
+	authService *AuthService
+}
+
+func NewWorkspaceService(store *repositories.Store, cfg *config.Config, authService *AuthService) *WorkspaceService {
+	return &WorkspaceService{
+		store:       store,
+		cfg:         cfg,
+		authService: authService,
+	}
+}
+
+func (s *WorkspaceService) ListWorkspaces(userID uuid.UUID) (*models.WorkspaceListResponse, error) {
+	memberships, err := s.store.Workspaces.ListMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces := make([]models.WorkspaceResponse, len(memberships))
+	for i, membership := range memberships {
+		workspaces[i] = *workspaceToResponse(&membership)
+	}
+
+	return &models.WorkspaceListResponse{Workspaces: workspaces}, nil
+}
+
+// CreateWorkspace creates a shared workspace owned by the user
+func (s *WorkspaceService) CreateWorkspace(userID uuid.UUID, req *models.WorkspaceCreateRequest) (*models.WorkspaceResponse, error) {
+	workspace := models.Workspace{Name: strings.TrimSpace(req.Name)}
+	member := models.WorkspaceMember{UserID: userID, Role: models.WorkspaceRoleOwner}
+
+	err := s.store.Transaction(func(tx *repositories.Store) error {
+		if err := tx.Workspaces.Create(&workspace); err != nil {
+			return err
+		}
+
+		member.WorkspaceID = workspace.ID
+		return tx.Workspaces.AddMember(&member)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	member.Workspace = workspace
+	return workspaceToResponse(&member), nil
+}
+
+func (s *WorkspaceService) GetWorkspace(userID, workspaceID uuid.UUID) (*models.WorkspaceResponse, error) {
+	member, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return workspaceToResponse(member), nil
+}
+
+func (s *WorkspaceService) UpdateWorkspace(userID, workspaceID uuid.UUID, req *models.WorkspaceUpdateRequest) (*models.WorkspaceResponse, error) {
+	member, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := member.Workspace
+	workspace.Name = strings.TrimSpace(req.Name)
+	workspace.UpdatedAt = time.Now()
+	if err := s.store.Workspaces.Update(&workspace, "name", "updated_at"); err != nil {
+		return nil, err
+	}
+
+	member.Workspace = workspace
+	return workspaceToResponse(member), nil
+}
+
+// DeleteWorkspace deletes a shared workspace. Its links have to be moved or
+// deleted first, so that deleting a workspace never deletes links.
+func (s *WorkspaceService) DeleteWorkspace(userID, workspaceID uuid.UUID) error {
+	member, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if member.Workspace.Personal {
+		return errors.New("personal workspaces cannot be deleted")
+	}
+
+	return s.store.Transaction(func(tx *repositories.Store) error {
+		count, err := tx.Links.CountByWorkspace(workspaceID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("workspace has links")
+		}
+
+		return tx.Workspaces.Delete(workspaceID)
+	})
+}
+
+func (s *WorkspaceService) ListMembers(userID, workspaceID uuid.UUID) (*models.WorkspaceMemberListResponse, error) {
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err := s.store.Workspaces.ListMembers(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.WorkspaceMemberResponse, len(members))
+	for i, member := range members {
+		responses[i] = models.WorkspaceMemberResponse{
+			UserID:    member.UserID,
+			Email:     member.User.Email,
+			Role:      member.Role,
+			CreatedAt: member.CreatedAt,
+		}
+	}
+
+	return &models.WorkspaceMemberListResponse{Members: responses}, nil
+}
+
+// UpdateMemberRole changes the role of a member. A workspace always keeps at
+// least one owner.
+func (s *WorkspaceService) UpdateMemberRole(userID, workspaceID, memberID uuid.UUID, req *models.WorkspaceMemberUpdateRequest) error {
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	return s.store.Transaction(func(tx *repositories.Store) error {
+		members, err := tx.Workspaces.ListMembers(workspaceID)
+		if err != nil {
+			return err
+		}
+
+		target := findMember(members, memberID)
+		if target == nil {
+			return errors.New("member not found")
+		}
+
+		if target.Role == models.WorkspaceRoleOwner && req.Role != models.WorkspaceRoleOwner && len(otherOwners(members, memberID)) == 0 {
+			return errors.New("workspace must keep an owner")
+		}
+
+		return tx.Workspaces.UpdateMemberRole(workspaceID, memberID, req.Role)
+	})
+}
+
+// RemoveMember removes a member from a shared workspace. Owners can remove
+// anyone and every member can leave. Links the member created stay in the
+// workspace and are credited to the owner removing them, or to another owner
+// when the member leaves.
+func (s *WorkspaceService) RemoveMember(userID, workspaceID, memberID uuid.UUID) error {
+	requiredRole := models.WorkspaceRoleOwner
+	if userID == memberID {
+		requiredRole = models.WorkspaceRoleViewer
+	}
+
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, requiredRole); err != nil {
+		return err
+	}
+
+	return s.store.Transaction(func(tx *repositories.Store) error {
+		members, err := tx.Workspaces.ListMembers(workspaceID)
+		if err != nil {
+			return err
+		}
+
+		if findMember(members, memberID) == nil {
+			return errors.New("member not found")
+		}
+
+		owners := otherOwners(members, memberID)
+		if len(owners) == 0 {
+			return errors.New("workspace must keep an owner")
+		}
+
+		successor := owners[0]
+		if userID != memberID {
+			successor = userID
+		}
+
+		if err := tx.Links.ReassignCreator(workspaceID, memberID, successor); err != nil {
+			return err
+		}
+
+		return tx.Workspaces.RemoveMember(workspaceID, memberID)
+	})
+}
+
+// CreateInvitation emails an invitation to join a shared workspace
+func (s *WorkspaceService) CreateInvitation(userID, workspaceID uuid.UUID, req *models.WorkspaceInvitationCreateRequest) (*models.WorkspaceInvitationResponse, error) {
+	member, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if member.Workspace.Personal {
+		return nil, errors.New("personal workspaces cannot be shared")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	if invitee, err := s.store.Users.FindByEmail(email); err == nil {
+		if _, err := s.store.Workspaces.FindMember(workspaceID, invitee.ID); err == nil {
+			return nil, errors.New("already a member")
+		} else if !errors.Is(err, repositories.ErrNotFound) {
+			return nil, err
+		}
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	token, err := utils.GenerateEmailToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := models.WorkspaceInvitation{
+		WorkspaceID: workspaceID,
+		Email:       email,
+		Role:        req.Role,
+		TokenHash:   hashToken(token),
+		InvitedBy:   &userID,
+		ExpiresAt:   time.Now().Add(s.cfg.WorkspaceInviteTTL),
+	}
+
+	if err := s.store.WorkspaceInvitations.Create(&invitation); err != nil {
+		return nil, err
+	}
+
+	s.authService.sendMail(&mailer.Message{
+		To:      email,
+		Subject: "You are invited to a CleanShort workspace",
+		Body: emailBody(
+			"You have been invited to join the CleanShort workspace \""+member.Workspace.Name+"\" as "+req.Role+". "+
+				"Sign in or register with this email address to accept.",
+			s.cfg.WorkspaceInviteURL, "POST /api/v1/workspaces/invitations/accept", token, invitation.ExpiresAt),
+	})
+
+	return invitationToResponse(&invitation), nil
+}
+
+func (s *WorkspaceService) ListInvitations(userID, workspaceID uuid.UUID) (*models.WorkspaceInvitationListResponse, error) {
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.store.WorkspaceInvitations.ListPending(workspaceID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.WorkspaceInvitationResponse, len(invitations))
+	for i, invitation := range invitations {
+		responses[i] = *invitationToResponse(&invitation)
+	}
+
+	return &models.WorkspaceInvitationListResponse{Invitations: responses}, nil
+}
+
+func (s *WorkspaceService) RevokeInvitation(userID, workspaceID, invitationID uuid.UUID) error {
+	if _, err := requireWorkspaceRole(s.store, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	if err := s.store.WorkspaceInvitations.Delete(workspaceID, invitationID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errors.New("invitation not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// AcceptInvitation adds the user to the workspace of an invitation. The
+// invitation has to be pending and sent to the user's verified email.
+func (s *WorkspaceService) AcceptInvitation(userID uuid.UUID, req *models.WorkspaceInvitationAcceptRequest) (*models.WorkspaceResponse, error) {
+	var response *models.WorkspaceResponse
+
+	err := s.store.Transaction(func(tx *repositories.Store) error {
+		invitation, err := tx.WorkspaceInvitations.FindByHash(hashToken(req.Token))
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return errors.New("invalid or expired invitation")
+			}
+			return err
+		}
+
+		if !invitation.IsPending() {
+			return errors.New("invalid or expired invitation")
+		}
+
+		user, err := tx.Users.FindByID(userID)
+		if err != nil {
+			return err
+		}
+
+		if user.Email != invitation.Email {
+			return errors.New("invitation was sent to another email")
+		}
+		if !user.IsEmailVerified() {
+			return errors.New("email not verified")
+		}
+
+		accepted, err := tx.WorkspaceInvitations.Accept(invitation.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return errors.New("invalid or expired invitation")
+		}
+
+		member := models.WorkspaceMember{
+			WorkspaceID: invitation.WorkspaceID,
+			UserID:      userID,
+			Role:        invitation.Role,
+		}
+		if err := tx.Workspaces.AddMember(&member); err != nil {
+			if errors.Is(err, repositories.ErrDuplicate) {
+				return errors.New("already a member")
+			}
+			return err
+		}
+
+		member.Workspace = invitation.Workspace
+		response = workspaceToResponse(&member)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s joined workspace %s", userID, response.ID)
+	return response, nil
+}
+
+// leaveWorkspaces prepares the deletion of a user's account. Shared
+// workspaces without other members are deleted; in the others the links the
+// user created are credited to another owner. It returns the short codes of
+// the deleted links.
+func leaveWorkspaces(tx *repositories.Store, userID uuid.UUID) ([]string, error) {
+	memberships, err := tx.Workspaces.ListMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var shortCodes []string
+	for _, membership := range memberships {
+		members, err := tx.Workspaces.ListMembers(membership.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+
+		if membership.Workspace.Personal || len(members) == 1 {
+			codes, err := tx.Links.ShortCodesByWorkspace(membership.WorkspaceID)
+			if err != nil {
+				return nil, err
+			}
+			shortCodes = append(shortCodes, codes...)
+
+			if err := tx.Workspaces.Delete(membership.WorkspaceID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		owners := otherOwners(members, userID)
+		if len(owners) == 0 {
+			return nil, errors.New("sole owner of a shared workspace")
+		}
+
+		if err := tx.Links.ReassignCreator(membership.WorkspaceID, userID, owners[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	return shortCodes, nil
+}
+
+// createPersonalWorkspace creates the personal workspace of a new user
+func createPersonalWorkspace(tx *repositories.Store, userID uuid.UUID) error {
+	if err := tx.Workspaces.Create(&models.Workspace{
+		ID:       userID,
+		Name:     personalWorkspaceName,
+		Personal: true,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Workspaces.AddMember(&models.WorkspaceMember{
+		WorkspaceID: userID,
+		UserID:      userID,
+		Role:        models.WorkspaceRoleOwner,
+	})
+}
+
+// requireWorkspaceRole returns the membership of a user with at least the
+// given role. Workspaces the user is not a member of are reported as not
+// found.
+func requireWorkspaceRole(store *repositories.Store, workspaceID, userID uuid.UUID, role string) (*models.WorkspaceMember, error) {
+	member, err := store.Workspaces.FindMember(workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, errors.New("workspace not found")
+		}
+		return nil, err
+	}
+
+	if !models.WorkspaceRoleAtLeast(member.Role, role) {
+		return nil, errors.New("insufficient workspace role")
+	}
+
+	return member, nil
+}
+
+func findMember(members []models.WorkspaceMember, userID uuid.UUID) *models.WorkspaceMember {
+	for i := range members {
+		if members[i].UserID == userID {
+			return &members[i]
+		}
+	}
+	return nil
+}
+
+// otherOwners returns the owners of a workspace other than userID, oldest
+// member first
+func otherOwners(members []models.WorkspaceMember, userID uuid.UUID) []uuid.UUID {
+	var owners []uuid.UUID
+	for _, member := range members {
+		if member.UserID != userID && member.Role == models.WorkspaceRoleOwner {
+			owners = append(owners, member.UserID)
+		}
+	}
+	return owners
+}
+
+func workspaceToResponse(member *models.WorkspaceMember) *models.WorkspaceResponse {
+	return &models.WorkspaceResponse{
+		ID:        member.Workspace.ID,
+		Name:      member.Workspace.Name,
+		Personal:  member.Workspace.Personal,
+		Role:      member.Role,
+		CreatedAt: member.Workspace.CreatedAt,
+		UpdatedAt: member.Workspace.UpdatedAt,
+	}
+}
+
+func invitationToResponse(invitation *models.WorkspaceInvitation) *models.WorkspaceInvitationResponse {
+	return &models.WorkspaceInvitationResponse{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		InvitedBy: invitation.InvitedBy,
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
@@ -8,22 +8,29 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/zhakazx/cleanshort/config"
+	"github.com/zhakazx/cleanshort/jwtkeys"
 	"github.com/zhakazx/cleanshort/middleware"
+	"github.com/zhakazx/cleanshort/models"
 )
 
-func GenerateAccessToken(userID uuid.UUID, email string, cfg *config.Config) (string, error) {
+// GenerateAccessToken issues an access token for the session (refresh token
+// family) it belongs to
+func GenerateAccessToken(user *models.User, sessionID uuid.UUID, keys *jwtkeys.KeySet, cfg *config.Config) (string, error) {
 	claims := middleware.JWTClaims{
-		UserID: userID.String(),
-		Email:  email,
+		UserID:    user.ID.String(),
+		Email:     user.Email,
+		Scope:     models.JoinScopes(models.ScopesForRole(user.Role)),
+		Role:      user.Role,
+		SessionID: sessionID.String(),
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.BaseURL,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.JWTAccessTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   userID.String(),
+			Subject:   user.ID.String(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWTSecret))
+	return keys.Sign(claims)
 }
 
 func GenerateRefreshToken() (string, error) {
@@ -34,13 +41,8 @@ func GenerateRefreshToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
-func ValidateAccessToken(tokenString string, cfg *config.Config) (*middleware.JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &middleware.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(cfg.JWTSecret), nil
-	})
+func ValidateAccessToken(tokenString string, keys *jwtkeys.KeySet) (*middleware.JWTClaims, error) {
+	token, err := keys.Parse(tokenString, &middleware.JWTClaims{})
 
 	if err != nil {
 		return nil, err
@@ -53,6 +53,7 @@ var ReservedShortCodes = map[string]bool{
 	"admin":    true,
 	"healthz":  true,
 	"readyz":   true,
+	"debug":    true,
 	"docs":     true,
 	"swagger":  true,
 	"www":      true,
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// The SHA-1 secret of the RFC 6238 test vectors, "12345678901234567890"
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		code string
+		at   int64
+		step int64
+		ok   bool
+	}{
+		{"287082", 59, 1, true},
+		{"081804", 1111111109, 37037036, true},
+		{"005924", 1234567890, 41152263, true},
+		// One step of clock drift either way is accepted
+		{"081804", 1111111109 + 30, 37037036, true},
+		{"081804", 1111111109 - 30, 37037036, true},
+		{"081804", 1111111109 + 60, 0, false},
+		{"000000", 59, 0, false},
+		{"28708", 59, 0, false},
+	}
+
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(secret, tt.code, time.Unix(tt.at, 0))
+		if ok != tt.ok || step != tt.step {
+			t.Errorf("ValidateTOTP(%q, %d) = %d, %v, want %d, %v", tt.code, tt.at, step, ok, tt.step, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	for _, code := range []string{"k3m9q-x7h2p", "K3M9Q-X7H2P", "k3m9q x7h2p", "k3m9qx7h2p"} {
+		if got := NormalizeRecoveryCode(code); got != "k3m9qx7h2p" {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q", code, got)
+		}
+	}
+}
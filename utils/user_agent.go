@@ -0,0 +1,47 @@
+package utils
+
+import "strings"
+
+// browserSignatures is checked in order, since most browsers also claim to be
+// the browsers they are derived from (Edge and Opera both contain "Chrome/")
+var browserSignatures = []struct {
+	token   string
+	browser string
+}{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+var botSignatures = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "preview"}
+
+// ParseBrowser returns the browser family for a User-Agent header
+func ParseBrowser(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	for _, signature := range botSignatures {
+		if strings.Contains(ua, signature) {
+			return "Bot"
+		}
+	}
+
+	for _, signature := range browserSignatures {
+		if strings.Contains(ua, signature.token) {
+			return signature.browser
+		}
+	}
+
+	return "Other"
+}
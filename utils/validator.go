@@ -17,47 +17,45 @@ func ValidateStruct(s interface{}) error {
 	return validate.Struct(s)
 }
 
-func HandleValidationError(c *fiber.Ctx, err error) error {
-	if validationErrors, ok := err.(validator.ValidationErrors); ok {
-		var errorMessages []string
-		for _, e := range validationErrors {
-			switch e.Tag() {
-			case "required":
-				errorMessages = append(errorMessages, e.Field()+" is required")
-			case "email":
-				errorMessages = append(errorMessages, e.Field()+" must be a valid email")
-			case "min":
-				errorMessages = append(errorMessages, e.Field()+" must be at least "+e.Param()+" characters")
-			case "max":
-				errorMessages = append(errorMessages, e.Field()+" must be at most "+e.Param()+" characters")
-			case "url":
-				errorMessages = append(errorMessages, e.Field()+" must be a valid URL")
-			case "alphanum":
-				errorMessages = append(errorMessages, e.Field()+" must contain only alphanumeric characters")
-			default:
-				errorMessages = append(errorMessages, e.Field()+" is invalid")
-			}
-		}
+// ValidationMessage describes the first failed rule of a validation error
+func ValidationMessage(err error) string {
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err.Error()
+	}
 
-		message := "Validation failed"
-		if len(errorMessages) > 0 {
-			message = errorMessages[0]
+	var errorMessages []string
+	for _, e := range validationErrors {
+		switch e.Tag() {
+		case "required":
+			errorMessages = append(errorMessages, e.Field()+" is required")
+		case "email":
+			errorMessages = append(errorMessages, e.Field()+" must be a valid email")
+		case "min":
+			errorMessages = append(errorMessages, e.Field()+" must be at least "+e.Param()+" characters")
+		case "max":
+			errorMessages = append(errorMessages, e.Field()+" must be at most "+e.Param()+" characters")
+		case "url":
+			errorMessages = append(errorMessages, e.Field()+" must be a valid URL")
+		case "alphanum":
+			errorMessages = append(errorMessages, e.Field()+" must contain only alphanumeric characters")
+		default:
+			errorMessages = append(errorMessages, e.Field()+" is invalid")
 		}
+	}
 
-		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error: models.ErrorDetail{
-				Code:      "VALIDATION_ERROR",
-				Message:   message,
-				RequestID: c.Locals("requestid").(string),
-			},
-		})
+	if len(errorMessages) > 0 {
+		return errorMessages[0]
 	}
+	return "Validation failed"
+}
 
+func HandleValidationError(c *fiber.Ctx, err error) error {
 	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 		Error: models.ErrorDetail{
 			Code:      "VALIDATION_ERROR",
-			Message:   err.Error(),
+			Message:   ValidationMessage(err),
 			RequestID: c.Locals("requestid").(string),
 		},
 	})
-}
\ No newline at end of file
+}